@@ -0,0 +1,63 @@
+package flatset
+
+
+import (
+    "slices"
+)
+
+// This is the constraint for the integer types that can be searched with ContainsBatch.
+//
+type Integer interface {
+    ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+
+// The number of values that are searched together by ContainsBatch.
+//
+const batchWidth = 8
+
+
+// Returns true for each of these values that is contained within a FlatSet of integers that is sorted in ascending
+// order. The values are searched in blocks using a branchless binary search, so the memory loads for several values are
+// in flight at the same time and there are no mispredicted branches, which is much faster than calling Contains for
+// each value when there are thousands of values. The values are only known to be sorted with the < operator if the
+// FlatSet was created by NewOrderedFlatSet or InitOrderedFlatSet, so for any other comparison function this function
+// falls back to ContainsEach.
+//
+func ContainsBatch[V Integer](self *FlatSet[V], values []V) []bool {
+    found := make([]bool, len(values))
+    data := self.data
+    size := len(data)
+    if size == 0 {
+        return found
+    } else if !isLessOrdered(self.cmp) {
+        i := 0
+        for _, contains := range self.ContainsEach(slices.Values(values)) {
+            found[i] = contains
+            i++
+        }
+        return found
+    }
+
+    var lo [batchWidth]int
+    for start := 0; start < len(values); start += batchWidth {
+        block := values[start:min(start + batchWidth, len(values))]
+        clear(lo[:])
+        for n := size; n > 1; n -= n / 2 {
+            half := n / 2
+            for j, value := range block {
+                if data[lo[j] + half] < value {
+                    lo[j] += half
+                }
+            }
+        }
+        for j, value := range block {
+            idx := lo[j]
+            if data[idx] < value {
+                idx++
+            }
+            found[start + j] = idx < size && data[idx] == value
+        }
+    }
+    return found
+}
@@ -0,0 +1,70 @@
+package flatset
+
+import (
+    "slices"
+    "testing"
+)
+
+
+// Test the ContainsBatch function returns the same results as Contains for ascending and descending FlatSets.
+//
+func TestContainsBatch(t *testing.T) {
+    values := randInt(-10, 1010, 1000)
+    for size := 0; size < 40; size++ {
+        for _, fs := range []*FlatSet[int] {InitOrderedFlatSet(randInt(0, 1000, size)),
+                                            InitFlatSet(randInt(0, 1000, size), lessInt),
+                                            InitFlatSet(randInt(0, 1000, size), greaterInt)} {
+            found := ContainsBatch(fs, values)
+            for i, value := range values {
+                if found[i] != fs.Contains(value) {
+                    t.Errorf("ContainsBatch(%d) size(%d): expected(%t), actual(%t)", value, fs.Size(),
+                             fs.Contains(value), found[i])
+                }
+            }
+        }
+    }
+
+    fs := InitOrderedFlatSet(randInt(0, 1000, 500))
+    found := ContainsBatch(fs, fs.data)
+    for i := range found {
+        if !found[i] {
+            t.Errorf("ContainsBatch(%d): expected(true), actual(false)", fs.data[i])
+        }
+    }
+
+    if !isLessOrdered(fs.cmp) || isLessOrdered(Compare[int](lessInt)) {
+        t.Errorf("ContainsBatch(): expected(only ordered sets to use the branchless search)")
+    }
+
+    // the first and last values are in ascending order but the other values are not
+    abs := InitFlatSet([]int {-1, 2, -3, 4}, func(lhs, rhs int) bool { return max(lhs, -lhs) < max(rhs, -rhs) })
+    if found := ContainsBatch(abs, []int {-3, 2, 5}); !slices.Equal(found, []bool {true, true, false}) {
+        t.Errorf("ContainsBatch(|x|): expected([true true false]), actual(%v)", found)
+    }
+}
+
+
+var bmOrdered = InitOrderedFlatSet(bmInit.data)
+
+
+var bmBatchProbes = randInt(0, 1000000, 10000)
+
+
+// Search for each value individually using O(log n) complexity.
+//
+func BenchmarkContainsEach(b *testing.B) {
+    for i := 0; i < b.N; i++ {
+        for _, value := range bmBatchProbes {
+            bmInit.Contains(value)
+        }
+    }
+}
+
+
+// Search for the values in blocks using a branchless binary search.
+//
+func BenchmarkContainsBatch(b *testing.B) {
+    for i := 0; i < b.N; i++ {
+        ContainsBatch(bmOrdered, bmBatchProbes)
+    }
+}
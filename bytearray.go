@@ -0,0 +1,73 @@
+package flatset
+
+
+import (
+    "bytes"
+    "encoding/hex"
+    "errors"
+    "strings"
+    "unsafe"
+)
+
+// This is the constraint for the fixed size byte arrays that can be stored in a byte array set, such as UUIDs and
+// SHA-256 hashes.
+//
+type ByteArray interface {
+    ~[16]byte | ~[32]byte
+}
+
+
+// Private function that returns the bytes of an array without copying them.
+//
+func arrayBytes[V ByteArray](value *V) []byte {
+    return unsafe.Slice((*byte)(unsafe.Pointer(value)), unsafe.Sizeof(*value))
+}
+
+
+// A comparison function that sorts byte arrays in ascending order of their bytes, in the same way as memcmp. The bytes
+// are compared with bytes.Compare which compares many bytes at a time, so this is much faster than comparing the
+// arrays one byte at a time.
+//
+func LessBytes[V ByteArray](a, b V) bool {
+    return bytes.Compare(arrayBytes(&a), arrayBytes(&b)) < 0
+}
+
+
+// Create a new empty FlatSet of byte arrays that is sorted with LessBytes and return a pointer to it. It can be merged
+// with a FlatSet created with LessBytes directly, such as by InitFlatSet, without sorting the values again.
+//
+func NewByteArraySet[V ByteArray]() *FlatSet[V] {
+    return NewFlatSet[V](LessBytes[V])
+}
+
+
+// Parse a byte array from its hexadecimal encoding, such as a content hash. Any dashes are ignored so that UUIDs can be
+// parsed in their standard format. An error is returned if the string is not valid hexadecimal or does not encode
+// exactly the number of bytes in the array.
+//
+func ParseByteArray[V ByteArray](s string) (V, error) {
+    var value V
+    buf := arrayBytes(&value)
+    s = strings.ReplaceAll(s, "-", "")
+    if hex.DecodedLen(len(s)) != len(buf) {
+        return value, errors.New("flatset: wrong length of hexadecimal byte array: " + s)
+    }
+    _, err := hex.Decode(buf, []byte(s))
+    return value, err
+}
+
+
+// Create a new FlatSet of byte arrays sorted with LessBytes containing the values parsed by ParseByteArray from these
+// hexadecimal strings, and return a pointer to it. An error is returned if any of the strings can not be parsed.
+//
+func InitHexSet[V ByteArray](values []string) (*FlatSet[V], error) {
+    data := make([]V, len(values))
+    for i, s := range values {
+        value, err := ParseByteArray[V](s)
+        if err != nil {
+            return nil, err
+        }
+        data[i] = value
+    }
+    return InitFlatSet(data, LessBytes[V]), nil
+}
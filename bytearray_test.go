@@ -0,0 +1,56 @@
+package flatset
+
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "slices"
+    "testing"
+)
+
+// Test byte arrays are sorted in the order of their bytes and can be parsed from hexadecimal strings.
+//
+func TestByteArraySet(t *testing.T) {
+    uuids := []string {"f81d4fae-7dec-11d0-a765-00a0c91e6bf6", "00000000-0000-0000-0000-000000000001",
+                       "f81d4fae-7dec-11d0-a765-00a0c91e6bf5", "ffffffff-ffff-ffff-ffff-ffffffffffff"}
+    fs, err := InitHexSet[[16]byte](uuids)
+    if err != nil || fs.Size() != 4 {
+        t.Fatalf("InitHexSet(): expected(4), actual(%v)", err)
+    }
+    var actual []string
+    for value := range fs.All() {
+        actual = append(actual, hex.EncodeToString(value[:]))
+    }
+    expected := []string {"00000000000000000000000000000001", "f81d4fae7dec11d0a76500a0c91e6bf5",
+                          "f81d4fae7dec11d0a76500a0c91e6bf6", "ffffffffffffffffffffffffffffffff"}
+    if !slices.Equal(actual, expected) {
+        t.Errorf("InitHexSet(): expected(%v), actual(%v)", expected, actual)
+    }
+    if value, err := ParseByteArray[[16]byte](uuids[0]); err != nil || fs.Find(value) != 2 {
+        t.Errorf("FlatSet.Find(%s): expected(2), actual(%d, %v)", uuids[0], fs.Find(value), err)
+    }
+    for _, invalid := range []string {"f81d4fae", "zz1d4fae-7dec-11d0-a765-00a0c91e6bf6"} {
+        if _, err := ParseByteArray[[16]byte](invalid); err == nil {
+            t.Errorf("ParseByteArray(%s): expected(error), actual(nil)", invalid)
+        }
+    }
+
+    hashes := NewByteArraySet[[32]byte]()
+    for _, s := range []string {"b", "a", "c", "a"} {
+        hashes.Insert(sha256.Sum256([]byte(s)))
+    }
+    sum := sha256.Sum256([]byte("a"))
+    hash, err := ParseByteArray[[32]byte](hex.EncodeToString(sum[:]))
+    if err != nil || hashes.Size() != 3 || !hashes.Contains(hash) {
+        t.Errorf("FlatSet.Contains(sha256(a)): expected(3, true), actual(%d, %t)", hashes.Size(), hashes.Contains(hash))
+    }
+
+    // a set sorted with LessBytes is recognised as being in the same order without sorting a copy of it
+    other := InitFlatSet([][32]byte {sha256.Sum256([]byte("d")), sha256.Sum256([]byte("e"))}, LessBytes[[32]byte])
+    if hashes.sameOrder(other) != other || other.sameOrder(hashes) != hashes {
+        t.Errorf("FlatSet.sameOrder(LessBytes): expected(the same set), actual(a sorted copy)")
+    }
+    if hashes.Merge(other); hashes.Size() != 5 || !sortedWith(hashes.data, LessBytes[[32]byte], true) {
+        t.Errorf("FlatSet.Merge(LessBytes): expected(5 sorted values), actual(%d)", hashes.Size())
+    }
+}
@@ -0,0 +1,127 @@
+package flatset
+
+
+import (
+    "encoding/binary"
+    "io"
+    "reflect"
+    "unsafe"
+)
+
+// A Codec defines how the values of a FlatSet or FlatMultiSet are serialized. Encode appends the encoding of a value to
+// a buffer and returns the extended buffer. Decode decodes a value from the start of a buffer and returns it with the
+// number of bytes that were read, or an error if the buffer does not start with a valid encoding.
+//
+type Codec[V any] interface {
+    Encode(value V, buf []byte) []byte
+    Decode(buf []byte) (V, int, error)
+}
+
+
+// An IntegerCodec encodes integers using the fixed number of bytes for their size in little-endian byte order. The int,
+// uint and uintptr types are always encoded using 8 bytes so that the encoding does not depend on the architecture.
+//
+type IntegerCodec[V Integer] struct{}
+
+
+// Private function that returns the number of bytes used to encode this type of integer.
+//
+func integerSize[V Integer]() int {
+    switch reflect.TypeFor[V]().Kind() {
+    case reflect.Int, reflect.Uint, reflect.Uintptr:
+        return 8
+    }
+    var value V
+    return int(unsafe.Sizeof(value))
+}
+
+
+// Append the little-endian encoding of this integer to the buffer.
+//
+func (IntegerCodec[V]) Encode(value V, buf []byte) []byte {
+    switch integerSize[V]() {
+    case 1:
+        return append(buf, byte(value))
+    case 2:
+        return binary.LittleEndian.AppendUint16(buf, uint16(value))
+    case 4:
+        return binary.LittleEndian.AppendUint32(buf, uint32(value))
+    default:
+        return binary.LittleEndian.AppendUint64(buf, uint64(value))
+    }
+}
+
+
+// Decode a little-endian integer from the start of the buffer.
+//
+func (IntegerCodec[V]) Decode(buf []byte) (V, int, error) {
+    var value V
+    size := integerSize[V]()
+    if len(buf) < size {
+        return value, 0, io.ErrUnexpectedEOF
+    }
+    switch size {
+    case 1:
+        value = V(buf[0])
+    case 2:
+        value = V(binary.LittleEndian.Uint16(buf))
+    case 4:
+        value = V(binary.LittleEndian.Uint32(buf))
+    default:
+        value = V(binary.LittleEndian.Uint64(buf))
+    }
+    return value, size, nil
+}
+
+
+// A StringCodec encodes strings as their length in bytes followed by the bytes of the string.
+//
+type StringCodec[V ~string] struct{}
+
+
+// Append the length and bytes of this string to the buffer.
+//
+func (StringCodec[V]) Encode(value V, buf []byte) []byte {
+    buf = binary.AppendUvarint(buf, uint64(len(value)))
+    return append(buf, value...)
+}
+
+
+// Decode a string from the start of the buffer.
+//
+func (StringCodec[V]) Decode(buf []byte) (V, int, error) {
+    length, n := binary.Uvarint(buf)
+    if n <= 0 || uint64(len(buf) - n) < length {
+        return "", 0, io.ErrUnexpectedEOF
+    }
+    return V(buf[n:n + int(length)]), n + int(length), nil
+}
+
+
+// A FixedCodec encodes values that have a fixed size, such as structures of numbers or arrays of bytes, using
+// encoding/binary in little-endian byte order.
+//
+type FixedCodec[V any] struct{}
+
+
+// Append the little-endian encoding of this value to the buffer.
+//
+func (FixedCodec[V]) Encode(value V, buf []byte) []byte {
+    buf, err := binary.Append(buf, binary.LittleEndian, value)
+    if err != nil {
+        panic("flatset: FixedCodec can only encode values with a fixed size: " + err.Error())
+    }
+    return buf
+}
+
+
+// Decode a value from the start of the buffer.
+//
+func (FixedCodec[V]) Decode(buf []byte) (V, int, error) {
+    var value V
+    if size := binary.Size(value); size >= 0 && len(buf) < size {
+        return value, 0, io.ErrUnexpectedEOF
+    }
+    n, err := binary.Decode(buf, binary.LittleEndian, &value)
+    return value, n, err
+}
@@ -0,0 +1,80 @@
+package flatset
+
+
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "text/tabwriter"
+)
+
+// Private method that writes a table of the index and formatted value of each value in this container. If groups are
+// given, an extra column with this name is written containing the group of the value at each index, and a blank line
+// is written at each boundary between the groups so that they are easy to see.
+//
+func (self *base[V]) dump(w io.Writer, format func(V) string, column string, groups []int) error {
+    if format == nil {
+        format = func(value V) string { return fmt.Sprint(value) }
+    }
+    tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    header := "index\tvalue\n"
+    if groups != nil {
+        header = "index\t" + column + "\tvalue\n"
+    }
+    if _, err := io.WriteString(tw, header); err != nil {
+        return err
+    }
+    for i, value := range self.data {
+        row := strconv.Itoa(i) + "\t" + format(value) + "\n"
+        if groups != nil {
+            row = strconv.Itoa(i) + "\t" + strconv.Itoa(groups[i]) + "\t" + format(value) + "\n"
+            if i > 0 && groups[i] != groups[i - 1] {
+                row = "\t\t\n" + row
+            }
+        }
+        if _, err := io.WriteString(tw, row); err != nil {
+            return err
+        }
+    }
+    return tw.Flush()
+}
+
+
+// Write a readable table of the index and value of each value in this FlatSet, to help diagnose ordering bugs. The
+// values are formatted with this function, or with fmt.Sprint if it is nil.
+//
+func (self *FlatSet[V]) Dump(w io.Writer, format func(V) string) error {
+    return self.dump(w, format, "", nil)
+}
+
+
+// Write a readable table of the index, run and value of each value in this FlatMultiSet, to help diagnose ordering
+// bugs. Each run of equivalent values is numbered from 0 and separated from the next run by a blank line. The values
+// are formatted with this function, or with fmt.Sprint if it is nil.
+//
+func (self *FlatMultiSet[V]) Dump(w io.Writer, format func(V) string) error {
+    runs := make([]int, len(self.data))
+    for i := 1; i < len(self.data); i++ {
+        runs[i] = runs[i - 1]
+        if self.cmp(self.data[i - 1], self.data[i]) {
+            runs[i]++
+        }
+    }
+    return self.dump(w, format, "run", runs)
+}
+
+
+// Write a readable table of the index and value of each value in this FrozenFlatSet, to help diagnose ordering bugs.
+// When the BlockLayout is used the block of each value is also written, and each block is separated from the next by
+// a blank line. The values are formatted with this function, or with fmt.Sprint if it is nil.
+//
+func (self *FrozenFlatSet[V]) Dump(w io.Writer, format func(V) string) error {
+    if self.layout != BlockLayout {
+        return self.sorted.dump(w, format, "", nil)
+    }
+    blocks := make([]int, len(self.sorted.data))
+    for i := range blocks {
+        blocks[i] = i / self.block
+    }
+    return self.sorted.dump(w, format, "block", blocks)
+}
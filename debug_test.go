@@ -0,0 +1,31 @@
+package flatset
+
+
+import (
+    "strconv"
+    "strings"
+    "testing"
+)
+
+// Test the Dump methods write the expected tables for each type of container.
+//
+func TestDump(t *testing.T) {
+    var sb strings.Builder
+    fs := InitFlatSet([]int {3, 1, 2}, lessInt)
+    if err := fs.Dump(&sb, nil); err != nil || sb.String() != "index  value\n0      1\n1      2\n2      3\n" {
+        t.Errorf("FlatSet.Dump(): expected(table), actual(%q, %v)", sb.String(), err)
+    }
+
+    sb.Reset()
+    ms := InitFlatMultiSet([]int {2, 1, 2}, lessInt)
+    expected := "index  run  value\n0      0    #1\n            \n1      1    #2\n2      1    #2\n"
+    if err := ms.Dump(&sb, func(v int) string { return "#" + strconv.Itoa(v) }); err != nil || sb.String() != expected {
+        t.Errorf("FlatMultiSet.Dump(): expected(%q), actual(%q, %v)", expected, sb.String(), err)
+    }
+
+    sb.Reset()
+    frozen := InitFlatSet(randInt(0, 1000, 100), lessInt).Freeze(BlockLayout)
+    if err := frozen.Dump(&sb, nil); err != nil || !strings.HasPrefix(sb.String(), "index  block  value\n0      0") {
+        t.Errorf("FrozenFlatSet.Dump(): expected(block table), actual(%q, %v)", sb.String(), err)
+    }
+}
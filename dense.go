@@ -0,0 +1,373 @@
+package flatset
+
+
+import (
+    "iter"
+    "math/bits"
+)
+
+// The number of 64 bit words counted by each entry of the rank index of a DenseIntSet.
+//
+const rankWords = 8
+
+
+// A DenseIntSet is a set of small non-negative integers stored as a bitmap, where each possible value uses a single bit
+// instead of the 64 bits used by a FlatSet of ints. It has the same iterator and set operation methods as a FlatSet
+// sorted in ascending order, and the indices of the values are found with a rank index of the number of values before
+// each block of the bitmap. The memory used is proportional to the greatest value rather than to the number of values,
+// so it should only be used when the values are dense within a small domain. The rank index is rebuilt by the first
+// method that uses it after the set is modified, so concurrent calls of LowerBound, UpperBound, Search, Find or At
+// must also be synchronized until the rank index has been rebuilt.
+//
+type DenseIntSet[V Integer] struct {
+    guard guard     // detects unsynchronized use in debug builds
+    words []uint64  // bitmap with a bit set for each value
+    size int        // number of values stored in the bitmap
+    ranks []int     // number of values before each block of rankWords words
+    stale bool      // the rank index must be rebuilt before it is used
+}
+
+
+// Create a new empty DenseIntSet and return a pointer to it.
+//
+func NewDenseIntSet[V Integer]() *DenseIntSet[V] {
+    return &DenseIntSet[V]{}
+}
+
+
+// Create a new DenseIntSet containing these values and return a pointer to it. The values do not need to be sorted and
+// any repeated values are ignored.
+//
+func InitDenseIntSet[V Integer](values []V) *DenseIntSet[V] {
+    self := &DenseIntSet[V]{}
+    for _, value := range values {
+        self.Insert(value)
+    }
+    return self
+}
+
+
+// Private method that returns the index of the word and the bit for this value, and false if the value is negative.
+//
+func (self *DenseIntSet[V]) locate(value V) (int, uint64, bool) {
+    if value < 0 {
+        return 0, 0, false
+    }
+    return int(uint64(value) / 64), 1 << (uint64(value) % 64), true
+}
+
+
+// Private method that rebuilds the rank index if the values have been modified since it was last used.
+//
+func (self *DenseIntSet[V]) rankIndex() []int {
+    if self.stale {
+        self.ranks = self.ranks[:0]
+        count := 0
+        for i, word := range self.words {
+            if i % rankWords == 0 {
+                self.ranks = append(self.ranks, count)
+            }
+            count += bits.OnesCount64(word)
+        }
+        self.stale = false
+    }
+    return self.ranks
+}
+
+
+// Private method that returns the number of values in this set that are less than this value.
+//
+func (self *DenseIntSet[V]) rank(value V) int {
+    w, bit, ok := self.locate(value)
+    if !ok {
+        return 0
+    } else if w >= len(self.words) {
+        return self.size
+    }
+    count := self.rankIndex()[w / rankWords]
+    for i := w - w % rankWords; i < w; i++ {
+        count += bits.OnesCount64(self.words[i])
+    }
+    return count + bits.OnesCount64(self.words[w] & (bit - 1))
+}
+
+
+// Insert a value into this set. Returns true if the value was inserted or false if it already exists. The value must
+// not be negative, otherwise this method will panic.
+//
+func (self *DenseIntSet[V]) Insert(value V) bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    w, bit, ok := self.locate(value)
+    if !ok {
+        panic("flatset: DenseIntSet values must not be negative")
+    }
+    if w >= len(self.words) {
+        self.words = append(self.words, make([]uint64, w + 1 - len(self.words))...)
+    }
+    if self.words[w] & bit != 0 {
+        return false
+    }
+    self.words[w] |= bit
+    self.size++
+    self.stale = true
+    return true
+}
+
+
+// Remove a value from this set. Returns true if the value was removed or false if it was not found.
+//
+func (self *DenseIntSet[V]) Remove(value V) bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    if !self.Contains(value) {
+        return false
+    }
+    w, bit, _ := self.locate(value)
+    self.words[w] &^= bit
+    self.size--
+    self.stale = true
+    return true
+}
+
+
+// Insert the values from an iterator into this set.
+//
+func (self *DenseIntSet[V]) Update(values iter.Seq[V]) {
+    if debug {
+        defer self.guard.write()()
+    }
+    for value := range values {
+        self.Insert(value)
+    }
+}
+
+
+// Empty this set and release its bitmap and rank index, so that their memory can be reclaimed by the garbage collector.
+// Values inserted later are stored in a new bitmap.
+//
+func (self *DenseIntSet[V]) Clear() {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.words = nil
+    self.ranks = nil
+    self.size = 0
+    self.stale = false
+}
+
+
+// Efficiently empty this set keeping its bitmap for future insertions, so that values up to the greatest value that was
+// in this set can be inserted again without reallocating. The bitmap is zeroed rather than released.
+//
+func (self *DenseIntSet[V]) ClearRetainingCapacity() {
+    if debug {
+        defer self.guard.write()()
+    }
+    clear(self.words)
+    self.size = 0
+    self.stale = true
+}
+
+
+// Returns the number of values stored in this set.
+//
+func (self *DenseIntSet[V]) Size() int {
+    return self.size
+}
+
+
+// Returns true if this set has this value or false if it does not.
+//
+func (self *DenseIntSet[V]) Contains(value V) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+    w, bit, ok := self.locate(value)
+    return ok && w < len(self.words) && self.words[w] & bit != 0
+}
+
+
+// Returns the index of the first value in this set that is not less than this value.
+//
+func (self *DenseIntSet[V]) LowerBound(value V) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    return self.rank(value)
+}
+
+
+// Returns the index of the first value in this set that is greater than this value.
+//
+func (self *DenseIntSet[V]) UpperBound(value V) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    if self.Contains(value) {
+        return self.rank(value) + 1
+    }
+    return self.rank(value)
+}
+
+
+// Searches for a value within this set and returns its index and true if it is found, otherwise it returns the index
+// where the value would be inserted and false.
+//
+func (self *DenseIntSet[V]) Search(value V) (int, bool) {
+    if debug {
+        defer self.guard.read()()
+    }
+    return self.rank(value), self.Contains(value)
+}
+
+
+// Searches for a value within this set, and returns the index for the location of the value or -1 if not found.
+//
+func (self *DenseIntSet[V]) Find(value V) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    if !self.Contains(value) {
+        return -1
+    }
+    return self.rank(value)
+}
+
+
+// Returns the value at the given index, where the values are indexed in ascending order. The block containing the
+// value is found with a binary search of the rank index. This method will panic if the index is out of range.
+//
+func (self *DenseIntSet[V]) At(index int) V {
+    if debug {
+        defer self.guard.read()()
+    }
+    if index < 0 || index >= self.size {
+        panic("flatset: DenseIntSet index out of range")
+    }
+    ranks := self.rankIndex()
+    low, high := 0, len(ranks) - 1
+    for low < high {
+        mid := (low + high + 1) / 2
+        if ranks[mid] <= index {
+            low = mid
+        } else {
+            high = mid - 1
+        }
+    }
+    remaining := index - ranks[low]
+    for w := low * rankWords; ; w++ {
+        word := self.words[w]
+        if count := bits.OnesCount64(word); remaining >= count {
+            remaining -= count
+            continue
+        }
+        for ; remaining > 0; remaining-- {
+            word &= word - 1
+        }
+        return V(w * 64 + bits.TrailingZeros64(word))
+    }
+}
+
+
+// Returns an iterator that returns each value in ascending order.
+//
+func (self *DenseIntSet[V]) All() iter.Seq[V] {
+    return func(yield func(V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
+        for w := 0; w < len(self.words); w++ {
+            for word := self.words[w]; word != 0; word &= word - 1 {
+                if !yield(V(w * 64 + bits.TrailingZeros64(word))) {
+                    return
+                }
+                if debug {
+                    self.guard.iterated(generation)
+                }
+            }
+        }
+    }
+}
+
+
+// Returns an iterator that returns each value in descending order.
+//
+func (self *DenseIntSet[V]) Backward() iter.Seq[V] {
+    return func(yield func(V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
+        for w := len(self.words) - 1; w >= 0; w-- {
+            for word := self.words[w]; word != 0; word &^= 1 << (63 - bits.LeadingZeros64(word)) {
+                if !yield(V(w * 64 + 63 - bits.LeadingZeros64(word))) {
+                    return
+                }
+                if debug {
+                    self.guard.iterated(generation)
+                }
+            }
+        }
+    }
+}
+
+
+// Returns a new set containing the values of this set and the values from an iterator.
+//
+func (self *DenseIntSet[V]) Union(values iter.Seq[V]) *DenseIntSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    out := &DenseIntSet[V]{words: append([]uint64(nil), self.words...), size: self.size, stale: true}
+    out.Update(values)
+    return out
+}
+
+
+// Returns a new set containing the values from an iterator that are also in this set.
+//
+func (self *DenseIntSet[V]) Intersection(values iter.Seq[V]) *DenseIntSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    out := &DenseIntSet[V]{}
+    for value := range values {
+        if self.Contains(value) {
+            out.Insert(value)
+        }
+    }
+    return out
+}
+
+
+// Returns a new set containing the values of this set that are not in an iterator.
+//
+func (self *DenseIntSet[V]) Difference(values iter.Seq[V]) *DenseIntSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    out := &DenseIntSet[V]{words: append([]uint64(nil), self.words...), size: self.size, stale: true}
+    for value := range values {
+        out.Remove(value)
+    }
+    return out
+}
+
+
+// Returns a new FlatSet containing the values of this set sorted in ascending order.
+//
+func (self *DenseIntSet[V]) ToFlatSet() *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    out := NewFlatSet[V](orderedCompare[V]())
+    out.data = make([]V, 0, self.size)
+    for value := range self.All() {
+        out.data = append(out.data, value)
+    }
+    return out
+}
@@ -0,0 +1,76 @@
+package flatset
+
+
+import (
+    "slices"
+    "testing"
+)
+
+// Test the DenseIntSet returns the same results as a FlatSet with the same values.
+//
+func TestDenseIntSet(t *testing.T) {
+    values := randInt(0, 5000, 2000)
+    ds := InitDenseIntSet(values)
+    fs := InitFlatSet(values, lessInt)
+    if ds.Size() != fs.Size() || !slices.Equal(slices.Collect(ds.All()), fs.data) {
+        t.Errorf("DenseIntSet.All(): expected(%d) values, actual(%d)", fs.Size(), ds.Size())
+    }
+    if !slices.Equal(slices.Collect(ds.Backward()), slices.Collect(fs.Backward())) {
+        t.Errorf("DenseIntSet.Backward(): expected(descending values)")
+    }
+    if out := ds.ToFlatSet(); !slices.Equal(out.data, fs.data) || !isLessOrdered(out.cmp) {
+        t.Errorf("DenseIntSet.ToFlatSet(): expected(%d) values sorted with <", fs.Size())
+    }
+
+    for _, value := range append(randInt(-10, 5100, 500), -1, 0, 4999, 5000, 64, 511, 512) {
+        index, found := ds.Search(value)
+        expectedIndex, expectedFound := fs.Search(value)
+        if index != expectedIndex || found != expectedFound {
+            t.Errorf("DenseIntSet.Search(%d): expected(%d, %t), actual(%d, %t)", value, expectedIndex, expectedFound,
+                     index, found)
+        }
+        if ds.UpperBound(value) != fs.UpperBound(value) || ds.Find(value) != fs.Find(value) {
+            t.Errorf("DenseIntSet.UpperBound(%d): expected(%d), actual(%d)", value, fs.UpperBound(value),
+                     ds.UpperBound(value))
+        }
+    }
+    for i := 0; i < fs.Size(); i++ {
+        if ds.At(i) != fs.At(i) {
+            t.Errorf("DenseIntSet.At(%d): expected(%d), actual(%d)", i, fs.At(i), ds.At(i))
+        }
+    }
+
+    other := randInt(0, 6000, 1000)
+    if !slices.Equal(slices.Collect(ds.Union(slices.Values(other)).All()), fs.Union(slices.Values(other)).data) {
+        t.Errorf("DenseIntSet.Union(): expected(same values as FlatSet.Union)")
+    }
+    if !slices.Equal(slices.Collect(ds.Intersection(slices.Values(other)).All()),
+                     fs.Intersection(slices.Values(other)).data) {
+        t.Errorf("DenseIntSet.Intersection(): expected(same values as FlatSet.Intersection)")
+    }
+    if !slices.Equal(slices.Collect(ds.Difference(slices.Values(other)).All()),
+                     fs.Difference(slices.Values(other)).data) {
+        t.Errorf("DenseIntSet.Difference(): expected(same values as FlatSet.Difference)")
+    }
+
+    if ds.Insert(fs.At(0)) || !ds.Remove(fs.At(0)) || ds.Remove(fs.At(0)) || ds.Size() != fs.Size() - 1 {
+        t.Errorf("DenseIntSet.Remove(%d): expected(%d) values, actual(%d)", fs.At(0), fs.Size() - 1, ds.Size())
+    }
+    if ds.At(0) != fs.At(1) {
+        t.Errorf("DenseIntSet.At(0): expected(%d), actual(%d)", fs.At(1), ds.At(0))
+    }
+    words := len(ds.words)
+    ds.ClearRetainingCapacity()
+    if ds.Size() != 0 || ds.Contains(fs.At(1)) || ds.LowerBound(100) != 0 || len(ds.words) != words {
+        t.Errorf("DenseIntSet.ClearRetainingCapacity(): expected(0, %d), actual(%d, %d)", words, ds.Size(),
+                 len(ds.words))
+    }
+    ds.Insert(5)
+    ds.Clear()
+    if ds.Size() != 0 || ds.Contains(5) || ds.LowerBound(100) != 0 || ds.words != nil {
+        t.Errorf("DenseIntSet.Clear(): expected(0, 0), actual(%d, %d)", ds.Size(), len(ds.words))
+    }
+    if !ds.Insert(3) || ds.At(0) != 3 || ds.Find(3) != 0 {
+        t.Errorf("DenseIntSet.Insert(3): expected(true), actual(%v)", slices.Collect(ds.All()))
+    }
+}
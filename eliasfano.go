@@ -0,0 +1,219 @@
+package flatset
+
+
+import (
+    "iter"
+    "math/bits"
+)
+
+// An EliasFanoSet is a read-only set of integers stored with the succinct Elias-Fano encoding, which uses less than
+// 2 + log2(u/n) bits per value for n values less than u, instead of the 64 bits per value of a FlatSet[uint64]. The
+// low bits of each value are stored in a packed array and the high bits are stored in unary in a bitmap, where a rank
+// index of the bitmap is used to find the values. This is useful for very large sets of integers such as the posting
+// lists of an inverted index, where the memory used matters more than the speed of each search.
+//
+type EliasFanoSet struct {
+    size int           // number of values stored in the set
+    lowBits int        // number of low bits of each value stored in the lower array
+    lower []uint64     // packed array of the low bits of each value
+    upper []uint64     // bitmap with a bit set at the position of each value's high bits plus its index
+    ranks []int        // number of bits set before each block of rankWords words of the upper bitmap
+}
+
+
+// Create a new EliasFanoSet containing the values of a FlatSet of uint64 that is sorted in ascending order and return
+// a pointer to it. The FlatSet is not modified. If the values are not in ascending order ErrOutOfOrder is returned.
+//
+func InitEliasFanoSet(values *FlatSet[uint64]) (*EliasFanoSet, error) {
+    data := values.data
+    for i := 1; i < len(data); i++ {
+        if data[i - 1] >= data[i] {
+            return nil, ErrOutOfOrder
+        }
+    }
+
+    self := &EliasFanoSet{size: len(data)}
+    if len(data) == 0 {
+        return self, nil
+    }
+    if ratio := data[len(data) - 1] / uint64(len(data)); ratio > 0 {
+        self.lowBits = bits.Len64(ratio) - 1
+    }
+    upperSize := len(data) + int(data[len(data) - 1] >> self.lowBits) + 1
+    self.upper = make([]uint64, (upperSize + 63) / 64)
+    self.lower = make([]uint64, (len(data) * self.lowBits + 63) / 64)
+
+    mask := uint64(1) << self.lowBits - 1
+    for i, value := range data {
+        position := int(value >> self.lowBits) + i
+        self.upper[position / 64] |= 1 << (position % 64)
+        if self.lowBits > 0 {
+            offset := i * self.lowBits
+            self.lower[offset / 64] |= (value & mask) << (offset % 64)
+            if offset % 64 + self.lowBits > 64 {
+                self.lower[offset / 64 + 1] |= (value & mask) >> (64 - offset % 64)
+            }
+        }
+    }
+
+    count := 0
+    for i, word := range self.upper {
+        if i % rankWords == 0 {
+            self.ranks = append(self.ranks, count)
+        }
+        count += bits.OnesCount64(word)
+    }
+    return self, nil
+}
+
+
+// Private method that returns the low bits of the value at this index.
+//
+func (self *EliasFanoSet) low(index int) uint64 {
+    if self.lowBits == 0 {
+        return 0
+    }
+    offset := index * self.lowBits
+    value := self.lower[offset / 64] >> (offset % 64)
+    if offset % 64 + self.lowBits > 64 {
+        value |= self.lower[offset / 64 + 1] << (64 - offset % 64)
+    }
+    return value & (uint64(1) << self.lowBits - 1)
+}
+
+
+// Private method that returns the position of the k-th bit (counting from 0) of the upper bitmap that is set if ones
+// is true, or that is clear if ones is false. The block containing the bit is found with a binary search of the rank
+// index and then the words of the block are scanned.
+//
+func (self *EliasFanoSet) selectBit(k int, ones bool) int {
+    count := func(block int) int {
+        if ones {
+            return self.ranks[block]
+        }
+        return block * rankWords * 64 - self.ranks[block]
+    }
+    low, high := 0, len(self.ranks) - 1
+    for low < high {
+        mid := (low + high + 1) / 2
+        if count(mid) <= k {
+            low = mid
+        } else {
+            high = mid - 1
+        }
+    }
+    remaining := k - count(low)
+    for w := low * rankWords; ; w++ {
+        word := self.upper[w]
+        if !ones {
+            word = ^word
+        }
+        if n := bits.OnesCount64(word); remaining >= n {
+            remaining -= n
+            continue
+        }
+        for ; remaining > 0; remaining-- {
+            word &= word - 1
+        }
+        return w * 64 + bits.TrailingZeros64(word)
+    }
+}
+
+
+// Returns the number of values stored in this set.
+//
+func (self *EliasFanoSet) Size() int {
+    return self.size
+}
+
+
+// Returns the value at the given index, where the values are indexed in ascending order. This method will panic if
+// the index is out of range.
+//
+func (self *EliasFanoSet) At(index int) uint64 {
+    if index < 0 || index >= self.size {
+        panic("flatset: EliasFanoSet index out of range")
+    }
+    return uint64(self.selectBit(index, true) - index) << self.lowBits | self.low(index)
+}
+
+
+// Returns the index of the first value that is not less than this value and the value, or the size of the set and
+// false if every value is less than this value.
+//
+func (self *EliasFanoSet) lowerBound(value uint64) (int, uint64, bool) {
+    if self.size == 0 {
+        return 0, 0, false
+    }
+    if self.At(self.size - 1) < value {
+        return self.size, 0, false
+    }
+    // the values with these high bits start after the previous zero bit of the upper bitmap
+    high := value >> self.lowBits
+    index := 0
+    if high > 0 {
+        index = self.selectBit(int(high) - 1, false) - int(high) + 1
+    }
+    for ; index < self.size; index++ {
+        if found := self.At(index); found >= value {
+            return index, found, true
+        }
+    }
+    return self.size, 0, false
+}
+
+
+// Returns the smallest value in this set that is greater than or equal to this value and true, or 0 and false if every
+// value is less than this value.
+//
+func (self *EliasFanoSet) NextGEQ(value uint64) (uint64, bool) {
+    _, found, ok := self.lowerBound(value)
+    return found, ok
+}
+
+
+// Returns the index of the first value in this set that is not less than this value.
+//
+func (self *EliasFanoSet) LowerBound(value uint64) int {
+    index, _, _ := self.lowerBound(value)
+    return index
+}
+
+
+// Returns true if this set has this value or false if it does not.
+//
+func (self *EliasFanoSet) Contains(value uint64) bool {
+    found, ok := self.NextGEQ(value)
+    return ok && found == value
+}
+
+
+// Returns an iterator that returns each value in ascending order. The values are decoded with a single pass over the
+// upper bitmap.
+//
+func (self *EliasFanoSet) All() iter.Seq[uint64] {
+    return func(yield func(uint64) bool) {
+        index := 0
+        for w, word := range self.upper {
+            for ; word != 0; word &= word - 1 {
+                high := uint64(w * 64 + bits.TrailingZeros64(word) - index)
+                if !yield(high << self.lowBits | self.low(index)) {
+                    return
+                }
+                index++
+            }
+        }
+    }
+}
+
+
+// Returns a new FlatSet containing the values of this set sorted in ascending order.
+//
+func (self *EliasFanoSet) ToFlatSet() *FlatSet[uint64] {
+    out := NewFlatSet[uint64](orderedCompare[uint64]())
+    out.data = make([]uint64, 0, self.size)
+    for value := range self.All() {
+        out.data = append(out.data, value)
+    }
+    return out
+}
@@ -0,0 +1,56 @@
+package flatset
+
+
+import (
+    "math/rand"
+    "slices"
+    "testing"
+)
+
+// Test the EliasFanoSet returns the same results as the FlatSet it was created from for sparse and dense values.
+//
+func TestEliasFanoSet(t *testing.T) {
+    lessUint := func(a, b uint64) bool { return a < b }
+    for _, limit := range []uint64 {100, 1 << 20, 1 << 62} {
+        values := make([]uint64, 1000)
+        for i := range values {
+            values[i] = rand.Uint64() % limit
+        }
+        fs := InitFlatSet(values, lessUint)
+        ef, err := InitEliasFanoSet(fs)
+        if err != nil || ef.Size() != fs.Size() || !slices.Equal(ef.ToFlatSet().data, fs.data) ||
+           !isLessOrdered(ef.ToFlatSet().cmp) {
+            t.Fatalf("InitEliasFanoSet(): expected(%d) values, actual(%v)", fs.Size(), err)
+        }
+        for i := 0; i < fs.Size(); i++ {
+            if ef.At(i) != fs.At(i) {
+                t.Errorf("EliasFanoSet.At(%d): expected(%d), actual(%d)", i, fs.At(i), ef.At(i))
+            }
+        }
+
+        probes := append(slices.Clone(values[:100]), 0, limit - 1, limit, fs.At(0) + 1, fs.At(fs.Size() - 1) + 1)
+        for i := 0; i < 100; i++ {
+            probes = append(probes, rand.Uint64() % limit)
+        }
+        for _, value := range probes {
+            lb := fs.LowerBound(value)
+            next, ok := ef.NextGEQ(value)
+            if ef.LowerBound(value) != lb || ok != (lb < fs.Size()) || (ok && next != fs.At(lb)) {
+                t.Errorf("EliasFanoSet.NextGEQ(%d): expected(%d), actual(%d, %t)", value, lb, next, ok)
+            }
+            if ef.Contains(value) != fs.Contains(value) {
+                t.Errorf("EliasFanoSet.Contains(%d): expected(%t), actual(%t)", value, fs.Contains(value),
+                         ef.Contains(value))
+            }
+        }
+    }
+
+    empty, err := InitEliasFanoSet(NewFlatSet(lessUint))
+    if _, ok := empty.NextGEQ(0); err != nil || ok || empty.Contains(0) || empty.Size() != 0 {
+        t.Errorf("EliasFanoSet.NextGEQ(0): expected(false), actual(%t)", ok)
+    }
+    descending := InitFlatSet([]uint64 {1, 2, 3}, func(a, b uint64) bool { return a > b })
+    if _, err := InitEliasFanoSet(descending); err != ErrOutOfOrder {
+        t.Errorf("InitEliasFanoSet(): expected(%v), actual(%v)", ErrOutOfOrder, err)
+    }
+}
@@ -0,0 +1,627 @@
+package flatset
+
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "iter"
+    "maps"
+    "reflect"
+    "slices"
+    "sort"
+)
+
+// An Entry is a key and value pair that is stored in a FlatMap or FlatMultiMap.
+//
+type Entry[K, V any] struct {
+    Key K
+    Value V
+}
+
+
+// This is base structure that contains the data for both the FlatMap and FlatMultiMap implementations. The entries are
+// sorted by their key using the comparison function, the values do not affect how the entries are sorted.
+//
+type mapBase[K, V any] struct {
+    less Compare[K]            // comparison function for the keys
+    entries base[Entry[K, V]]  // entries stored in a array of continuous memory sorted by key
+}
+
+
+// Shared private function to make the base structure for a map using a comparison function for the keys.
+//
+func makeMapBase[K, V any](cmp Compare[K]) mapBase[K, V] {
+    return mapBase[K, V]{less: cmp, entries: base[Entry[K, V]]{cmp: func(lhs, rhs Entry[K, V]) bool {
+        return cmp(lhs.Key, rhs.Key)
+    }}}
+}
+
+
+// Shared private method to search for a key and return the index of the lower bound and true if the key was found.
+//
+func (self *mapBase[K, V]) search(key K) (int, bool) {
+    return self.entries.Search(Entry[K, V]{Key: key})
+}
+
+
+// Shared private method that returns the index of the first entry (inclusive) and last entry (exclusive) for this key.
+//
+func (self *mapBase[K, V]) equalRange(key K) (int, int) {
+    probe := Entry[K, V]{Key: key}
+    return self.entries.LowerBound(probe), self.entries.UpperBound(probe)
+}
+
+
+// Empty this container and release its array, so that the memory of the array and of any keys and values it refers to
+// can be reclaimed by the garbage collector. Entries inserted later are stored in a new array.
+//
+func (self *mapBase[K, V]) Clear() {
+    self.entries.Clear()
+}
+
+
+// Efficiently empty this container keeping the array for future insertions, so that it can be reused without
+// reallocating. The entries are zeroed so that the garbage collector can reclaim any keys and values they refer to.
+//
+func (self *mapBase[K, V]) ClearRetainingCapacity() {
+    self.entries.ClearRetainingCapacity()
+}
+
+
+// Returns the number of entries stored in this container.
+//
+func (self *mapBase[K, V]) Size() int {
+    return self.entries.Size()
+}
+
+
+// Returns true if this container has this key or false if it does not.
+//
+func (self *mapBase[K, V]) Contains(key K) bool {
+    _, found := self.search(key)
+    return found
+}
+
+
+// Returns an index to the first entry with a key that is not less than this key.
+//
+func (self *mapBase[K, V]) LowerBound(key K) int {
+    return self.entries.LowerBound(Entry[K, V]{Key: key})
+}
+
+
+// Returns an index to the first entry with a key that is greater than this key.
+//
+func (self *mapBase[K, V]) UpperBound(key K) int {
+    return self.entries.UpperBound(Entry[K, V]{Key: key})
+}
+
+
+// Returns a copy of the key and value of the entry at this index.
+//
+func (self *mapBase[K, V]) At(index int) (K, V) {
+    if debug {
+        defer self.entries.guard.read()()
+    }
+    entry := &self.entries.data[index]
+    return entry.Key, entry.Value
+}
+
+
+// Returns an iterator that returns a copy of each key in order.
+//
+func (self *mapBase[K, V]) Keys() iter.Seq[K] {
+    return func(yield func(K) bool) {
+        var generation uint64
+        if debug {
+            generation = self.entries.guard.generation()
+        }
+        for i := 0; i < len(self.entries.data); i++ {
+            if !yield(self.entries.data[i].Key) {
+                break
+            }
+            if debug {
+                self.entries.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Returns a new FlatSet containing a copy of the keys in this container that is sorted using the same comparison
+// function, so it can be used with the FlatSet set operations.
+//
+func (self *mapBase[K, V]) KeySet() *FlatSet[K] {
+    if debug {
+        defer self.entries.guard.read()()
+    }
+    out := &FlatSet[K]{base[K]{cmp: self.less}}
+    out.data = make([]K, len(self.entries.data))
+    for i := range self.entries.data {
+        out.data[i] = self.entries.data[i].Key
+    }
+    return out
+}
+
+
+// Returns an iterator that returns a copy of each value ordered by its key.
+//
+func (self *mapBase[K, V]) Values() iter.Seq[V] {
+    return func(yield func(V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.entries.guard.generation()
+        }
+        for i := 0; i < len(self.entries.data); i++ {
+            if !yield(self.entries.data[i].Value) {
+                break
+            }
+            if debug {
+                self.entries.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Returns an iterator that returns a copy of each key and value ordered by key.
+//
+func (self *mapBase[K, V]) Entries() iter.Seq2[K, V] {
+    return func(yield func(K, V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.entries.guard.generation()
+        }
+        for i := 0; i < len(self.entries.data); i++ {
+            if !yield(self.entries.data[i].Key, self.entries.data[i].Value) {
+                break
+            }
+            if debug {
+                self.entries.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Shared private method that returns the index of the first entry (inclusive) with a key not less than lo and the last
+// entry (exclusive) with a key less than hi.
+//
+func (self *mapBase[K, V]) keyRange(lo, hi K) (int, int) {
+    from := self.entries.LowerBound(Entry[K, V]{Key: lo})
+    upto := self.entries.LowerBound(Entry[K, V]{Key: hi})
+    return from, max(from, upto)
+}
+
+
+// Returns an iterator that returns a copy of each key and value ordered by key, for the keys from lo (inclusive) upto
+// hi (exclusive).
+//
+func (self *mapBase[K, V]) EntriesRange(lo, hi K) iter.Seq2[K, V] {
+    return func(yield func(K, V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.entries.guard.generation()
+        }
+        from, upto := self.keyRange(lo, hi)
+        for i := from; i < upto; i++ {
+            if !yield(self.entries.data[i].Key, self.entries.data[i].Value) {
+                break
+            }
+            if debug {
+                self.entries.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Shared private method that deletes the entries from this index (inclusive) upto this index (exclusive). The entries
+// left after the end of the array are zeroed so that the garbage collector can reclaim their keys and values.
+//
+func (self *mapBase[K, V]) erase(from, upto int) {
+    size := len(self.entries.data)
+    self.entries.data = append(self.entries.data[:from], self.entries.data[upto:]...)
+    clear(self.entries.data[len(self.entries.data):size])
+}
+
+
+// Delete the entries with keys from lo (inclusive) upto hi (exclusive) and return the number of entries that were
+// removed. This method will invalidate any previous indices.
+//
+func (self *mapBase[K, V]) EraseKeyRange(lo, hi K) int {
+    if debug {
+        defer self.entries.guard.write()()
+    }
+    from, upto := self.keyRange(lo, hi)
+    self.erase(from, upto)
+    return upto - from
+}
+
+
+// A FlatMap is a sorted associative container of unique keys mapped to values using a comparison function for the keys.
+// It can be used as an ordered replacement for a golang map.
+//
+type FlatMap[K, V any] struct {
+    mapBase[K, V]
+}
+
+
+// Make an empty FlatMap.
+//
+func MakeFlatMap[K, V any](cmp Compare[K]) FlatMap[K, V] {
+    return FlatMap[K, V]{makeMapBase[K, V](cmp)}
+}
+
+
+// Create a new empty FlatMap.
+//
+func NewFlatMap[K, V any](cmp Compare[K]) *FlatMap[K, V] {
+    return &FlatMap[K, V]{makeMapBase[K, V](cmp)}
+}
+
+
+// Create a new FlatMap and initialize it with the entries from a golang map. The entries are sorted once using the
+// comparison function. The keys of a golang map are unique, but if the comparison function treats several of them as
+// equivalent only one of them is kept, which is not specified as a golang map is not ordered.
+//
+func FromMap[K comparable, V any](values map[K]V, cmp Compare[K]) *FlatMap[K, V] {
+    self := &FlatMap[K, V]{makeMapBase[K, V](cmp)}
+    entries := make([]Entry[K, V], 0, len(values))
+    for key, value := range values {
+        entries = append(entries, Entry[K, V]{key, value})
+    }
+    self.sortEntries(entries)
+    return self
+}
+
+
+// Returns a new golang map containing a copy of the entries in this FlatMap. This is a function rather than a method
+// because the keys of a golang map must be comparable.
+//
+func ToMap[K comparable, V any](self *FlatMap[K, V]) map[K]V {
+    if debug {
+        defer self.entries.guard.read()()
+    }
+    out := make(map[K]V, len(self.entries.data))
+    for _, entry := range self.entries.data {
+        out[entry.Key] = entry.Value
+    }
+    return out
+}
+
+
+// Returns a copy of the value for this key and true, or the zero value and false if this key is not in this container.
+//
+func (self *FlatMap[K, V]) Get(key K) (V, bool) {
+    if debug {
+        defer self.entries.guard.read()()
+    }
+    lb, found := self.search(key)
+    if found {
+        return self.entries.data[lb].Value, true
+    } else {
+        var zero V
+        return zero, false
+    }
+}
+
+
+// Returns a copy of the value for this key, or the default value if this key is not in this container.
+//
+func (self *FlatMap[K, V]) GetOrDefault(key K, value V) V {
+    if debug {
+        defer self.entries.guard.read()()
+    }
+    lb, found := self.search(key)
+    if found {
+        return self.entries.data[lb].Value
+    } else {
+        return value
+    }
+}
+
+
+// Insert a new key and value. If this key is already contained within this container it will return the index of the
+// existing entry and false without replacing its value, otherwise it will return the index of the new entry and true.
+// If insertion is successful it will invalidate any previous indices.
+//
+func (self *FlatMap[K, V]) Insert(key K, value V) (int, bool) {
+    if debug {
+        defer self.entries.guard.write()()
+    }
+    lb, found := self.search(key)
+    if found {
+        return lb, false
+    }
+    self.entries.insert(lb, Entry[K, V]{key, value})
+    return lb, true
+}
+
+
+// Searches for this key, and returns the index of its entry or -1 if not found.
+//
+func (self *FlatMap[K, V]) Find(key K) int {
+    if debug {
+        defer self.entries.guard.read()()
+    }
+    lb, found := self.search(key)
+    if found {
+        return lb
+    }
+    return -1
+}
+
+
+// Set the value for this key. If this key is already contained within this container its value is replaced and it will
+// return false, otherwise the new key is inserted and it will return true. If a new key is inserted it will invalidate
+// any previous indices.
+//
+func (self *FlatMap[K, V]) Set(key K, value V) bool {
+    if debug {
+        defer self.entries.guard.update()()
+    }
+    lb, found := self.search(key)
+    if found {
+        self.entries.data[lb].Value = value
+        return false
+    } else {
+        if debug {
+            defer self.entries.guard.write()()
+        }
+        self.entries.insert(lb, Entry[K, V]{key, value})
+        return true
+    }
+}
+
+
+// Modify the value for this key in place by passing a pointer to it to this function and return true, otherwise return
+// false if this key is not in this container. The values do not affect how the entries are sorted so this method will
+// not invalidate previous indices.
+//
+func (self *FlatMap[K, V]) UpdateValue(key K, f func(*V)) bool {
+    if debug {
+        defer self.entries.guard.update()()
+    }
+    lb, found := self.search(key)
+    if found {
+        f(&self.entries.data[lb].Value)
+    }
+    return found
+}
+
+
+// Delete the entry at this index from this container. This method will invalidate any previous indices.
+//
+func (self *FlatMap[K, V]) Erase(index int) {
+    if debug {
+        defer self.entries.guard.write()()
+    }
+    self.erase(index, index + 1)
+}
+
+
+// Delete this key if it exists in this container and return true, otherwise return false if it was not found. This
+// method will invalidate any previous indices.
+//
+func (self *FlatMap[K, V]) Delete(key K) bool {
+    if debug {
+        defer self.entries.guard.write()()
+    }
+    lb, found := self.search(key)
+    if found {
+        self.Erase(lb)
+    }
+    return found
+}
+
+
+// Merge another FlatMap into this one using a function to combine the values of keys that are contained within both
+// containers, the combine function is passed the value from this container followed by the value from the other one. It
+// is also possible to merge FlatMaps that have a different comparison function. The merged entries are written to a
+// single preallocated array. This method will invalidate any previous indices.
+//
+func (self *FlatMap[K, V]) MergeWith(other *FlatMap[K, V], combine func(old, new V) V) {
+    if debug {
+        defer self.entries.guard.write()()
+        defer other.entries.guard.read()()
+    }
+    lhs, rhs := self.entries.data, other.entries.data
+    if !sameCompare(self.less, other.less) && !sortedWith(rhs, self.entries.cmp, false) {
+        rhs = append([]Entry[K, V](nil), rhs...)
+        sort.SliceStable(rhs, func(i, j int) bool { return self.less(rhs[i].Key, rhs[j].Key) })
+    }
+
+    data := make([]Entry[K, V], 0, len(lhs) + len(rhs))
+    push := func(entry Entry[K, V]) {
+        last := len(data) - 1
+        if last >= 0 && !self.less(data[last].Key, entry.Key) {
+            data[last].Value = combine(data[last].Value, entry.Value)
+        } else {
+            data = append(data, entry)
+        }
+    }
+
+    lhsIdx, rhsIdx := 0, 0
+    for lhsIdx < len(lhs) && rhsIdx < len(rhs) {
+        if self.less(rhs[rhsIdx].Key, lhs[lhsIdx].Key) {
+            push(rhs[rhsIdx])
+            rhsIdx++
+        } else {
+            push(lhs[lhsIdx])
+            lhsIdx++
+        }
+    }
+    for ; lhsIdx < len(lhs); lhsIdx++ {
+        push(lhs[lhsIdx])
+    }
+    for ; rhsIdx < len(rhs); rhsIdx++ {
+        push(rhs[rhsIdx])
+    }
+    self.entries.data = data
+}
+
+
+// A FlatMultiMap is a sorted associative container of keys mapped to values using a comparison function for the keys.
+// Unlike a FlatMap, a FlatMultiMap allows equivalent keys to be stored in the same container and the order stability of
+// the entries for these keys is guaranteed.
+//
+type FlatMultiMap[K, V any] struct {
+    mapBase[K, V]
+}
+
+
+// Make an empty FlatMultiMap.
+//
+func MakeFlatMultiMap[K, V any](cmp Compare[K]) FlatMultiMap[K, V] {
+    return FlatMultiMap[K, V]{makeMapBase[K, V](cmp)}
+}
+
+
+// Create a new empty FlatMultiMap.
+//
+func NewFlatMultiMap[K, V any](cmp Compare[K]) *FlatMultiMap[K, V] {
+    return &FlatMultiMap[K, V]{makeMapBase[K, V](cmp)}
+}
+
+
+// Insert a new entry at the upper bound of this key and return the index of the new entry. This method will invalidate
+// any previous indices.
+//
+func (self *FlatMultiMap[K, V]) Insert(key K, value V) int {
+    if debug {
+        defer self.entries.guard.write()()
+    }
+    entry := Entry[K, V]{key, value}
+    ub := self.entries.UpperBound(entry)
+    self.entries.insert(ub, entry)
+    return ub
+}
+
+
+// Searches for this key, and returns the index of the first entry (inclusive) and last entry (exclusive) for this key,
+// or -1, -1 if not found.
+//
+func (self *FlatMultiMap[K, V]) Find(key K) (int, int) {
+    if debug {
+        defer self.entries.guard.read()()
+    }
+    from, upto := self.equalRange(key)
+    if from == upto {
+        return -1, -1
+    }
+    return from, upto
+}
+
+
+// Delete the entries from this index (inclusive) upto this index (exclusive) from this container. If from == -1 this
+// method is a no-op in order that you can pass the indices from Find as arguments. This method will invalidate any
+// previous indices.
+//
+func (self *FlatMultiMap[K, V]) Erase(from, upto int) {
+    if debug {
+        defer self.entries.guard.write()()
+    }
+    if from >= 0 {
+        self.erase(from, upto)
+    }
+}
+
+
+// Returns an iterator that returns a copy of each value for this key in the order they were inserted.
+//
+func (self *FlatMultiMap[K, V]) ValuesFor(key K) iter.Seq[V] {
+    return func(yield func(V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.entries.guard.generation()
+        }
+        from, upto := self.equalRange(key)
+        for i := from; i < upto; i++ {
+            if !yield(self.entries.data[i].Value) {
+                break
+            }
+            if debug {
+                self.entries.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Implements json.Marshaler to encode a FlatMap with string keys as a JSON object. The keys are written in sorted order
+// so the output is deterministic. It will return an error if the keys are not strings. The receiver is a value so that
+// a FlatMap that is a field of a structure is also encoded as an object when the structure is marshalled by value.
+//
+func (self FlatMap[K, V]) MarshalJSON() ([]byte, error) {
+    if reflect.TypeFor[K]().Kind() != reflect.String {
+        return nil, errors.New("flatset: FlatMap keys must be strings to encode as a JSON object")
+    }
+
+    var buf bytes.Buffer
+    buf.WriteByte('{')
+    for i, entry := range self.entries.data {
+        if i > 0 {
+            buf.WriteByte(',')
+        }
+        key, err := json.Marshal(reflect.ValueOf(entry.Key).String())
+        if err != nil {
+            return nil, err
+        }
+        value, err := json.Marshal(entry.Value)
+        if err != nil {
+            return nil, err
+        }
+        buf.Write(key)
+        buf.WriteByte(':')
+        buf.Write(value)
+    }
+    buf.WriteByte('}')
+    return buf.Bytes(), nil
+}
+
+
+// Implements json.Unmarshaler to decode a JSON object into a FlatMap with string keys, replacing any previous entries.
+// The entries are collected and then sorted once using the comparison function, so the FlatMap must have been created
+// with a comparison function before it is decoded. If the comparison function treats several keys as equivalent only
+// the first of them in string order is kept. This method will invalidate any previous indices.
+//
+func (self *FlatMap[K, V]) UnmarshalJSON(data []byte) error {
+    if debug {
+        defer self.entries.guard.write()()
+    }
+    if reflect.TypeFor[K]().Kind() != reflect.String {
+        return errors.New("flatset: FlatMap keys must be strings to decode a JSON object")
+    } else if self.less == nil {
+        return errors.New("flatset: FlatMap has no comparison function to decode a JSON object")
+    }
+
+    var values map[string]V
+    if err := json.Unmarshal(data, &values); err != nil {
+        return err
+    }
+
+    // the keys are visited in a fixed order so that the same key is kept when several are equivalent
+    entries := make([]Entry[K, V], 0, len(values))
+    for _, key := range slices.Sorted(maps.Keys(values)) {
+        entry := Entry[K, V]{Value: values[key]}
+        reflect.ValueOf(&entry.Key).Elem().SetString(key)
+        entries = append(entries, entry)
+    }
+    self.sortEntries(entries)
+    return nil
+}
+
+
+// Private method that replaces the entries of this FlatMap with these entries. The entries are sorted once with a
+// stable sort and the entries with repeated keys are discarded like InitFlatSet, keeping the first of each, so that
+// the keys remain unique even if the comparison function treats keys that are not equal as equivalent.
+//
+func (self *FlatMap[K, V]) sortEntries(entries []Entry[K, V]) {
+    set := FlatSet[Entry[K, V]]{base[Entry[K, V]]{cmp: self.entries.cmp, data: entries}}
+    sort.SliceStable(set.data, func(lhs, rhs int) bool {return set.cmp(set.data[lhs], set.data[rhs])})
+    set.removeDuplicates()
+    self.entries.data = set.data
+    self.entries.shared = false
+}
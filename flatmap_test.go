@@ -0,0 +1,394 @@
+package flatset
+
+import (
+    "encoding/json"
+    "maps"
+    "slices"
+    "strings"
+    "testing"
+)
+
+
+func lessString(lhs, rhs string) bool { return strings.Compare(lhs, rhs) < 0 }
+
+
+// Test the Get/GetOrDefault/Set/Delete methods for the FlatMap.
+//
+func TestMapAccessors(t *testing.T) {
+    fm := NewFlatMap[string, int](lessString)
+
+    for key, expected := range map[string]bool {"b": true, "c": true, "a": true} {
+        if actual := fm.Set(key, len(key)); actual != expected {
+            t.Errorf("FlatMap.Set(%q): expected(%t), actual(%t)", key, expected, actual)
+        }
+    }
+    if fm.Set("b", 20) {
+        t.Errorf("FlatMap.Set(\"b\"): expected(false), actual(true)")
+    }
+
+    for key, expected := range map[string]int {"a": 1, "b": 20, "c": 1} {
+        if actual, found := fm.Get(key); !found || actual != expected {
+            t.Errorf("FlatMap.Get(%q): expected(%d, true), actual(%d, %t)", key, expected, actual, found)
+        }
+    }
+    if actual, found := fm.Get("d"); found || actual != 0 {
+        t.Errorf("FlatMap.Get(\"d\"): expected(0, false), actual(%d, %t)", actual, found)
+    }
+    if actual := fm.GetOrDefault("d", -1); actual != -1 {
+        t.Errorf("FlatMap.GetOrDefault(\"d\"): expected(-1), actual(%d)", actual)
+    }
+
+    if !fm.Delete("a") || fm.Delete("a") || fm.Contains("a") || fm.Size() != 2 {
+        t.Errorf("FlatMap.Delete(\"a\") failed")
+    }
+
+    expectedKeys := []string {"b", "c"}
+    expectedValues := []int {20, 1}
+    i := 0
+    for key, value := range fm.Entries() {
+        if key != expectedKeys[i] || value != expectedValues[i] {
+            t.Errorf("FlatMap.Entries(): expected(%q, %d), actual(%q, %d)", expectedKeys[i], expectedValues[i], key,
+                     value)
+        }
+        i++
+    }
+
+    i = 0
+    for key := range fm.Keys() {
+        if key != expectedKeys[i] {
+            t.Errorf("FlatMap.Keys(): expected(%q), actual(%q)", expectedKeys[i], key)
+        }
+        i++
+    }
+
+    i = 0
+    for value := range fm.Values() {
+        if value != expectedValues[i] {
+            t.Errorf("FlatMap.Values(): expected(%d), actual(%d)", expectedValues[i], value)
+        }
+        i++
+    }
+}
+
+
+// Test the UpdateValue method modifies the value of an existing key in place.
+//
+func TestMapUpdateValue(t *testing.T) {
+    fm := NewFlatMap[string, []int](lessString)
+    fm.Set("a", []int {1})
+
+    if !fm.UpdateValue("a", func(value *[]int) { *value = append(*value, 2) }) {
+        t.Errorf("FlatMap.UpdateValue(\"a\"): expected(true), actual(false)")
+    }
+    if fm.UpdateValue("b", func(value *[]int) { *value = append(*value, 2) }) || fm.Contains("b") {
+        t.Errorf("FlatMap.UpdateValue(\"b\"): expected(false), actual(true)")
+    }
+    if actual, _ := fm.Get("a"); !slices.Equal(actual, []int {1, 2}) {
+        t.Errorf("FlatMap.UpdateValue(\"a\"): expected([1 2]), actual(%v)", actual)
+    }
+}
+
+
+// Test a FlatMap can be created from a golang map and converted back again.
+//
+func TestMapFromToMap(t *testing.T) {
+    original := map[int]string {3: "c", 1: "a", 2: "b"}
+    fm := FromMap(original, lessInt)
+
+    expected := []int {1, 2, 3}
+    i := 0
+    for key, value := range fm.Entries() {
+        if key != expected[i] || value != original[key] {
+            t.Errorf("FromMap(): expected(%d, %q), actual(%d, %q)", expected[i], original[expected[i]], key, value)
+        }
+        i++
+    }
+
+    if actual := ToMap(fm); !maps.Equal(actual, original) {
+        t.Errorf("ToMap(): expected(%v), actual(%v)", original, actual)
+    }
+
+    folded := FromMap(map[string]int {"b": 1, "a": 0, "B": 1}, func(a, b string) bool {
+        return strings.ToLower(a) < strings.ToLower(b)
+    })
+    if folded.Size() != 2 || !strings.EqualFold(folded.entries.data[1].Key, "b") {
+        t.Errorf("FromMap(): expected([a b]) keys, actual(%v)", folded.entries.data)
+    }
+}
+
+
+// Test the MergeWith method combines the values of keys contained in both FlatMaps.
+//
+func TestMapMergeWith(t *testing.T) {
+    lhs := NewFlatMap[string, int](lessString)
+    rhs := NewFlatMap[string, int](func(lhs, rhs string) bool { return lhs > rhs })
+    for key, value := range map[string]int {"a": 1, "c": 3, "d": 4} {
+        lhs.Set(key, value)
+    }
+    for key, value := range map[string]int {"b": 20, "c": 30, "e": 50} {
+        rhs.Set(key, value)
+    }
+
+    lhs.MergeWith(rhs, func(old, new int) int { return old + new })
+    expectedKeys := []string {"a", "b", "c", "d", "e"}
+    expectedValues := []int {1, 20, 33, 4, 50}
+    i := 0
+    for key, value := range lhs.Entries() {
+        if key != expectedKeys[i] || value != expectedValues[i] {
+            t.Errorf("FlatMap.MergeWith(): expected(%q, %d), actual(%q, %d)", expectedKeys[i], expectedValues[i], key,
+                     value)
+        }
+        i++
+    }
+    if i != len(expectedKeys) {
+        t.Errorf("FlatMap.MergeWith(): expected(%d) entries, actual(%d)", len(expectedKeys), i)
+    }
+}
+
+
+// Test the KeySet method can be used with the FlatSet set operations.
+//
+func TestMapKeySet(t *testing.T) {
+    lhs := NewFlatMap[string, int](lessString)
+    rhs := NewFlatMap[string, bool](lessString)
+    for _, key := range []string {"d", "a", "c"} {
+        lhs.Set(key, 0)
+    }
+    for _, key := range []string {"b", "c", "d"} {
+        rhs.Set(key, true)
+    }
+
+    common := lhs.KeySet().Intersection(rhs.Keys())
+    expected := []string {"c", "d"}
+    i := 0
+    for key := range common.All() {
+        if key != expected[i] {
+            t.Errorf("FlatMap.KeySet(): expected(%q), actual(%q)", expected[i], key)
+        }
+        i++
+    }
+    if i != len(expected) {
+        t.Errorf("FlatMap.KeySet(): expected(%d) keys, actual(%d)", len(expected), i)
+    }
+}
+
+
+// Test the ValuesFor method of the FlatMultiMap returns the values for a key in the order they were inserted.
+//
+func TestMultiMapValuesFor(t *testing.T) {
+    fm := NewFlatMultiMap[string, int](lessString)
+    for i, key := range []string {"b", "a", "b", "c", "b"} {
+        fm.Insert(key, i)
+    }
+
+    for key, expected := range map[string][]int {"a": {1}, "b": {0, 2, 4}, "c": {3}, "d": {}} {
+        actual := slices.Collect(fm.ValuesFor(key))
+        if !slices.Equal(actual, expected) {
+            t.Errorf("FlatMultiMap.ValuesFor(%q): expected(%v), actual(%v)", key, expected, actual)
+        }
+    }
+}
+
+
+// Test the EntriesRange and EraseKeyRange methods for the FlatMap and FlatMultiMap.
+//
+func TestMapKeyRange(t *testing.T) {
+    fm := NewFlatMap[int, string](lessInt)
+    fmm := NewFlatMultiMap[int, string](lessInt)
+    for _, key := range []int {10, 20, 30, 40} {
+        fm.Set(key, "")
+        fmm.Insert(key, "")
+        fmm.Insert(key, "")
+    }
+
+    actual := slices.Collect(maps.Keys(maps.Collect(fm.EntriesRange(15, 40))))
+    slices.Sort(actual)
+    if !slices.Equal(actual, []int {20, 30}) {
+        t.Errorf("FlatMap.EntriesRange(15, 40): expected([20 30]), actual(%v)", actual)
+    }
+    for range fm.EntriesRange(40, 15) {
+        t.Errorf("FlatMap.EntriesRange(40, 15): expected no entries")
+    }
+
+    if removed := fm.EraseKeyRange(20, 40); removed != 2 || !slices.Equal(slices.Collect(fm.Keys()), []int {10, 40}) {
+        t.Errorf("FlatMap.EraseKeyRange(20, 40): expected(2, [10 40]), actual(%d, %v)", removed,
+                 slices.Collect(fm.Keys()))
+    }
+    if removed := fmm.EraseKeyRange(0, 30); removed != 4 || !slices.Equal(slices.Collect(fmm.Keys()),
+                                                                           []int {30, 30, 40, 40}) {
+        t.Errorf("FlatMultiMap.EraseKeyRange(0, 30): expected(4, [30 30 40 40]), actual(%d, %v)", removed,
+                 slices.Collect(fmm.Keys()))
+    }
+}
+
+
+// Test a FlatMap with string keys is encoded as a JSON object in sorted order and decoded back again.
+//
+func TestMapJSON(t *testing.T) {
+    fm := NewFlatMap[string, int](lessString)
+    fm.Set("zebra", 3)
+    fm.Set("apple", 1)
+    fm.Set("mango", 2)
+
+    data, err := json.Marshal(fm)
+    expected := `{"apple":1,"mango":2,"zebra":3}`
+    if err != nil || string(data) != expected {
+        t.Errorf("FlatMap.MarshalJSON(): expected(%s), actual(%s, %v)", expected, data, err)
+    }
+
+    decoded := NewFlatMap[string, int](lessString)
+    if err := json.Unmarshal([]byte(`{"b":2,"c":3,"a":1}`), decoded); err != nil {
+        t.Errorf("FlatMap.UnmarshalJSON() failed: %v", err)
+    }
+    expectedKeys := []string {"a", "b", "c"}
+    i := 0
+    for key, value := range decoded.Entries() {
+        if key != expectedKeys[i] || value != i + 1 {
+            t.Errorf("FlatMap.UnmarshalJSON(): expected(%q, %d), actual(%q, %d)", expectedKeys[i], i + 1, key, value)
+        }
+        i++
+    }
+
+    if _, err := json.Marshal(NewFlatMap[int, int](lessInt)); err == nil {
+        t.Errorf("FlatMap.MarshalJSON() expected an error for integer keys")
+    }
+
+    // the map is also encoded as an object when it is a field of a structure that is marshalled by value
+    type embedded struct {
+        Counts FlatMap[string, int]
+    }
+    value := embedded{MakeFlatMap[string, int](lessString)}
+    if err := json.Unmarshal([]byte(`{"Counts":{"b":2,"a":1}}`), &value); err != nil {
+        t.Errorf("FlatMap.UnmarshalJSON() failed: %v", err)
+    }
+    data, err = json.Marshal(value)
+    if expected := `{"Counts":{"a":1,"b":2}}`; err != nil || string(data) != expected {
+        t.Errorf("FlatMap.MarshalJSON(): expected(%s), actual(%s, %v)", expected, data, err)
+    }
+
+    folded := NewFlatMap[string, int](func(a, b string) bool { return strings.ToLower(a) < strings.ToLower(b) })
+    for range 10 {
+        if err := json.Unmarshal([]byte(`{"b":1,"a":0,"B":2}`), folded); err != nil {
+            t.Errorf("FlatMap.UnmarshalJSON() failed: %v", err)
+        }
+        if expected := []Entry[string, int] {{"a", 0}, {"B", 2}}; !slices.Equal(folded.entries.data, expected) {
+            t.Errorf("FlatMap.UnmarshalJSON(): expected(%v), actual(%v)", expected, folded.entries.data)
+        }
+    }
+}
+
+
+// Test the Insert/Find/At/Erase and LowerBound/UpperBound methods for the FlatMap.
+//
+func TestMapIndices(t *testing.T) {
+    fm := NewFlatMap[string, int](lessString)
+    for _, key := range []string {"d", "b", "f"} {
+        if _, inserted := fm.Insert(key, len(key)); !inserted {
+            t.Errorf("FlatMap.Insert(%q): expected(true), actual(false)", key)
+        }
+    }
+    if index, inserted := fm.Insert("b", 20); index != 0 || inserted {
+        t.Errorf("FlatMap.Insert(\"b\"): expected(0, false), actual(%d, %t)", index, inserted)
+    }
+    if key, value := fm.At(0); key != "b" || value != 1 {
+        t.Errorf("FlatMap.At(0): expected(\"b\", 1), actual(%q, %d)", key, value)
+    }
+
+    for key, expected := range map[string]int {"b": 0, "d": 1, "f": 2, "a": -1, "e": -1} {
+        if actual := fm.Find(key); actual != expected {
+            t.Errorf("FlatMap.Find(%q): expected(%d), actual(%d)", key, expected, actual)
+        }
+    }
+    for key, expected := range map[string][2]int {"a": {0, 0}, "b": {0, 1}, "c": {1, 1}, "f": {2, 3}, "g": {3, 3}} {
+        if lb, ub := fm.LowerBound(key), fm.UpperBound(key); lb != expected[0] || ub != expected[1] {
+            t.Errorf("FlatMap.LowerBound/UpperBound(%q): expected(%v), actual([%d %d])", key, expected, lb, ub)
+        }
+    }
+
+    fm.Erase(fm.Find("d"))
+    if expected := []string {"b", "f"}; !slices.Equal(slices.Collect(fm.Keys()), expected) {
+        t.Errorf("FlatMap.Erase(1): expected(%v), actual(%v)", expected, slices.Collect(fm.Keys()))
+    }
+}
+
+
+// Test the Find and Erase methods for the FlatMultiMap return and remove the range of entries for a key.
+//
+func TestMultiMapFind(t *testing.T) {
+    fm := NewFlatMultiMap[string, int](lessString)
+    for i, key := range []string {"b", "a", "b", "c", "b"} {
+        fm.Insert(key, i)
+    }
+
+    for key, expected := range map[string][2]int {"a": {0, 1}, "b": {1, 4}, "c": {4, 5}, "d": {-1, -1}} {
+        if from, upto := fm.Find(key); from != expected[0] || upto != expected[1] {
+            t.Errorf("FlatMultiMap.Find(%q): expected(%v), actual([%d %d])", key, expected, from, upto)
+        }
+    }
+
+    fm.Erase(fm.Find("d"))
+    fm.Erase(fm.Find("b"))
+    if expected := []int {1, 3}; !slices.Equal(slices.Collect(fm.Values()), expected) {
+        t.Errorf("FlatMultiMap.Erase(1, 4): expected(%v), actual(%v)", expected, slices.Collect(fm.Values()))
+    }
+}
+
+
+// Test Clear releases the array of a map and ClearRetainingCapacity zeroes the entries but keeps the array.
+//
+func TestMapClear(t *testing.T) {
+    fm := FromMap(map[string]*int {"a": new(int), "b": new(int), "c": new(int)}, lessString)
+    capacity := fm.entries.Cap()
+    fm.ClearRetainingCapacity()
+    if fm.Size() != 0 || fm.entries.Cap() != capacity {
+        t.Errorf("FlatMap.ClearRetainingCapacity(): expected(0, %d), actual(%d, %d)", capacity, fm.Size(),
+                 fm.entries.Cap())
+    }
+    for _, entry := range fm.entries.data[:3] {
+        if entry.Key != "" || entry.Value != nil {
+            t.Errorf("FlatMap.ClearRetainingCapacity(): expected(zeroed entries), actual(%v)", entry)
+        }
+    }
+
+    mm := NewFlatMultiMap[string, int](lessString)
+    for i, key := range []string {"b", "a", "b"} {
+        mm.Insert(key, i)
+    }
+    mm.Clear()
+    if mm.Size() != 0 || mm.entries.Cap() != 0 {
+        t.Errorf("FlatMultiMap.Clear(): expected(0, 0), actual(%d, %d)", mm.Size(), mm.entries.Cap())
+    }
+    mm.Insert("c", 3)
+    if expected := []int {3}; !slices.Equal(slices.Collect(mm.Values()), expected) {
+        t.Errorf("FlatMultiMap.Insert(c): expected(%v), actual(%v)", expected, slices.Collect(mm.Values()))
+    }
+}
+
+
+// Test the methods that delete entries zero the entries left after the end of the array, so that the garbage collector
+// can reclaim their keys and values.
+//
+func TestMapEraseZeroes(t *testing.T) {
+    zeroed := func(name string, data []Entry[string, *int]) {
+        for _, entry := range data[len(data):cap(data)] {
+            if entry.Key != "" || entry.Value != nil {
+                t.Errorf("%s: expected(zeroed entries), actual(%v)", name, entry)
+            }
+        }
+    }
+    fm := FromMap(map[string]*int {"a": new(int), "b": new(int), "c": new(int), "d": new(int)}, lessString)
+    fm.Delete("a")
+    zeroed("FlatMap.Delete(a)", fm.entries.data)
+    fm.EraseKeyRange("b", "d")
+    zeroed("FlatMap.EraseKeyRange(b, d)", fm.entries.data)
+
+    mm := NewFlatMultiMap[string, *int](lessString)
+    for _, key := range []string {"a", "b", "b", "c"} {
+        mm.Insert(key, new(int))
+    }
+    mm.Erase(mm.Find("b"))
+    zeroed("FlatMultiMap.Erase(1, 3)", mm.entries.data)
+    if expected := []string {"a", "c"}; !slices.Equal(slices.Collect(mm.Keys()), expected) {
+        t.Errorf("FlatMultiMap.Erase(1, 3): expected(%v), actual(%v)", expected, slices.Collect(mm.Keys()))
+    }
+}
@@ -16,9 +16,16 @@ package flatset
 
 
 import (
+    "cmp"
+    "encoding/json"
+    "errors"
+    "fmt"
     "iter"
+    "math/rand/v2"
     "reflect"
+    "slices"
     "sort"
+    "unsafe"
 )
 
 // This is the interface for the comparison function that is passed to the FlatSet and FlatMultiSet which defines how
@@ -31,22 +38,99 @@ type Compare[V any] func(a, b V) bool
 // This is base structure that contains the data for both the FlatSet and FlatMultiSet implementations.
 //
 type base[V any] struct {
-    cmp Compare[V]  // comparison function
-    data [] V       // data stored in a array of continuous memory
+    guard guard             // detects unsynchronized use in debug builds
+    cmp Compare[V]          // comparison function
+    eq func(a, b V) bool    // optional equality function for equivalent values
+    onGrow func(int, int)   // optional function called when the array is reallocated
+    strict bool             // spot-check the order of the values after each modification
+    shared bool             // the array is shared with a snapshot and must be copied before it is modified
+    data [] V               // data stored in a array of continuous memory
 }
 
 
-// Shared private method to efficiently insert into an array.
+// Shared private method to efficiently insert into an array. The array is grown at most once and the values after the
+// insertion point are shifted with a single copy.
 //
 func (self *base[V]) insert(ub int, value V) {
-    if ub == 0 {
-        self.data = append([]V{value}, self.data...)
-	} else if ub == len(self.data) {
-	    self.data = append(self.data, []V{value}...)
-	} else {
-     	self.data = append(self.data[:ub], self.data[ub - 1:]...)
-    	self.data[ub] = value
-	}
+    size := len(self.data)
+    self.grow(1)
+    self.unshare()
+    self.data = self.data[:size + 1]
+    copy(self.data[ub + 1:], self.data[ub:size])
+    self.data[ub] = value
+    self.check(ub)
+    slowShift(size, size - ub)
+}
+
+
+// Shared private method to efficiently insert a block of sorted values into an array at the same position. The array is
+// grown at most once and the values after the insertion point are shifted with a single copy.
+//
+func (self *base[V]) insertBlock(at int, values []V) {
+    size, n := len(self.data), len(values)
+    self.grow(n)
+    self.unshare()
+    self.data = self.data[:size + n]
+    copy(self.data[at + n:], self.data[at:size])
+    copy(self.data[at:], values)
+    self.check(at)
+    self.check(at + n)
+}
+
+
+// Shared private method to ensure the array has the capacity to append another n values without reallocating. If the
+// array is reallocated it is no longer shared with a snapshot.
+//
+func (self *base[V]) grow(n int) {
+    capacity := cap(self.data)
+    self.data = slices.Grow(self.data, n)
+    if cap(self.data) != capacity {
+        self.shared = false
+    }
+    self.grown(capacity)
+}
+
+
+// Shared private method that calls the growth function if the capacity of the array has changed from this capacity.
+//
+func (self *base[V]) grown(capacity int) {
+    if self.onGrow != nil && cap(self.data) != capacity {
+        self.onGrow(capacity, cap(self.data))
+    }
+}
+
+
+// Shared private method that copies the array if it is shared with a snapshot, so that the values can be modified in
+// place without affecting the snapshot. The copy has the same capacity, so it can be called after the array has been
+// grown. It must be called before any value within the length of the snapshot is written.
+//
+func (self *base[V]) unshare() {
+    if self.shared {
+        data := make([]V, len(self.data), cap(self.data))
+        copy(data, self.data)
+        self.data = data
+        self.shared = false
+    }
+}
+
+
+// Shared private method that, in strict mode, checks the value at this index is ordered after the previous value and
+// before the next value, and checks another pair of neighbouring values chosen at random. It panics if a pair is out of
+// order, which is caused by an inconsistent comparison function or by modifying the values through pointers.
+//
+func (self *base[V]) check(index int) {
+    if !self.strict || len(self.data) < 2 {
+        return
+    }
+    pair := func(i int) {
+        if i > 0 && i < len(self.data) && self.cmp(self.data[i], self.data[i - 1]) {
+            panic(fmt.Sprintf("flatset: strict mode found values out of order at index %d of %d: %v is before %v",
+                i, len(self.data), self.data[i - 1], self.data[i]))
+        }
+    }
+    pair(index)
+    pair(index + 1)
+    pair(1 + rand.IntN(len(self.data) - 1))
 }
 
 
@@ -75,6 +159,10 @@ func (self *base[V]) traverse(values iter.Seq[V], cmp Compare[V]) iter.Seq2[int,
     idx := (low + high) / 2
 
     return func(yield func(int, V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
         for value := range values {
             size := len(self.data)
             if size > 0 {
@@ -99,30 +187,99 @@ func (self *base[V]) traverse(values iter.Seq[V], cmp Compare[V]) iter.Seq2[int,
                 }
 
                 idx = self.bounds(value, low, high, cmp)
-                if !yield(idx, value) {
-                    break
-                }
+            }
+            if !yield(idx, value) {
+                break
+            }
+            if debug {
+                self.guard.iterated(generation)
             }
         }
 	}
 }
 
 
+// Shared private method to insert values from an iterator at their upper bound. Consecutive values that belong in the
+// same gap between the existing values are buffered and inserted as a block, so that sorted or clustered values are
+// shifted with one copy per block rather than one copy per value. If unique is true then values equivalent to an
+// existing or previously inserted value are discarded.
+//
+func (self *base[V]) update(values iter.Seq[V], unique bool) {
+    upper := func(lhs, rhs V) bool { return !self.cmp(rhs, lhs) }
+    var block []V
+    at := 0
+
+    for ub, value := range self.traverse(values, upper) {
+        if len(block) > 0 {
+            last := block[len(block) - 1]
+            if ub == at && !self.cmp(value, last) {
+                if !unique || self.cmp(last, value) {
+                    block = append(block, value)
+                }
+                continue
+            }
+
+            self.insertBlock(at, block)
+            if ub > at {
+                ub += len(block)
+            } else if ub == at {
+                ub = self.bounds(value, at, at + len(block) - 1, upper)
+            }
+            block = block[:0]
+        }
+
+        if unique && ub > 0 && !self.cmp(self.data[ub - 1], value) {
+            continue
+        }
+        at = ub
+        block = append(block, value)
+    }
+
+    if len(block) > 0 {
+        self.insertBlock(at, block)
+    }
+}
+
+
+// Shared private method that collects the values from an iterator into a new array, and returns true if the values are
+// already sorted using the comparison function.
+//
+func (self *base[V]) collect(values iter.Seq[V]) ([]V, bool) {
+    var out []V
+    sorted := true
+    for value := range values {
+        if sorted && len(out) > 0 && self.cmp(value, out[len(out) - 1]) {
+            sorted = false
+        }
+        out = append(out, value)
+    }
+    return out, sorted
+}
+
+
 // Shared private method to append another flatset to this one that is sorted using the same comparison function.
+// Equivalent values from this flatset are ordered before the values from the other one. If the array already has the
+// capacity for both flatsets they are merged in place from the end, otherwise they are merged into a new array.
 //
 func (self *base[V]) mergeSorted(other *base[V]) {
-    lhsIdx, rhsIdx, mergedIdx := 0, 0, 0
     lhsSz, rhsSz := len(self.data), len(other.data)
     mergedSz := lhsSz + rhsSz
+    if mergedSz <= cap(self.data) && (lhsSz == 0 || rhsSz == 0 || &self.data[0] != &other.data[0]) {
+        self.mergeInPlace(other)
+        self.check(lhsSz)
+        return
+    }
+
+    lhsIdx, rhsIdx, mergedIdx := 0, 0, 0
     data := make([]V, mergedSz)
 
     for lhsIdx < lhsSz && rhsIdx < rhsSz {
-        if self.cmp(self.data[lhsIdx], other.data[rhsIdx]) {
-            data[mergedIdx] = self.data[lhsIdx]
-            lhsIdx++
-        } else {
+        if self.cmp(other.data[rhsIdx], self.data[lhsIdx]) {
             data[mergedIdx] = other.data[rhsIdx]
             rhsIdx++
+        } else {
+            data[mergedIdx] = self.data[lhsIdx]
+            lhsIdx++
         }
         mergedIdx++
     }
@@ -132,293 +289,1653 @@ func (self *base[V]) mergeSorted(other *base[V]) {
     } else {
         copy(data[mergedIdx:mergedSz], other.data[rhsIdx:rhsSz])
     }
+    capacity := cap(self.data)
     self.data = data
+    self.shared = false
+    self.grown(capacity)
+    self.check(lhsSz)
 }
 
-// Efficiently empty the set keeping any previously allocated memory for future insertions.
-//
-func (self *base[V]) Clear() {
-    self.data = self.data[:0]
-}
 
-// Returns a copy of the value at the given index.
+// Shared private method to merge another flatset into the spare capacity of this one, starting with the greatest values
+// so that none of the values in this flatset are overwritten before they are moved.
 //
-func (self *base[V]) At(index int) V {
-    return self.data[index]
+func (self *base[V]) mergeInPlace(other *base[V]) {
+    self.unshare()
+    lhsIdx, rhsIdx := len(self.data) - 1, len(other.data) - 1
+    self.data = self.data[:len(self.data) + len(other.data)]
+
+    for mergedIdx := len(self.data) - 1; rhsIdx >= 0; mergedIdx-- {
+        if lhsIdx >= 0 && self.cmp(other.data[rhsIdx], self.data[lhsIdx]) {
+            self.data[mergedIdx] = self.data[lhsIdx]
+            lhsIdx--
+        } else {
+            self.data[mergedIdx] = other.data[rhsIdx]
+            rhsIdx--
+        }
+    }
 }
 
 
-// Returns the number of values stored in this container.
+// Shared private method that counts the values that are equivalent in this container and another container sorted with
+// the same comparison function. Both arrays are walked once without allocating any memory.
 //
-func (self *base[V]) Size() int {
-    return len(self.data)
-}
-
+func (self *base[V]) countCommon(other *base[V]) int {
+    lhsIdx, rhsIdx, count := 0, 0, 0
+    lhsSz, rhsSz := len(self.data), len(other.data)
 
-// Returns an iterator that returns a copy of each value in order.
-//
-func (self *base[V]) All() iter.Seq[V] {
-    return func(yield func(V) bool) {
-        for i := 0; i < len(self.data); i++ {
-            if !yield(self.data[i]) {
-                break
-            }
+    for lhsIdx < lhsSz && rhsIdx < rhsSz {
+        if self.cmp(self.data[lhsIdx], other.data[rhsIdx]) {
+            lhsIdx++
+        } else if self.cmp(other.data[rhsIdx], self.data[lhsIdx]) {
+            rhsIdx++
+        } else {
+            lhsIdx++
+            rhsIdx++
+            count++
         }
     }
+    return count
 }
 
 
-// Returns an iterator that iterates in reverse order returning a copy of each value.
+// Shared private method that returns true if every value in this container is equivalent to a value in another
+// container sorted with the same comparison function. The walk stops at the first value that is missing.
 //
-func (self *base[V]) Backward() iter.Seq[V] {
-    return func(yield func(V) bool) {
-        for i := len(self.data) - 1; i >= 0; i-- {
-            if !yield(self.data[i]) {
-                break
-            }
+func (self *base[V]) subsetOf(other *base[V]) bool {
+    lhsIdx, rhsIdx := 0, 0
+    lhsSz, rhsSz := len(self.data), len(other.data)
+    if lhsSz > rhsSz {
+        return false
+    }
+
+    for lhsIdx < lhsSz && rhsIdx < rhsSz {
+        if self.cmp(self.data[lhsIdx], other.data[rhsIdx]) {
+            return false
+        } else if self.cmp(other.data[rhsIdx], self.data[lhsIdx]) {
+            rhsIdx++
+        } else {
+            lhsIdx++
+            rhsIdx++
         }
     }
+    return lhsIdx == lhsSz
 }
 
-// Returns true if this container has this value or false if it does not.
+// Empty this container and release its array, so that the memory of the array and of any values it refers to can be
+// reclaimed by the garbage collector. Values inserted later are stored in a new array.
 //
-func (self *base[V]) Contains(value V) bool {
-    lb := self.LowerBound(value)
-	if lb < len(self.data) && !self.cmp(value, self.data[lb]) {
-	    return true
-	} else {
-    	return false
+func (self *base[V]) Clear() {
+    if debug {
+        defer self.guard.write()()
     }
+    self.data = nil
+    self.shared = false
 }
 
 
-// This method takes an iterator and will returns true if any of these equivalent values are contained within this
-// container.
+// Efficiently empty this container keeping the array for future insertions, so that it can be reused without
+// reallocating. The values are zeroed so that the garbage collector can reclaim any values they refer to, such as the
+// structures of a container of pointers.
 //
-func (self *base[V]) HasAny(values iter.Seq[V]) bool {
-    size := len(self.data)
-    for lb, value := range self.traverse(values, self.cmp) {
-        if lb < size && !self.cmp(value, self.data[lb]) {
-		    return true
-		}
+func (self *base[V]) ClearRetainingCapacity() {
+    if debug {
+        defer self.guard.write()()
     }
-    return false
+    self.unshare()
+    clear(self.data)
+    self.data = self.data[:0]
 }
 
 
-// This method takes an iterator and returns true if this container is a superset of these values.
+// Returns the sorted array of values and empties this container without copying them, transferring the ownership of
+// the array to the caller. The container does not refer to the array afterwards, so the caller can modify it freely and
+// any values inserted into this container later will be stored in a new array.
 //
-func (self *base[V]) HasAll(values iter.Seq[V]) bool {
-   size := len(self.data)
-   for lb, value := range self.traverse(values, self.cmp) {
-        if lb >= size || self.cmp(value, self.data[lb]) {
-		    return false
-		}
-   }
-   return true
+func (self *base[V]) Release() []V {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.unshare()
+    data := self.data
+    self.data = nil
+    return data
 }
 
-
-// Returns an index to the first value in the range where the comparison is not less than.
+// Shared private method that compacts the array in a single forward pass, keeping the values at the indices where the
+// keep function returns true. The keep function is called once for each index in order. The slots that are no longer
+// used are zeroed and the number of values that were removed is returned.
 //
-func (self *base[V]) LowerBound(value V) int {
-    return self.bounds(value, 0, len(self.data) - 1, self.cmp)
+func (self *base[V]) compact(keep func(index int) bool) int {
+    self.unshare()
+    size := len(self.data)
+    upto := 0
+    for i := 0; i < size; i++ {
+        if keep(i) {
+            self.data[upto] = self.data[i]
+            upto++
+        }
+    }
+    clear(self.data[upto:size])
+    self.data = self.data[:upto]
+    self.check(0)
+    return size - upto
 }
 
 
-// Returns an index to the first value in the range where the comparison is greater.
+// Shared private method that takes an iterator and returns a mask of the indices of values in this container that are
+// equivalent to any of these values, and the number of indices that were matched.
 //
-func (self *base[V]) UpperBound(value V) int {
-    return self.bounds(value, 0, len(self.data) - 1, func(lhs, rhs V) bool { return !self.cmp(rhs, lhs) })
+func (self *base[V]) matches(values iter.Seq[V]) ([]bool, int) {
+    size := len(self.data)
+    mask := make([]bool, size)
+    count := 0
+    for lb, value := range self.traverse(values, self.cmp) {
+        if lb < size && !self.cmp(value, self.data[lb]) && !mask[lb] {
+            mask[lb] = true
+            count++
+        }
+    }
+    return mask, count
 }
 
 
-// A FlatSet is a sorted associative container of unique values using a comparison function.
+// Shared private method that removes the values from this index (inclusive) upto this index (exclusive) and returns
+// them in a new array. The values are copied once into the new array and the values after them are moved once.
 //
-type FlatSet[V any] struct {
-   base[V]
+func (self *base[V]) drain(from, upto int) []V {
+    self.unshare()
+    out := slices.Clone(self.data[from:upto])
+    size := len(self.data)
+    self.data = append(self.data[:from], self.data[upto:]...)
+    clear(self.data[len(self.data):size])
+    self.check(from)
+    return out
 }
 
 
-// Private method to remove subsequent keys that are repeated.
+// Delete the values at these indices from this container. The indices do not need to be in order, and indices that are
+// repeated or out of range (such as -1 from Find) are ignored. The remaining values are moved at most once so this is
+// much more efficient than erasing each index individually. This method will invalidate any previous indices.
 //
-func (self *FlatSet[V]) removeDuplicates() {
-    size := len(self.data)
-    if size > 1 {
-        upto := 1
-        for next := 1; next < size; next++ {
-            if !self.cmp(self.data[next - 1], self.data[next]) {
-                continue
-            }
-            self.data[upto] = self.data[next]
-            upto++
-        }
-        self.data = append([]V(nil), self.data[:upto]...)
+func (self *base[V]) EraseIndices(indices []int) {
+    if debug {
+        defer self.guard.write()()
     }
+    sorted := slices.Clone(indices)
+    slices.Sort(sorted)
+
+    next := 0
+    self.compact(func(index int) bool {
+        for next < len(sorted) && sorted[next] < index {
+            next++
+        }
+        return next == len(sorted) || sorted[next] != index
+    })
 }
 
 
-// Make an empty FlatSet.
+// Delete the values for which the predicate returns true from this container, such as the values that have expired.
+// The predicate is called once for each value in order and the remaining values are moved at most once, so this is
+// much more efficient than erasing each value individually. Returns the number of values that were removed. This method
+// will invalidate any previous indices.
 //
-func MakeFlatSet[V any](cmp Compare[V]) FlatSet[V] {
-    return FlatSet[V]{base[V]{cmp: cmp}}
+func (self *base[V]) RemoveIf(pred func(V) bool) int {
+    if debug {
+        defer self.guard.write()()
+    }
+    return self.compact(func(index int) bool {return !pred(self.data[index])})
 }
 
 
-// Create a new empty FlatSet.
+// Keep only the values for which the predicate returns true, which is the complement of RemoveIf, such as to prune
+// the sessions that have expired. Returns the number of values that were removed. This method will invalidate any
+// previous indices.
 //
-func NewFlatSet[V any](cmp Compare[V]) *FlatSet[V] {
-    return &FlatSet[V]{base[V]{cmp: cmp}}
+func (self *base[V]) RetainIf(pred func(V) bool) int {
+    if debug {
+        defer self.guard.write()()
+    }
+    return self.compact(func(index int) bool {return pred(self.data[index])})
 }
 
 
-// Create a new FlatSet and initialize it with some values. Values that are repeated will be discarded.
+// Remove the values that are outside the range from the low value (inclusive) upto the high value (exclusive), such
+// as to keep only the keys of a sliding window. The range is found with two binary searches and the remaining values
+// are moved once to the start of the array. Returns the number of values that were removed. This method will
+// invalidate any previous indices.
 //
-func InitFlatSet[V any](values []V, cmp Compare[V]) *FlatSet[V] {
-    self := &FlatSet[V]{base[V]{cmp: cmp}}
-    self.data = append([]V(nil), values...)
-    sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
-    self.removeDuplicates()
-    return self
+func (self *base[V]) TrimToRange(low, high V) int {
+    if debug {
+        defer self.guard.write()()
+    }
+    size := len(self.data)
+    from := self.LowerBound(low)
+    upto := max(from, self.LowerBound(high))
+    self.unshare()
+    n := copy(self.data, self.data[from:upto])
+    clear(self.data[n:size])
+    self.data = self.data[:n]
+    return size - n
 }
 
 
-// Searches for a value within this container, and returns the index for the location of the value or -1 if not found.
+// Returns a copy of the value at the given index.
 //
-func (self *FlatSet[V]) Find(value V) int {
-    lb := self.LowerBound(value)
-	if lb < len(self.data) && !self.cmp(value, self.data[lb]) {
-	    return lb
-	} else {
-    	return -1
+func (self *base[V]) At(index int) V {
+    if debug {
+        defer self.guard.read()()
     }
+    return self.data[index]
 }
 
 
-// Insert a new value. If this value is already contained within this container it will return the index of the existing
-// value and false, otherwise it will return the index of the new value and true. If insertion is successful it will
-// invalidate any previous indices.
+// Returns a pointer to the value at the given index, so that the fields of a large structure that are not compared by
+// the comparison function can be read or modified without copying it. The fields that are compared must not be modified
+// through the pointer. The pointer is only valid until this container is modified, as the values can then be moved.
 //
-func (self *FlatSet[V]) Insert(value V) (int, bool) {
-    ub := self.UpperBound(value)
-    if ub > 0 && !self.cmp(self.data[ub - 1], value) {
-        return ub - 1, false
-    } else {
-    	self.insert(ub, value)
-    	return ub, true
-	}
+func (self *base[V]) AtRef(index int) *V {
+    self.unshare()
+    return &self.data[index]
 }
 
 
-// Delete the value at this index from this container.
+// Returns a copy of the value at the given index and true, or the zero value and false if the index is out of range,
+// such as a stale index or the -1 returned by Find when the value was not found.
 //
-func (self *FlatSet[V]) Erase(index int) {
-    self.data = append(self.data[:index], self.data[index+1:]...)
+func (self *base[V]) TryAt(index int) (V, bool) {
+    if debug {
+        defer self.guard.read()()
+    }
+    if index < 0 || index >= len(self.data) {
+        var zero V
+        return zero, false
+    }
+    return self.data[index], true
 }
 
-// Remove this value if it exists in this container and return true, otherwise return false if it was not found.
+
+// Returns a copy of the smallest value in this container and true, or the zero value and false if this container is
+// empty.
 //
-func (self *FlatSet[V]) Remove(value V) bool {
-    index := self.Find(value)
-    if index != -1 {
-        self.Erase(index)
-        return true
-    } else {
-        return false
+func (self *base[V]) Front() (V, bool) {
+    if debug {
+        defer self.guard.read()()
+    }
+    if len(self.data) == 0 {
+        var zero V
+        return zero, false
     }
+    return self.data[0], true
 }
 
-// Try to replace the value at this index. If the previous value was replaced return true, otherwise return false if
-// the new value would result in data being out of sequence. This method allow you to quickly modify a value if you know
-// its index, without the need to erase the previous value and insert the new one. This method will not invalidate
-// previous indices.
+
+// Returns a copy of the largest value in this container and true, or the zero value and false if this container is
+// empty. If there are equivalent values the last one inserted is returned.
 //
-func (self *FlatSet[V]) Replace(index int, value V) bool {
-    size := len(self.data)
-    if index < size {
-        if (index > 0 && !self.cmp(self.data[index - 1], value)) ||
-            (index < size - 1 && !self.cmp(value, self.data[index + 1])) {
-            return false
-        }
-        self.data[index] = value
-        return true
+func (self *base[V]) Back() (V, bool) {
+    if debug {
+        defer self.guard.read()()
     }
-    return false
+    if len(self.data) == 0 {
+        var zero V
+        return zero, false
+    }
+    return self.data[len(self.data) - 1], true
 }
 
-// Append another FlatSet into this one. It is also possible to merge FlatSets that have a different comparison
-// function. If a value already exists in this container the new value from the other FlatSet will be discarded to
-// maintain order stability. This method is similar but more efficient than Update because it is able to preallocate
-// the array. This method updates this container so it will invalidate any previous indices.
+
+// Remove the smallest value from this container and return it with true, or return the zero value and false if this
+// container is empty. The remaining values are moved down by one, so a queue that is drained from the front should
+// use PopBack with the reverse comparison function instead. This method will invalidate any previous indices.
 //
-func (self *FlatSet[V]) Merge(other *FlatSet[V]) {
-    if reflect.ValueOf(self.cmp).Pointer() != reflect.ValueOf(other.cmp).Pointer() {
-        other = InitFlatSet[V](other.data, self.cmp)
+func (self *base[V]) PopFront() (V, bool) {
+    if debug {
+        defer self.guard.write()()
     }
-    self.mergeSorted(&other.base)
-    self.removeDuplicates()
+    size := len(self.data)
+    if size == 0 {
+        var zero V
+        return zero, false
+    }
+    self.unshare()
+    value := self.data[0]
+    self.data = append(self.data[:0], self.data[1:]...)
+    clear(self.data[size - 1:size])
+    self.check(0)
+    return value, true
 }
 
 
-// Insert these values into this container. This method is more flexible but less efficient than Merge because it takes
-// a generic iterator of values. If a value already exists in this container the new value will be discarded to maintain
-// order stability. This method updates this container so it will invalidate any previous indices.
+// Remove the largest value from this container and return it with true, or return the zero value and false if this
+// container is empty. No other values are moved. If there are equivalent values the last one inserted is removed. This
+// method will invalidate any previous indices.
 //
-func (self *FlatSet[V]) Update(values iter.Seq[V]) {
-    for ub, value := range self.traverse(values, func(lhs, rhs V) bool { return !self.cmp(rhs, lhs) }) {
-        if len(self.data) == 0 || (ub > 0 && self.cmp(self.data[ub - 1], value))  {
-            self.insert(ub, value)
-        }
+func (self *base[V]) PopBack() (V, bool) {
+    if debug {
+        defer self.guard.write()()
     }
+    size := len(self.data)
+    if size == 0 {
+        var zero V
+        return zero, false
+    }
+    self.unshare()
+    value := self.data[size - 1]
+    clear(self.data[size - 1:size])
+    self.data = self.data[:size - 1]
+    return value, true
+}
+
+
+// Returns the number of values stored in this container.
+//
+func (self *base[V]) Size() int {
+    return len(self.data)
+}
+
+
+// Returns the number of values this container can store before the array must be reallocated.
+//
+func (self *base[V]) Cap() int {
+    return cap(self.data)
+}
+
+
+// Ensure this container has the capacity to store at least n values without reallocating the array, so that the array
+// is allocated once before inserting a known number of values. It does nothing if the capacity is already large enough.
+// This method will not invalidate previous indices.
+//
+func (self *base[V]) Reserve(n int) {
+    if debug {
+        defer self.guard.write()()
+    }
+    if n > len(self.data) {
+        self.grow(n - len(self.data))
+    }
+}
+
+
+// Returns an iterator that returns a copy of each value in order.
+//
+func (self *base[V]) All() iter.Seq[V] {
+    return func(yield func(V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
+        for i := 0; i < len(self.data); i++ {
+            if !yield(self.data[i]) {
+                break
+            }
+            if debug {
+                self.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Returns an iterator that iterates in reverse order returning a copy of each value.
+//
+func (self *base[V]) Backward() iter.Seq[V] {
+    return func(yield func(V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
+        for i := len(self.data) - 1; i >= 0; i-- {
+            if !yield(self.data[i]) {
+                break
+            }
+            if debug {
+                self.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Returns an iterator that returns the index and a copy of each value in order. The indices can be passed to methods
+// such as At or Replace after the iteration, but they are invalidated once this container is modified.
+//
+func (self *base[V]) Enumerate() iter.Seq2[int, V] {
+    return func(yield func(int, V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
+        for i := 0; i < len(self.data); i++ {
+            if !yield(i, self.data[i]) {
+                break
+            }
+            if debug {
+                self.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Returns an iterator that returns a copy of each value in order from lo (inclusive) upto hi (exclusive). The range is
+// found with two binary searches when the iteration starts, so only the values in the range are visited.
+//
+func (self *base[V]) Range(lo, hi V) iter.Seq[V] {
+    return func(yield func(V) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
+        from := self.LowerBound(lo)
+        upto := max(from, self.LowerBound(hi))
+        for i := from; i < upto; i++ {
+            if !yield(self.data[i]) {
+                break
+            }
+            if debug {
+                self.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+// Shared private method that returns the index of a value that is equal to this value using the equality function, or
+// -1 if there is no equal value. Only the equivalent values are compared, starting from their lower bound.
+//
+func (self *base[V]) findEqual(value V) int {
+    for index := self.LowerBound(value); index < len(self.data) && !self.cmp(value, self.data[index]); index++ {
+        if self.eq(self.data[index], value) {
+            return index
+        }
+    }
+    return -1
+}
+
+
+// Set an equality function that is used by Contains and Find, and to decide which values are duplicates of each other
+// in a FlatSet. By default values are equal if neither is less than the other using the comparison function, but the
+// equality function can be stricter for comparison functions that only compare a key, so that equivalent values with
+// a different payload are not discarded as duplicates. The equality function is only called for equivalent values and
+// should be set before any values are inserted. Set operations such as Intersection still use the comparison function.
+//
+func (self *base[V]) SetEquals(eq func(a, b V) bool) {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.eq = eq
+}
+
+
+// Set a function that is called with the old and new capacity whenever a method that inserts values reallocates the
+// array of this container, or nil to stop calling it. Latency sensitive code can use it to detect the stalls caused by
+// copying a large array, and reserve enough capacity in advance. The function is not inherited by the containers
+// returned by methods such as Union.
+//
+func (self *base[V]) SetGrowthHook(fn func(oldCap, newCap int)) {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.onGrow = fn
+}
+
+
+// Enable or disable strict mode. In strict mode every method that modifies this container checks the order of the
+// values next to the values it modified, and of another pair of neighbouring values chosen at random, and panics if
+// they are out of order. Each check is constant time, so an inconsistent comparison function or a value modified
+// through a pointer is caught close to the point of corruption rather than by a failed search much later. The mode is
+// not inherited by the containers returned by methods such as Union.
+//
+func (self *base[V]) SetStrict(enabled bool) {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.strict = enabled
+}
+
+
+// Returns true if this container has this value or false if it does not.
+//
+func (self *base[V]) Contains(value V) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+    if self.eq != nil {
+        return self.findEqual(value) != -1
+    }
+    _, found := self.Search(value)
+    return found
+}
+
+
+// This method takes an iterator and will returns true if any of these equivalent values are contained within this
+// container.
+//
+func (self *base[V]) HasAny(values iter.Seq[V]) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+    size := len(self.data)
+    for lb, value := range self.traverse(values, self.cmp) {
+        if lb < size && !self.cmp(value, self.data[lb]) {
+		    return true
+		}
+    }
+    return false
+}
+
+
+// This method takes an iterator and returns true if none of these values are equivalent to a value contained within
+// this container, which is the inverse of HasAny. It returns false as soon as an equivalent value is found.
+//
+func (self *base[V]) Disjoint(values iter.Seq[V]) bool {
+    return !self.HasAny(values)
+}
+
+
+// This method takes an iterator and returns true if this container is a superset of these values.
+//
+func (self *base[V]) HasAll(values iter.Seq[V]) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+   size := len(self.data)
+   for lb, value := range self.traverse(values, self.cmp) {
+        if lb >= size || self.cmp(value, self.data[lb]) {
+		    return false
+		}
+   }
+   return true
+}
+
+
+// This method takes an iterator and returns an iterator that yields each of these values with true if an equivalent
+// value is contained within this container, or false if it is not. This is more efficient than calling Contains for
+// each value because the location of the previous value is used to optimize the search for the next one.
+//
+func (self *base[V]) ContainsEach(values iter.Seq[V]) iter.Seq2[V, bool] {
+    return func(yield func(V, bool) bool) {
+        size := len(self.data)
+        for lb, value := range self.traverse(values, self.cmp) {
+            if !yield(value, lb < size && !self.cmp(value, self.data[lb])) {
+                break
+            }
+        }
+    }
+}
+
+
+// Searches for a value within this container and returns the index of the lower bound and true if an equivalent value
+// is found at this index, otherwise it returns the index where the value would be inserted and false.
+//
+func (self *base[V]) Search(value V) (int, bool) {
+    if debug {
+        defer self.guard.read()()
+    }
+    lb := self.LowerBound(value)
+    return lb, lb < len(self.data) && !self.cmp(value, self.data[lb])
+}
+
+
+// Returns an index to the first value in the range where the comparison is not less than.
+//
+func (self *base[V]) LowerBound(value V) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    return self.bounds(value, 0, len(self.data) - 1, self.cmp)
+}
+
+
+// Returns an index to the first value in the range where the comparison is greater.
+//
+func (self *base[V]) UpperBound(value V) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    return self.bounds(value, 0, len(self.data) - 1, func(lhs, rhs V) bool { return !self.cmp(rhs, lhs) })
+}
+
+
+// Returns a new Finger that searches this container starting from the location of the previous value it found.
+//
+func (self *base[V]) Finger() *Finger[V] {
+    return &Finger[V]{set: self}
+}
+
+
+// A Finger is a handle to a FlatSet or FlatMultiSet that remembers the index of the previous value it searched for,
+// and searches outwards from this index for the next value using steps that double in size. If consecutive searches
+// are for values that are close together this takes O(log d) operations where d is the distance between them, instead
+// of the O(log n) operations required to search the whole container. A Finger is not safe to share between goroutines,
+// so each goroutine should use its own Finger to the same container.
+//
+type Finger[V any] struct {
+    set *base[V]  // container that is searched
+    last int      // index of the previous search
+}
+
+
+// Searches for a value starting from the previous search and returns the index of the lower bound and true if an
+// equivalent value is found at this index, otherwise it returns the index where the value would be inserted and false.
+//
+func (self *Finger[V]) Search(value V) (int, bool) {
+    data, cmp := self.set.data, self.set.cmp
+    size := len(data)
+    if size == 0 {
+        return 0, false
+    }
+
+    pos := min(self.last, size - 1)
+    lo, hi := 0, size - 1
+    if cmp(data[pos], value) {
+        lo = pos + 1
+        for step := 1; pos + step < size; step *= 2 {
+            if !cmp(data[pos + step], value) {
+                hi = pos + step - 1
+                break
+            }
+            lo = pos + step + 1
+        }
+    } else {
+        hi = pos - 1
+        for step := 1; pos - step >= 0; step *= 2 {
+            if cmp(data[pos - step], value) {
+                lo = pos - step + 1
+                break
+            }
+            hi = pos - step - 1
+        }
+    }
+
+    self.last = self.set.bounds(value, lo, hi, cmp)
+    return self.last, self.last < size && !cmp(value, data[self.last])
+}
+
+
+// Returns true if the container has this value or false if it does not, starting from the previous search.
+//
+func (self *Finger[V]) Contains(value V) bool {
+    _, found := self.Search(value)
+    return found
+}
+
+
+// A FlatSet is a sorted associative container of unique values using a comparison function.
+//
+type FlatSet[V any] struct {
+   base[V]
+}
+
+
+// Private method to remove subsequent keys that are repeated. If there is an equality function, an equivalent value is
+// only removed if it is equal to one of the values that are kept before it.
+//
+func (self *FlatSet[V]) removeDuplicates() {
+    self.unshare()
+    size := len(self.data)
+    if size > 1 {
+        upto, run := 1, 0
+        for next := 1; next < size; next++ {
+            if self.cmp(self.data[upto - 1], self.data[next]) {
+                run = upto
+            } else if self.eq == nil || slices.ContainsFunc(self.data[run:upto], func(kept V) bool {
+                return self.eq(kept, self.data[next])
+            }) {
+                continue
+            }
+            self.data[upto] = self.data[next]
+            upto++
+        }
+        clear(self.data[upto:size])
+        self.data = self.data[:upto]
+    }
+}
+
+
+// Private method that returns the other FlatSet sorted by the same comparison function as this one. If the comparison
+// functions are different and the values of the other FlatSet are not already sorted by this one, a sorted copy of the
+// other FlatSet is returned.
+//
+func (self *FlatSet[V]) sameOrder(other *FlatSet[V]) *FlatSet[V] {
+    if !sameCompare(self.cmp, other.cmp) && !sortedWith(other.data, self.cmp, true) {
+        out := &FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+        out.data = append([]V(nil), other.data...)
+        sort.SliceStable(out.data, func(lhs, rhs int) bool {return out.cmp(out.data[lhs], out.data[rhs])})
+        out.removeDuplicates()
+        return out
+    }
+    return other
+}
+
+
+// Make an empty FlatSet.
+//
+func MakeFlatSet[V any](cmp Compare[V]) FlatSet[V] {
+    return FlatSet[V]{base[V]{cmp: cmp}}
+}
+
+
+// Create a new empty FlatSet.
+//
+func NewFlatSet[V any](cmp Compare[V]) *FlatSet[V] {
+    return &FlatSet[V]{base[V]{cmp: cmp}}
+}
+
+
+// Create a new FlatSet and initialize it with some values. Values that are repeated will be discarded.
+//
+func InitFlatSet[V any](values []V, cmp Compare[V]) *FlatSet[V] {
+    defer traceOperation("flatset.InitFlatSet", len(values))()
+    self := &FlatSet[V]{base[V]{cmp: cmp}}
+    self.data = append([]V(nil), values...)
+    sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
+    self.removeDuplicates()
+    return self
+}
+
+
+// Private comparison function that sorts ordered values in ascending order using the < operator.
+//
+func lessOrdered[V cmp.Ordered](a, b V) bool {
+    return a < b
+}
+
+
+// Private function that returns lessOrdered as a comparison function. A generic function is wrapped in a new closure
+// wherever it is used as a value, so the ordered constructors all take it from here to share the same closure.
+//
+func orderedCompare[V cmp.Ordered]() Compare[V] {
+    return lessOrdered[V]
+}
+
+
+// Private function that returns true if this comparison function was returned by orderedCompare, so that the values
+// are known to be sorted in ascending order using the < operator without comparing any of them.
+//
+func isLessOrdered[V cmp.Ordered](compare Compare[V]) bool {
+    return compare != nil && reflect.ValueOf(compare).Pointer() == reflect.ValueOf(orderedCompare[V]()).Pointer()
+}
+
+
+// Private function that returns true if both comparison functions are the same closure, so that they are known to sort
+// the values in the same order. The code of the functions is not compared, as different closures of the same code can
+// capture different state, such as the orders composed by OrderBy.
+//
+func sameCompare[V any](lhs, rhs Compare[V]) bool {
+    return *(*unsafe.Pointer)(unsafe.Pointer(&lhs)) == *(*unsafe.Pointer)(unsafe.Pointer(&rhs))
+}
+
+
+// Private function that returns true if these values are sorted by this comparison function, where each value must be
+// greater than the one before it if strict, or not less than the one before it otherwise. This allows the values of a
+// container with a different comparison function to be used without sorting them again if they are in the same order.
+//
+func sortedWith[V any](values []V, cmp Compare[V], strict bool) bool {
+    for i := 1; i < len(values); i++ {
+        if strict && !cmp(values[i - 1], values[i]) || !strict && cmp(values[i], values[i - 1]) {
+            return false
+        }
+    }
+    return true
+}
+
+
+// Create a new empty FlatSet of ordered values, such as integers and strings, that is sorted in ascending order using
+// the < operator so that no comparison function is needed. Floating point NaN values can not be sorted with < so they
+// must not be inserted.
+//
+func NewOrderedFlatSet[V cmp.Ordered]() *FlatSet[V] {
+    return NewFlatSet[V](orderedCompare[V]())
+}
+
+
+// Create a new FlatSet of ordered values sorted in ascending order using the < operator, and initialize it with some
+// values. Values that are repeated will be discarded.
+//
+func InitOrderedFlatSet[V cmp.Ordered](values []V) *FlatSet[V] {
+    return InitFlatSet[V](values, orderedCompare[V]())
+}
+
+
+// Searches for a value within this container, and returns the index for the location of the value or -1 if not found.
+//
+func (self *FlatSet[V]) Find(value V) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    if self.eq != nil {
+        return self.findEqual(value)
+    }
+    lb := self.LowerBound(value)
+	if lb < len(self.data) && !self.cmp(value, self.data[lb]) {
+	    return lb
+	} else {
+    	return -1
+    }
+}
+
+
+// Insert a new value. If this value is already contained within this container it will return the index of the existing
+// value and false, otherwise it will return the index of the new value and true. If insertion is successful it will
+// invalidate any previous indices.
+//
+func (self *FlatSet[V]) Insert(value V) (int, bool) {
+    if debug {
+        defer self.guard.write()()
+    }
+    if self.eq != nil {
+        if index := self.findEqual(value); index != -1 {
+            return index, false
+        }
+        ub := self.UpperBound(value)
+        self.insert(ub, value)
+        return ub, true
+    }
+    ub := self.UpperBound(value)
+    if ub > 0 && !self.cmp(self.data[ub - 1], value) {
+        return ub - 1, false
+    } else {
+    	self.insert(ub, value)
+    	return ub, true
+	}
+}
+
+
+// Insert a new value like Insert, but return a pointer to the value stored in this container instead of its index, so
+// the fields of a structure that are not compared by the comparison function can be modified without searching for it
+// again. The fields that are compared must not be modified through the pointer. The pointer is only valid until this
+// container is modified, as the values can then be moved.
+//
+func (self *FlatSet[V]) InsertRef(value V) (*V, bool) {
+    if debug {
+        defer self.guard.write()()
+    }
+    index, inserted := self.Insert(value)
+    self.unshare()
+    return &self.data[index], inserted
+}
+
+
+// Insert a new value, or overwrite the existing equivalent value if there is one, and return the index of the value and
+// true if it was inserted. For a comparison function that only compares the key of a structure this replaces the
+// record with the same key, which does not change the order of the values. Previous indices are only invalidated if
+// the value was inserted.
+//
+func (self *FlatSet[V]) Upsert(value V) (int, bool) {
+    if debug {
+        defer self.guard.write()()
+    }
+    ub := self.UpperBound(value)
+    if ub > 0 && !self.cmp(self.data[ub - 1], value) {
+        self.unshare()
+        self.data[ub - 1] = value
+        self.check(ub - 1)
+        return ub - 1, false
+    }
+    self.insert(ub, value)
+    return ub, true
+}
+
+
+// Append a value that is greater than all of the values in this container without searching for its position. If the
+// value is less than the last value ErrOutOfOrder is returned, or if it is equivalent ErrDuplicate is returned, and
+// the value is not inserted. This is the fastest way to insert values from a producer that is already ordered.
+//
+func (self *FlatSet[V]) PushBack(value V) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    if size := len(self.data); size > 0 && !self.cmp(self.data[size - 1], value) {
+        if self.cmp(value, self.data[size - 1]) {
+            return ErrOutOfOrder
+        }
+        return ErrDuplicate
+    }
+    self.grow(1)
+    self.data = append(self.data, value)
+    self.check(len(self.data) - 1)
+    return nil
+}
+
+
+// Append the values of another FlatSet that are all greater than the values in this container with a single copy,
+// such as for time partitioned data where each new chunk is ordered after the existing ones. If the first value of the
+// other FlatSet is less than the last value of this container ErrOutOfOrder is returned, or if it is equivalent
+// ErrDuplicate is returned, and none of the values are inserted. This method will not invalidate previous indices.
+//
+func (self *FlatSet[V]) ExtendDisjoint(other *FlatSet[V]) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    other = self.sameOrder(other)
+    if size := len(self.data); size > 0 && len(other.data) > 0 && !self.cmp(self.data[size - 1], other.data[0]) {
+        if self.cmp(other.data[0], self.data[size - 1]) {
+            return ErrOutOfOrder
+        }
+        return ErrDuplicate
+    }
+    size := len(self.data)
+    self.grow(len(other.data))
+    self.data = append(self.data, other.data...)
+    self.check(size)
+    return nil
+}
+
+
+// Insert a value that is less than all of the values in this container without searching for its position. If the
+// value is greater than the first value ErrOutOfOrder is returned, or if it is equivalent ErrDuplicate is returned,
+// and the value is not inserted. The other values are shifted with a single copy. This method will invalidate any
+// previous indices.
+//
+func (self *FlatSet[V]) PushFront(value V) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    if len(self.data) > 0 && !self.cmp(value, self.data[0]) {
+        if self.cmp(self.data[0], value) {
+            return ErrOutOfOrder
+        }
+        return ErrDuplicate
+    }
+    self.insert(0, value)
+    return nil
+}
+
+
+// Delete the value at this index from this container.
+//
+func (self *FlatSet[V]) Erase(index int) {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.unshare()
+    self.data = append(self.data[:index], self.data[index+1:]...)
+    self.check(index)
+}
+
+
+// Delete the values from this index (inclusive) upto this index (exclusive) from this container, such as the values
+// between two lower bounds. The values after the range are moved with a single copy and the vacated slots are zeroed.
+// This method will invalidate any previous indices.
+//
+func (self *FlatSet[V]) EraseRange(from, upto int) {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.unshare()
+    size := len(self.data)
+    self.data = append(self.data[:from], self.data[upto:]...)
+    clear(self.data[len(self.data):size])
+    self.check(from)
+}
+
+// Remove this value if it exists in this container and return true, otherwise return false if it was not found.
+//
+func (self *FlatSet[V]) Remove(value V) bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    index := self.Find(value)
+    if index != -1 {
+        self.Erase(index)
+        return true
+    } else {
+        return false
+    }
+}
+
+// Try to replace the value at this index. If the previous value was replaced return true, otherwise return false if
+// the new value would result in data being out of sequence. This method allow you to quickly modify a value if you know
+// its index, without the need to erase the previous value and insert the new one. This method will not invalidate
+// previous indices.
+//
+func (self *FlatSet[V]) Replace(index int, value V) bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    size := len(self.data)
+    if index < size {
+        if (index > 0 && !self.cmp(self.data[index - 1], value)) ||
+            (index < size - 1 && !self.cmp(value, self.data[index + 1])) {
+            return false
+        }
+        self.unshare()
+        self.data[index] = value
+        self.check(index)
+        return true
+    }
+    return false
+}
+
+
+// Try to replace the block of values starting at this index with these values. If the block was replaced return true,
+// otherwise return false if the block would extend past the end of this container, or if the new values are not in
+// strictly ascending order or would be out of sequence with the values either side of the block. This allows a window
+// of values that have been recomputed to be copied in at once without erasing and inserting each value. This method
+// will not invalidate previous indices.
+//
+func (self *FlatSet[V]) ReplaceSlice(from int, values []V) bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    n := len(values)
+    upto := from + n
+    if from < 0 || upto > len(self.data) {
+        return false
+    } else if n == 0 {
+        return true
+    }
+    for i := 1; i < n; i++ {
+        if !self.cmp(values[i - 1], values[i]) {
+            return false
+        }
+    }
+    if (from > 0 && !self.cmp(self.data[from - 1], values[0])) ||
+        (upto < len(self.data) && !self.cmp(values[n - 1], self.data[upto])) {
+        return false
+    }
+    self.unshare()
+    copy(self.data[from:], values)
+    self.check(from)
+    self.check(from + len(values))
+    return true
+}
+
+
+// Append another FlatSet into this one. It is also possible to merge FlatSets that have a different comparison
+// function. If a value already exists in this container the new value from the other FlatSet will be discarded to
+// maintain order stability. This method is similar but more efficient than Update because it is able to preallocate
+// the array. This method updates this container so it will invalidate any previous indices.
+//
+func (self *FlatSet[V]) Merge(other *FlatSet[V]) {
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.FlatSet.Merge", len(self.data) + len(other.data))()
+    other = self.sameOrder(other)
+    self.mergeSorted(&other.base)
+    self.removeDuplicates()
+}
+
+
+// Returns a new FlatSet containing a copy of the values of this container in a new array, with the same comparison and
+// equality functions, so that modifying either container does not affect the other. The values themselves are copied
+// by assignment, so a container of pointers still refers to the same structures.
+//
+func (self *FlatSet[V]) Clone() *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    return &FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq, data: slices.Clone(self.data)}}
+}
+
+
+// Return a new FlatSet containing the values of this container and another FlatSet, merged in a single pass into an
+// array that is allocated once like Merge, without modifying either of them. If a value exists in both containers the
+// value from this container is kept to maintain order stability. This method does not modify this container so it will
+// not invalidate previous indices.
+//
+func (self *FlatSet[V]) Merged(other *FlatSet[V]) *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    defer traceOperation("flatset.FlatSet.Merged", len(self.data) + len(other.data))()
+    other = self.sameOrder(other)
+    // the capacity is clipped so that the values are merged into a new array
+    out := &FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq, data: slices.Clip(self.data)}}
+    if len(other.data) == 0 {
+        out.data = slices.Clone(self.data)
+        return out
+    }
+    out.mergeSorted(&other.base)
+    out.removeDuplicates()
+    return out
+}
+
+
+// Insert these values into this container. This method is more flexible but less efficient than Merge because it takes
+// a generic iterator of values. If a value already exists in this container the new value will be discarded to maintain
+// order stability. If the values are already sorted using the comparison function they are merged in a single pass
+// like Merge. This method updates this container so it will invalidate any previous indices.
+//
+func (self *FlatSet[V]) Update(values iter.Seq[V]) {
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.FlatSet.Update", len(self.data))()
+    buffer, sorted := self.collect(values)
+    if !sorted && self.eq != nil {
+        sort.SliceStable(buffer, func(lhs, rhs int) bool {return self.cmp(buffer[lhs], buffer[rhs])})
+        sorted = true
+    }
+    if sorted && (len(buffer) > 1 || self.eq != nil) {
+        self.mergeSorted(&base[V]{cmp: self.cmp, data: buffer})
+        self.removeDuplicates()
+    } else {
+        self.update(slices.Values(buffer), true)
+    }
+}
+
+
+// Insert the values from several iterators into this container, such as when combining several feeds. The values of
+// every iterator are collected into one buffer that is sorted once and merged into this container with a single growth
+// of its array, instead of calling Update for each iterator. If a value already exists in this container, or in an
+// earlier iterator, the new value will be discarded to maintain order stability. This method updates this container so
+// it will invalidate any previous indices.
+//
+func (self *FlatSet[V]) UpdateAll(sources ...iter.Seq[V]) {
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.FlatSet.UpdateAll", len(self.data))()
+    var buffer []V
+    for _, values := range sources {
+        for value := range values {
+            buffer = append(buffer, value)
+        }
+    }
+    if len(buffer) > 0 {
+        sort.SliceStable(buffer, func(lhs, rhs int) bool {return self.cmp(buffer[lhs], buffer[rhs])})
+        self.mergeSorted(&base[V]{cmp: self.cmp, data: buffer})
+        self.removeDuplicates()
+    }
+}
+
+
+// Insert the values from an iterator like Update, and return the number of values that were inserted and the number
+// that were discarded because an equivalent value already exists. Calling this method for each source of values gives
+// the counts for each source. This method updates this container so it will invalidate any previous indices.
+//
+func (self *FlatSet[V]) UpdateCount(values iter.Seq[V]) (int, int) {
+    size, count := len(self.data), 0
+    self.Update(func(yield func(V) bool) {
+        for value := range values {
+            count++
+            if !yield(value) {
+                return
+            }
+        }
+    })
+    inserted := len(self.data) - size
+    return inserted, count - inserted
+}
+
+
+// Append another FlatSet into this one like Merge, and return the number of values that were inserted and the number
+// that were discarded because an equivalent value already exists. This method updates this container so it will
+// invalidate any previous indices.
+//
+func (self *FlatSet[V]) MergeCount(other *FlatSet[V]) (int, int) {
+    size, count := len(self.data), len(other.data)
+    self.Merge(other)
+    inserted := len(self.data) - size
+    return inserted, count - inserted
+}
+
+
+// Return a new FlatSet combining all the values in this container with these other values. If a value already exists in
+// this container the new value will not be included in the resulting FlatSet. If the expected size of the resulting
+// FlatSet is known it can be passed as a size hint so that its array is only allocated once. This method does not
+// modify this container so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) Union(values iter.Seq[V], sizeHint ...int) *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    size := len(self.data)
+    for _, hint := range sizeHint {
+        size = max(size, hint)
+    }
+    out := FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    out.data = append(make([]V, 0, size), self.data...)
+    out.Update(values)
+    return &out
+}
+
+// Return a new FlatSet containing the common values in this container with these other values. To maintain order
+// stability the original values from this container will be returned. The common values are found before the resulting
+// FlatSet is allocated so it does not need a size hint. This method does not modify this container so it will not
+// invalidate previous indices.
+//
+func (self *FlatSet[V]) Intersection(values iter.Seq[V]) *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    mask, count := self.matches(values)
+    out := FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    out.data = make([]V, 0, count)
+    for i, value := range self.data {
+        if mask[i] {
+            out.data = append(out.data, value)
+        }
+    }
+    return &out
+}
+
+
+// Remove the values from this container that are not equivalent to any of these values, which updates this container
+// to the Intersection without allocating a new FlatSet. The remaining values are moved once to the start of the array.
+// Returns the number of values that were removed. This method will invalidate any previous indices.
+//
+func (self *FlatSet[V]) IntersectUpdate(values iter.Seq[V]) int {
+    if debug {
+        defer self.guard.write()()
+    }
+    mask, count := self.matches(values)
+    if count == len(self.data) {
+        return 0
+    }
+    return self.compact(func(index int) bool {return mask[index]})
+}
+
+
+// Return a new FlatSet containing the values that exist in this container but not in these other values. The common
+// values are found before the resulting FlatSet is allocated so it does not need a size hint. This method does not
+// modify this container so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) Difference(values iter.Seq[V]) *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    mask, count := self.matches(values)
+    out := FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    out.data = make([]V, 0, len(self.data) - count)
+    for i, value := range self.data {
+        if !mask[i] {
+            out.data = append(out.data, value)
+        }
+    }
+    return &out
+}
+
+
+// Remove the values from this container that are equivalent to any of these values, which updates this container to
+// the Difference without allocating a new FlatSet. The remaining values are moved once to the start of the array.
+// Returns the number of values that were removed. This method will invalidate any previous indices.
+//
+func (self *FlatSet[V]) DifferenceUpdate(values iter.Seq[V]) int {
+    if debug {
+        defer self.guard.write()()
+    }
+    mask, count := self.matches(values)
+    if count == 0 {
+        return 0
+    }
+    return self.compact(func(index int) bool {return !mask[index]})
+}
+
+
+// Return a new FlatSet containing the values that exist in only one of this container and these other values. The
+// other values are sorted if they are not already sorted, and then both are merged in a single pass, instead of
+// subtracting their Intersection from their Union. If a value is repeated in the other values it is only included once.
+// This method does not modify this container so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) SymmetricDifference(values iter.Seq[V]) *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    buffer, sorted := self.collect(values)
+    if !sorted {
+        sort.SliceStable(buffer, func(lhs, rhs int) bool {return self.cmp(buffer[lhs], buffer[rhs])})
+    }
+    out := FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    out.data = make([]V, 0, len(self.data) + len(buffer))
+
+    lhsIdx, rhsIdx := 0, 0
+    for lhsIdx < len(self.data) || rhsIdx < len(buffer) {
+        if rhsIdx == len(buffer) || (lhsIdx < len(self.data) && self.cmp(self.data[lhsIdx], buffer[rhsIdx])) {
+            out.data = append(out.data, self.data[lhsIdx])
+            lhsIdx++
+            continue
+        }
+        value := buffer[rhsIdx]
+        for rhsIdx++; rhsIdx < len(buffer) && !self.cmp(value, buffer[rhsIdx]); rhsIdx++ {}
+        if lhsIdx == len(self.data) || self.cmp(value, self.data[lhsIdx]) {
+            out.data = append(out.data, value)
+        } else {
+            lhsIdx++
+        }
+    }
+    return &out
+}
+
+
+// Return a new FlatSet combining all the values in this container and these other FlatSets in a single pass over all
+// of them, instead of chaining Union and allocating every intermediate result. If equivalent values exist in several
+// containers the value from the first of them is included, starting with this container. The array of the resulting
+// FlatSet is allocated once. This method does not modify any of the containers so it will not invalidate previous
+// indices.
+//
+func (self *FlatSet[V]) UnionN(others ...*FlatSet[V]) *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    sets := []*FlatSet[V]{self}
+    size := len(self.data)
+    for _, other := range others {
+        other = self.sameOrder(other)
+        sets = append(sets, other)
+        size += len(other.data)
+    }
+    out := FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    out.data = make([]V, 0, size)
+
+    // a value is new if it is the first of the equivalent values, or if it is not equal to any of the values kept
+    group := 0
+    isNew := func(value V) bool {
+        if group == len(out.data) {
+            return true
+        } else if self.eq == nil {
+            return false
+        }
+        return !slices.ContainsFunc(out.data[group:], func(kept V) bool { return self.eq(kept, value) })
+    }
+
+    heads := make([]int, len(sets))
+    for {
+        least := -1
+        for i, set := range sets {
+            if heads[i] < len(set.data) &&
+                (least == -1 || self.cmp(set.data[heads[i]], sets[least].data[heads[least]])) {
+                least = i
+            }
+        }
+        if least == -1 {
+            return &out
+        }
+        value := sets[least].data[heads[least]]
+        group = len(out.data)
+        for i, set := range sets {
+            for ; heads[i] < len(set.data) && !self.cmp(value, set.data[heads[i]]); heads[i]++ {
+                if isNew(set.data[heads[i]]) {
+                    out.data = append(out.data, set.data[heads[i]])
+                }
+            }
+        }
+    }
+}
+
+
+// Return a new FlatSet containing the values of this container that are also in every one of these other FlatSets.
+// The values of the smallest container are searched for in the other containers, continuing each search from where the
+// previous search ended, so the cost depends on the size of the smallest container rather than the largest. No
+// intermediate results are allocated, and to maintain order stability the original values from this container are
+// returned. This method does not modify any of the containers so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) IntersectionN(others ...*FlatSet[V]) *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    sets := []*FlatSet[V]{self}
+    smallest := 0
+    for _, other := range others {
+        other = self.sameOrder(other)
+        if len(other.data) < len(sets[smallest].data) {
+            smallest = len(sets)
+        }
+        sets = append(sets, other)
+    }
+    out := FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    out.data = make([]V, 0, len(sets[smallest].data))
+
+    heads := make([]int, len(sets))
+    for _, value := range sets[smallest].data {
+        found := true
+        for i, set := range sets {
+            heads[i] = set.bounds(value, heads[i], len(set.data) - 1, self.cmp)
+            if heads[i] == len(set.data) {
+                return &out
+            }
+            found = found && !self.cmp(value, set.data[heads[i]])
+        }
+        if found {
+            out.data = append(out.data, self.data[heads[0]])
+        }
+    }
+    return &out
+}
+
+
+// Returns the number of values that would be in the Intersection of this container and another FlatSet without
+// allocating the result. This method does not modify this container so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) IntersectionCount(other *FlatSet[V]) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    other = self.sameOrder(other)
+    return self.countCommon(&other.base)
+}
+
+
+// Returns the number of values that would be in the Union of this container and another FlatSet without allocating the
+// result. This method does not modify this container so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) UnionCount(other *FlatSet[V]) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    other = self.sameOrder(other)
+    return len(self.data) + len(other.data) - self.countCommon(&other.base)
+}
+
+
+// Returns the number of values that would be in the Difference of this container and another FlatSet without
+// allocating the result. This method does not modify this container so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) DifferenceCount(other *FlatSet[V]) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    other = self.sameOrder(other)
+    return len(self.data) - self.countCommon(&other.base)
+}
+
+
+// Returns the number of values that are in only one of this container and another FlatSet, which is the size of their
+// symmetric difference, without allocating the result. This can be used to decide whether the values have changed and
+// by how much. This method does not modify this container so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) SymmetricDifferenceCount(other *FlatSet[V]) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    other = self.sameOrder(other)
+    return len(self.data) + len(other.data) - 2 * self.countCommon(&other.base)
 }
 
-// Return a new FlatSet combining all the values in this container with these other values. If a value already exists in
-// the new value will not be included in the resulting FlatSet. This method does not modify this container so it will
-// not invalidate previous indices.
+
+// Returns true if this container and another FlatSet contain equivalent values, which is when their symmetric
+// difference is empty. The sizes are compared first so that sets of different sizes are not walked at all. This method
+// does not modify this container so it will not invalidate previous indices.
 //
-func (self *FlatSet[V]) Union(values iter.Seq[V]) *FlatSet[V] {
-    out := *self
-    out.Update(values)
-    return &out
+func (self *FlatSet[V]) Equal(other *FlatSet[V]) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+    other = self.sameOrder(other)
+    return len(self.data) == len(other.data) && self.countCommon(&other.base) == len(self.data)
 }
 
-// Return a new FlatSet containing the common values in this container with these other values. To maintain order
-// stability the original values from this container will be returned. This method does not modify this container so it
-// will not invalidate previous indices.
+
+// Returns true if every value in this container is equivalent to a value in another FlatSet. Both arrays are walked
+// once with two indices, stopping at the first value that is missing from the other FlatSet. This method does not
+// modify this container so it will not invalidate previous indices.
 //
-func (self *FlatSet[V]) Intersection(values iter.Seq[V]) *FlatSet[V] {
-    size := len(self.data)
-    out := FlatSet[V]{base[V]{cmp: self.cmp}}
-    out.data = make([]V, size)
+func (self *FlatSet[V]) IsSubsetOf(other *FlatSet[V]) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+    other = self.sameOrder(other)
+    return self.subsetOf(&other.base)
+}
 
-    i := 0
-    for lb, value := range self.traverse(values, self.cmp) {
-        if lb < size && !self.cmp(value, self.data[lb]) {
-            out.data[i] = value
-            i++
+
+// Returns true if every value in another FlatSet is equivalent to a value in this container, which is when the other
+// FlatSet is a subset of this one. This method does not modify this container so it will not invalidate previous
+// indices.
+//
+func (self *FlatSet[V]) IsSupersetOf(other *FlatSet[V]) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+    other = self.sameOrder(other)
+    return other.subsetOf(&self.base)
+}
+
+
+// Returns true if no value in this container is equivalent to a value in another FlatSet. Both arrays are walked once
+// with two indices, stopping at the first value that is contained in both. This method does not modify this container
+// so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) IsDisjointFrom(other *FlatSet[V]) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+    other = self.sameOrder(other)
+    lhsIdx, rhsIdx := 0, 0
+    lhsSz, rhsSz := len(self.data), len(other.data)
+
+    for lhsIdx < lhsSz && rhsIdx < rhsSz {
+        if self.cmp(self.data[lhsIdx], other.data[rhsIdx]) {
+            lhsIdx++
+        } else if self.cmp(other.data[rhsIdx], self.data[lhsIdx]) {
+            rhsIdx++
+        } else {
+            return false
         }
     }
-    out.data = append([]V(nil), out.data[:i]...)
-    return &out
+    return true
 }
 
 
-// Return a new FlatSet containing the values that exist in this container but not in these other values. This method
-// does not modify this container so it will not invalidate previous indices.
+// Returns a FlatMultiSet that takes the array of values from this FlatSet without copying them, as the values of a
+// FlatSet are also sorted for a FlatMultiSet. This FlatSet is left empty, so that the two containers do not share the
+// same array.
 //
-func (self *FlatSet[V]) Difference(values iter.Seq[V]) *FlatSet[V] {
-    out := FlatSet[V]{base[V]{cmp: self.cmp}}
-    out.data = append([]V(nil), self.data...)
+func (self *FlatSet[V]) AsMultiSet() *FlatMultiSet[V] {
+    return &FlatMultiSet[V]{base[V]{cmp: self.cmp, eq: self.eq, data: self.Release()}}
+}
+
+
+// Remove the values from this index (inclusive) upto this index (exclusive) and return them in a new FlatSet with the
+// same comparison function, for example to move a range of values that are no longer used into an archive. The range
+// of values for a pair of values can be found with LowerBound. This method will invalidate any previous indices.
+//
+func (self *FlatSet[V]) DrainRange(from, upto int) *FlatSet[V] {
+    if debug {
+        defer self.guard.write()()
+    }
+    return &FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq, data: self.drain(from, upto)}}
+}
+
+
+// Returns true if the values are sorted using the comparison function without any repeated values. This can only be
+// false if the values were modified outside of this container, such as the fields of a FlatSet of pointers to
+// structures, in which case the search methods will return the wrong results until the FlatSet is repaired.
+//
+func (self *FlatSet[V]) IsSorted() bool {
+    for i := 1; i < len(self.data); i++ {
+        if self.cmp(self.data[i], self.data[i - 1]) || (self.eq == nil && !self.cmp(self.data[i - 1], self.data[i])) {
+            return false
+        }
+    }
+    return true
+}
+
+
+// Sort the values in place using the comparison function and remove any repeated values, if the values are not already
+// sorted because they were modified outside of this container. Returns true if the values had to be repaired. The
+// order of equivalent values is maintained, so the first of each repeated value is kept. This method will invalidate
+// any previous indices if the values had to be repaired.
+//
+func (self *FlatSet[V]) Repair() bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    if self.IsSorted() {
+        return false
+    }
+    self.unshare()
+    sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
+    self.removeDuplicates()
+    return true
+}
+
+
+// Replace every value of this FlatSet with the result of calling the function with the value, such as to normalize
+// the values. The order of the new values is checked in the same pass, and only if the function changed their order or
+// made any of them equivalent are the values sorted again and the repeated values removed, keeping the first of each.
+// Returns true if the values had to be sorted. This method will invalidate any previous indices if the values had to
+// be sorted.
+//
+func (self *FlatSet[V]) MapInPlace(fn func(V) V) bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.unshare()
+    sorted := true
+    for i := range self.data {
+        self.data[i] = fn(self.data[i])
+        if sorted && i > 0 && !self.cmp(self.data[i - 1], self.data[i]) {
+            sorted = false
+        }
+    }
+    if sorted {
+        return false
+    }
+    sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
+    self.removeDuplicates()
+    return true
+}
+
+
+// Return a new FlatSet with the same comparison function containing only the values of this container for which the
+// predicate returns true. The values are already sorted so they are appended in a single pass without sorting.
+// This method does not modify this container so it will not invalidate previous indices.
+//
+func (self *FlatSet[V]) Filter(pred func(V) bool) *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    out := FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    for _, value := range self.data {
+        if pred(value) {
+            out.data = append(out.data, value)
+        }
+    }
+    return &out
+}
+
 
-    i := 0
-    for lb, value := range out.traverse(values, self.cmp) {
-        if lb < len(out.data) && !self.cmp(value, out.data[lb]) {
-            size := len(out.data)
-            copy(out.data[lb:size-1], out.data[lb+1:size])
-            i++
+// Return a new FlatSet with the same comparison function containing the result of calling the function with each value
+// of this container. The order of the new values is checked as they are made, and only if the function changed their
+// order or made any of them equivalent are they sorted and the repeated values removed, keeping the first of each. If
+// the function is known to preserve the order, and never makes values equivalent, true can be passed as a hint so that
+// the values are not compared at all. This method does not modify this container so it will not invalidate previous
+// indices.
+//
+func (self *FlatSet[V]) Transform(fn func(V) V, preservesOrder ...bool) *FlatSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    trusted := false
+    for _, hint := range preservesOrder {
+        trusted = trusted || hint
+    }
+    out := FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    out.data = make([]V, len(self.data))
+    sorted := true
+    for i, value := range self.data {
+        out.data[i] = fn(value)
+        if !trusted && sorted && i > 0 && !self.cmp(out.data[i - 1], out.data[i]) {
+            sorted = false
         }
     }
-    out.data = append([]V(nil), out.data[:len(self.data) - i]...)
+    if !sorted {
+        sort.SliceStable(out.data, func(lhs, rhs int) bool {return out.cmp(out.data[lhs], out.data[rhs])})
+        out.removeDuplicates()
+    }
     return &out
 }
 
@@ -449,6 +1966,7 @@ func NewFlatMultiSet[V any](cmp Compare[V]) *FlatMultiSet[V] {
 // Create a new FlatMultiSet and initialize it with some values. The order of equivalent values will be maintained.
 //
 func InitFlatMultiSet[V any](values []V, cmp Compare[V]) *FlatMultiSet[V] {
+    defer traceOperation("flatset.InitFlatMultiSet", len(values))()
     self := &FlatMultiSet[V]{base[V]{cmp: cmp}}
     self.data = append([]V(nil), values...)
     sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
@@ -460,6 +1978,9 @@ func InitFlatMultiSet[V any](values []V, cmp Compare[V]) *FlatMultiSet[V] {
 // index of the last value exclusive(). If no equivalent value is found this method will return -1, -1.
 //
 func (self *FlatMultiSet[V]) Find(value V) (int, int) {
+    if debug {
+        defer self.guard.read()()
+    }
     size := len(self.data)
     lb := self.LowerBound(value)
 	if lb < size && !self.cmp(value, self.data[lb]) {
@@ -474,23 +1995,112 @@ func (self *FlatMultiSet[V]) Find(value V) (int, int) {
 }
 
 
+// Returns the number of values in this container that are equivalent to this value, or 0 if there are none.
+//
+func (self *FlatMultiSet[V]) Count(value V) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    return self.UpperBound(value) - self.LowerBound(value)
+}
+
+
 // Insert a new value at the upper bound and return the index of the new value. This method will invalidate any previous
 // indices.
 //
 func (self *FlatMultiSet[V]) Insert(value V) int {
+    if debug {
+        defer self.guard.write()()
+    }
 	ub := self.UpperBound(value)
     self.insert(ub, value)
     return ub
 }
 
 
+// Insert a new value like Insert, but return a pointer to the value stored in this container instead of its index, so
+// the fields of a structure that are not compared by the comparison function can be modified without searching for it
+// again. The fields that are compared must not be modified through the pointer. The pointer is only valid until this
+// container is modified, as the values can then be moved.
+//
+func (self *FlatMultiSet[V]) InsertRef(value V) *V {
+    if debug {
+        defer self.guard.write()()
+    }
+    return &self.data[self.Insert(value)]
+}
+
+
+// Append a value that is greater than or equivalent to all of the values in this container without searching for its
+// position. If the value is less than the last value ErrOutOfOrder is returned and the value is not inserted. This is
+// the fastest way to insert values from a producer that is already ordered.
+//
+func (self *FlatMultiSet[V]) PushBack(value V) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    if size := len(self.data); size > 0 && self.cmp(value, self.data[size - 1]) {
+        return ErrOutOfOrder
+    }
+    self.grow(1)
+    self.data = append(self.data, value)
+    self.check(len(self.data) - 1)
+    return nil
+}
+
+
+// Append the values of another FlatMultiSet that are all greater than or equivalent to the values in this container
+// with a single copy, such as for time partitioned data where each new chunk is ordered after the existing ones. If the
+// first value of the other FlatMultiSet is less than the last value of this container ErrOutOfOrder is returned and
+// none of the values are inserted. This method will not invalidate previous indices.
+//
+func (self *FlatMultiSet[V]) ExtendDisjoint(other *FlatMultiSet[V]) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    if !sameCompare(self.cmp, other.cmp) && !sortedWith(other.data, self.cmp, false) {
+        other = InitFlatMultiSet[V](other.data, self.cmp)
+    }
+    if size := len(self.data); size > 0 && len(other.data) > 0 && self.cmp(other.data[0], self.data[size - 1]) {
+        return ErrOutOfOrder
+    }
+    size := len(self.data)
+    self.grow(len(other.data))
+    self.data = append(self.data, other.data...)
+    self.check(size)
+    return nil
+}
+
+
+// Insert a value that is less than all of the values in this container without searching for its position. If the
+// value is greater than or equivalent to the first value ErrOutOfOrder is returned and the value is not inserted, as an
+// equivalent value must be inserted after the existing values to maintain order stability. The other values are
+// shifted with a single copy. This method will invalidate any previous indices.
+//
+func (self *FlatMultiSet[V]) PushFront(value V) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    if len(self.data) > 0 && !self.cmp(value, self.data[0]) {
+        return ErrOutOfOrder
+    }
+    self.insert(0, value)
+    return nil
+}
+
+
 // Delete values from this index (inclusive) upto this index (exclusive) from this container. If from == -1 this method
 // is a no-op in order that you can pass the indices from Find as arguments. This method will invalidate any previous
 // indices.
 //
 func (self *FlatMultiSet[V]) Erase(from, upto int) {
+    if debug {
+        defer self.guard.write()()
+    }
     if from >= 0 {
+        self.unshare()
         self.data = append(self.data[:from], self.data[upto:]...)
+        self.check(from)
     }
 }
 
@@ -498,6 +2108,9 @@ func (self *FlatMultiSet[V]) Erase(from, upto int) {
 // invalidate any previous indices.
 //
 func (self *FlatMultiSet[V]) Remove(value V) int {
+    if debug {
+        defer self.guard.write()()
+    }
     from, upto := self.Find(value)
     self.Erase(from, upto)
     return upto - from
@@ -510,38 +2123,365 @@ func (self *FlatMultiSet[V]) Remove(value V) int {
 // previous indices.
 //
 func (self *FlatMultiSet[V]) Replace(index int, value V) bool {
+    if debug {
+        defer self.guard.write()()
+    }
     size := len(self.data)
     if index < size {
         if (index > 0 && self.cmp(value, self.data[index - 1], )) ||
             (index < size - 1 && self.cmp(self.data[index + 1], value)) {
             return false
         }
+        self.unshare()
         self.data[index] = value
+        self.check(index)
         return true
     }
     return false
 }
 
 
+// Try to replace the block of values starting at this index with these values. If the block was replaced return true,
+// otherwise return false if the block would extend past the end of this container, or if the new values are not in
+// order or would be out of sequence with the values either side of the block. This allows a window of values that have
+// been recomputed to be copied in at once without erasing and inserting each value. This method will not invalidate
+// previous indices.
+//
+func (self *FlatMultiSet[V]) ReplaceSlice(from int, values []V) bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    n := len(values)
+    upto := from + n
+    if from < 0 || upto > len(self.data) {
+        return false
+    } else if n == 0 {
+        return true
+    }
+    for i := 1; i < n; i++ {
+        if self.cmp(values[i], values[i - 1]) {
+            return false
+        }
+    }
+    if (from > 0 && self.cmp(values[0], self.data[from - 1])) ||
+        (upto < len(self.data) && self.cmp(self.data[upto], values[n - 1])) {
+        return false
+    }
+    self.unshare()
+    copy(self.data[from:], values)
+    self.check(from)
+    self.check(from + len(values))
+    return true
+}
+
+
 // Append another FlatMultiSet into this one. It is also possible to merge FlatMultiSets that have a different
 // comparison function. Values from the other container will be inserted at the upper bound so equivalent values will be
 // ordered after the one in this container other ones. This method is similar but more efficient than Update because it
 // is able to preallocate the array. This method will invalidate any previous indices.
 //
 func (self *FlatMultiSet[V]) Merge(other *FlatMultiSet[V]) {
-    if reflect.ValueOf(self.cmp).Pointer() != reflect.ValueOf(other.cmp).Pointer() {
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.FlatMultiSet.Merge", len(self.data) + len(other.data))()
+    if !sameCompare(self.cmp, other.cmp) && !sortedWith(other.data, self.cmp, false) {
         other = InitFlatMultiSet[V](other.data, self.cmp)
     }
     self.mergeSorted(&other.base)
 }
 
 
+// Returns a new FlatMultiSet containing a copy of the values of this container in a new array, with the same comparison
+// and equality functions, so that modifying either container does not affect the other. The values themselves are
+// copied by assignment, so a container of pointers still refers to the same structures.
+//
+func (self *FlatMultiSet[V]) Clone() *FlatMultiSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    return &FlatMultiSet[V]{base[V]{cmp: self.cmp, eq: self.eq, data: slices.Clone(self.data)}}
+}
+
+
+// Return a new FlatMultiSet containing the values of this container and another FlatMultiSet, merged in a single pass
+// into an array that is allocated once like Merge, without modifying either of them. Equivalent values from the other
+// container are ordered after the ones from this container. This method does not modify this container so it will not
+// invalidate previous indices.
+//
+func (self *FlatMultiSet[V]) Merged(other *FlatMultiSet[V]) *FlatMultiSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    defer traceOperation("flatset.FlatMultiSet.Merged", len(self.data) + len(other.data))()
+    if !sameCompare(self.cmp, other.cmp) && !sortedWith(other.data, self.cmp, false) {
+        other = InitFlatMultiSet[V](other.data, self.cmp)
+    }
+    // the capacity is clipped so that the values are merged into a new array
+    out := &FlatMultiSet[V]{base[V]{cmp: self.cmp, eq: self.eq, data: slices.Clip(self.data)}}
+    if len(other.data) == 0 {
+        out.data = slices.Clone(self.data)
+        return out
+    }
+    out.mergeSorted(&other.base)
+    return out
+}
+
+
 // Insert these values into this container at the upper bound to maintain order stability. This method is more flexible
-// but less efficient than Merge because it takes a generic iterator of values. This method updates this container so
-// it will invalidate any previous indices.
+// but less efficient than Merge because it takes a generic iterator of values. If the values are already sorted using
+// the comparison function they are merged in a single pass like Merge. This method updates this container so it will
+// invalidate any previous indices.
 //
 func (self *FlatMultiSet[V]) Update(values iter.Seq[V]) {
-    for ub, value := range self.traverse(values, func(lhs, rhs V) bool { return !self.cmp(rhs, lhs) }) {
-        self.insert(ub, value)
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.FlatMultiSet.Update", len(self.data))()
+    buffer, sorted := self.collect(values)
+    if sorted && len(buffer) > 1 {
+        self.mergeSorted(&base[V]{cmp: self.cmp, data: buffer})
+    } else {
+        self.update(slices.Values(buffer), false)
+    }
+}
+
+
+// Insert the values from several iterators into this container, such as when combining several feeds. The values of
+// every iterator are collected into one buffer that is sorted once and merged into this container with a single growth
+// of its array, instead of calling Update for each iterator. Equivalent values are ordered after the values in this
+// container, and then in the order of the iterators. This method will invalidate any previous indices.
+//
+func (self *FlatMultiSet[V]) UpdateAll(sources ...iter.Seq[V]) {
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.FlatMultiSet.UpdateAll", len(self.data))()
+    var buffer []V
+    for _, values := range sources {
+        for value := range values {
+            buffer = append(buffer, value)
+        }
+    }
+    if len(buffer) > 0 {
+        sort.SliceStable(buffer, func(lhs, rhs int) bool {return self.cmp(buffer[lhs], buffer[rhs])})
+        self.mergeSorted(&base[V]{cmp: self.cmp, data: buffer})
+    }
+}
+
+
+// Returns a FlatSet that takes the array of values from this FlatMultiSet without copying them. Values that are
+// repeated are removed in place, keeping the first of each equivalent value. This FlatMultiSet is left empty, so that
+// the two containers do not share the same array.
+//
+func (self *FlatMultiSet[V]) ToFlatSet() *FlatSet[V] {
+    out := &FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq, data: self.Release()}}
+    out.removeDuplicates()
+    return out
+}
+
+
+// Remove the values from this index (inclusive) upto this index (exclusive) and return them in a new FlatMultiSet with
+// the same comparison function, for example to move a range of values that are no longer used into an archive. The
+// range of values for a pair of values can be found with LowerBound. This method will invalidate any previous indices.
+//
+func (self *FlatMultiSet[V]) DrainRange(from, upto int) *FlatMultiSet[V] {
+    if debug {
+        defer self.guard.write()()
+    }
+    return &FlatMultiSet[V]{base[V]{cmp: self.cmp, eq: self.eq, data: self.drain(from, upto)}}
+}
+
+
+// Returns true if the values are sorted using the comparison function. This can only be false if the values were
+// modified outside of this container, such as the fields of a FlatMultiSet of pointers to structures, in which case the
+// search methods will return the wrong results until the FlatMultiSet is repaired.
+//
+func (self *FlatMultiSet[V]) IsSorted() bool {
+    for i := 1; i < len(self.data); i++ {
+        if self.cmp(self.data[i], self.data[i - 1]) {
+            return false
+        }
+    }
+    return true
+}
+
+
+// Sort the values in place using the comparison function, if the values are not already sorted because they were
+// modified outside of this container. Returns true if the values had to be repaired. The order of equivalent values is
+// maintained. This method will invalidate any previous indices if the values had to be repaired.
+//
+func (self *FlatMultiSet[V]) Repair() bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    if self.IsSorted() {
+        return false
+    }
+    self.unshare()
+    sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
+    return true
+}
+
+
+// Replace every value of this FlatMultiSet with the result of calling the function with the value, such as to
+// normalize the values. The order of the new values is checked in the same pass, and only if the function changed
+// their order are the values sorted again, maintaining the order of equivalent values. Returns true if the values had
+// to be sorted. This method will invalidate any previous indices if the values had to be sorted.
+//
+func (self *FlatMultiSet[V]) MapInPlace(fn func(V) V) bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.unshare()
+    sorted := true
+    for i := range self.data {
+        self.data[i] = fn(self.data[i])
+        if sorted && i > 0 && self.cmp(self.data[i], self.data[i - 1]) {
+            sorted = false
+        }
+    }
+    if sorted {
+        return false
+    }
+    sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
+    return true
+}
+
+
+// Return a new FlatMultiSet with the same comparison function containing only the values of this container for which
+// the predicate returns true. The values are already sorted so they are appended in a single pass without sorting. The
+// order of equivalent values is maintained. This method does not modify this container so it will not invalidate
+// previous indices.
+//
+func (self *FlatMultiSet[V]) Filter(pred func(V) bool) *FlatMultiSet[V] {
+    if debug {
+        defer self.guard.read()()
+    }
+    out := FlatMultiSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    for _, value := range self.data {
+        if pred(value) {
+            out.data = append(out.data, value)
+        }
+    }
+    return &out
+}
+
+
+// Returns true if this container and another FlatMultiSet contain the same number of each equivalent value, which is
+// when the values of both containers are equivalent at every index. The sizes are compared first so that containers of
+// different sizes are not walked at all. This method does not modify this container so it will not invalidate previous
+// indices.
+//
+func (self *FlatMultiSet[V]) Equal(other *FlatMultiSet[V]) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+    if len(self.data) != len(other.data) {
+        return false
+    }
+    if !sameCompare(self.cmp, other.cmp) && !sortedWith(other.data, self.cmp, false) {
+        other = InitFlatMultiSet[V](other.data, self.cmp)
+    }
+    for i := range self.data {
+        if self.cmp(self.data[i], other.data[i]) || self.cmp(other.data[i], self.data[i]) {
+            return false
+        }
+    }
+    return true
+}
+
+
+// Shared private method to encode the values of this container as a JSON array in sorted order. An empty container is
+// encoded as an empty array rather than null, and a container of bytes as an array of numbers rather than a string.
+//
+func (self *base[V]) marshalJSON() ([]byte, error) {
+    if self.data == nil {
+        return []byte("[]"), nil
+    } else if reflect.TypeFor[V]().Kind() != reflect.Uint8 {
+        return json.Marshal(self.data)
+    }
+
+    // a slice of bytes is encoded as a base64 string, so the values are encoded one at a time
+    buf := []byte{'['}
+    for i, value := range self.data {
+        if i > 0 {
+            buf = append(buf, ',')
+        }
+        encoded, err := json.Marshal(value)
+        if err != nil {
+            return nil, err
+        }
+        buf = append(buf, encoded...)
+    }
+    return append(buf, ']'), nil
+}
+
+
+// Implements json.Marshaler to encode this FlatSet as a JSON array of its values in sorted order. An empty FlatSet is
+// encoded as an empty array rather than null. The receiver is a value so that a FlatSet that is a field of a structure
+// is also encoded as an array when the structure is marshalled by value. A FlatSet of bytes is encoded as an array of
+// numbers rather than a base64 string.
+//
+func (self FlatSet[V]) MarshalJSON() ([]byte, error) {
+    return self.marshalJSON()
+}
+
+
+// Implements json.Marshaler to encode this FlatMultiSet as a JSON array of its values in sorted order. An empty
+// FlatMultiSet is encoded as an empty array rather than null. The receiver is a value so that a FlatMultiSet that is a
+// field of a structure is also encoded as an array when the structure is marshalled by value. A FlatMultiSet of bytes
+// is encoded as an array of numbers rather than a base64 string.
+//
+func (self FlatMultiSet[V]) MarshalJSON() ([]byte, error) {
+    return self.marshalJSON()
+}
+
+
+// Shared private method to decode a JSON array into the values of this container, replacing any previous values. The
+// values are sorted once using the comparison function, so the container must have been created with a comparison
+// function before it is decoded.
+//
+func (self *base[V]) unmarshalJSON(data []byte) error {
+    if self.cmp == nil {
+        return errors.New("flatset: container has no comparison function to decode a JSON array")
+    }
+    var values []V
+    if err := json.Unmarshal(data, &values); err != nil {
+        return err
+    }
+    sort.SliceStable(values, func(lhs, rhs int) bool {return self.cmp(values[lhs], values[rhs])})
+    self.data = values
+    self.shared = false
+    return nil
+}
+
+
+// Implements json.Unmarshaler to decode a JSON array into this FlatSet, replacing any previous values. The values do
+// not need to be sorted and any repeated values are discarded like InitFlatSet. The FlatSet must already have a
+// comparison function, such as a field made by MakeFlatSet before the structure is decoded, otherwise an error is
+// returned. This method will invalidate any previous indices.
+//
+func (self *FlatSet[V]) UnmarshalJSON(data []byte) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    if err := self.unmarshalJSON(data); err != nil {
+        return err
+    }
+    self.removeDuplicates()
+    return nil
+}
+
+
+// Implements json.Unmarshaler to decode a JSON array into this FlatMultiSet, replacing any previous values. The values
+// do not need to be sorted and the order of repeated values is kept. The FlatMultiSet must already have a comparison
+// function, such as a field made by MakeFlatMultiSet before the structure is decoded, otherwise an error is returned.
+// This method will invalidate any previous indices.
+//
+func (self *FlatMultiSet[V]) UnmarshalJSON(data []byte) error {
+    if debug {
+        defer self.guard.write()()
     }
+    return self.unmarshalJSON(data)
 }
@@ -1,6 +1,7 @@
 package flatset
 
 import (
+    "encoding/json"
     "math/rand"
     "slices"
     "strings"
@@ -12,6 +13,50 @@ func lessInt(lhs, rhs int) bool { return lhs < rhs }
 func greaterInt(lhs, rhs int) bool { return lhs > rhs }
 
 
+// Test the private insert method at every position of arrays with different sizes and spare capacity.
+//
+func TestInsertPositions(t *testing.T) {
+    for size := 0; size < 8; size++ {
+        for spare := 0; spare < 3; spare++ {
+            for ub := 0; ub <= size; ub++ {
+                fs := MakeFlatMultiSet[int](lessInt)
+                fs.data = make([]int, size, size + spare)
+                expected := make([]int, 0, size + 1)
+                for i := 0; i < size; i++ {
+                    fs.data[i] = i * 2
+                    if i == ub {
+                        expected = append(expected, -1)
+                    }
+                    expected = append(expected, i * 2)
+                }
+                if ub == size {
+                    expected = append(expected, -1)
+                }
+
+                fs.insert(ub, -1)
+                if !slices.Equal(fs.data, expected) {
+                    t.Errorf("insert(%d) size(%d) capacity(%d): expected(%v), actual(%v)", ub, size, size + spare,
+                             expected, fs.data)
+                }
+            }
+        }
+    }
+}
+
+
+// Test the Union method does not modify the original FlatSet when it has spare capacity.
+//
+func TestUnionAliasing(t *testing.T) {
+    fs := NewFlatSet[int](lessInt)
+    fs.data = append(make([]int, 0, 8), 1, 3, 5)
+
+    union := fs.Union(slices.Values([]int {2, 4}))
+    if !slices.Equal(fs.data, []int {1, 3, 5}) || !slices.Equal(union.data, []int {1, 2, 3, 4, 5}) {
+        t.Errorf("FlatSet.Union(): expected([1 3 5], [1 2 3 4 5]), actual(%v, %v)", fs.data, union.data)
+    }
+}
+
+
 // Test the LowerBound and UpperBound methods for the FlatSet.
 //
 func TestBoundsUniq(t *testing.T) {
@@ -54,6 +99,58 @@ func TestBoundMulti(t *testing.T) {
 }
 
 
+// Test the Search method for the FlatSet and FlatMultiSet.
+//
+func TestSearch(t *testing.T) {
+    type testData struct {
+        index int
+        found bool
+    }
+    fs := InitFlatSet[int]([]int {2, 4}, lessInt)
+    fm := InitFlatMultiSet[int]([]int {2, 2, 4}, lessInt)
+
+    for value, expected := range map[int]testData {1: {0, false}, 2: {0, true}, 3: {1, false}, 4: {1, true},
+                                                  5: {2, false}} {
+        index, found := fs.Search(value)
+        if index != expected.index || found != expected.found {
+            t.Errorf("FlatSet.Search(%d): expected(%d, %t), actual(%d, %t)", value, expected.index, expected.found,
+                     index, found)
+        }
+    }
+
+    for value, expected := range map[int]testData {1: {0, false}, 2: {0, true}, 3: {2, false}, 4: {2, true},
+                                                  5: {3, false}} {
+        index, found := fm.Search(value)
+        if index != expected.index || found != expected.found {
+            t.Errorf("FlatMultiSet.Search(%d): expected(%d, %t), actual(%d, %t)", value, expected.index,
+                     expected.found, index, found)
+        }
+    }
+}
+
+
+// Test a Finger returns the same results as Search when the values are close together or far apart.
+//
+func TestFinger(t *testing.T) {
+    fs := InitFlatMultiSet[int](randInt(0, 1000, 500), lessInt)
+    finger := fs.Finger()
+    values := append(randInt(-10, 1010, 500), 5, 6, 7, 1, 999, 1010, -10, 500, 499, 501)
+    for _, value := range values {
+        index, found := finger.Search(value)
+        expectedIndex, expectedFound := fs.Search(value)
+        if index != expectedIndex || found != expectedFound {
+            t.Errorf("Finger.Search(%d): expected(%d, %t), actual(%d, %t)", value, expectedIndex, expectedFound, index,
+                     found)
+        }
+    }
+
+    fs.Clear()
+    if finger.Contains(5) {
+        t.Errorf("Finger.Contains(5): expected(false), actual(true)")
+    }
+}
+
+
 // Test the Insert/Find/Replace methods for the FlatSet.
 //
 func TestInsertFindReplaceUniq(t *testing.T) {
@@ -185,6 +282,133 @@ func TestStableUniq(t *testing.T) {
 }
 
 
+// Test the EraseIndices method deletes the values at unordered and repeated indices.
+//
+func TestEraseIndices(t *testing.T) {
+    fs := InitFlatMultiSet[int]([]int {0, 1, 2, 2, 3, 4, 5}, lessInt)
+    fs.EraseIndices([]int {5, -1, 0, 3, 5, 9})
+
+    expected := []int {1, 2, 3, 5}
+    if actual := slices.Collect(fs.All()); !slices.Equal(actual, expected) {
+        t.Errorf("FlatMultiSet.EraseIndices(): expected(%v), actual(%v)", expected, actual)
+    }
+}
+
+
+// Test the RemoveIf method deletes the matching values in order and returns how many were removed.
+//
+func TestRemoveIf(t *testing.T) {
+    ms := InitFlatMultiSet[int]([]int {5, 0, 1, 2, 2, 3, 4}, lessInt)
+    var seen []int
+    removed := ms.RemoveIf(func(v int) bool {
+        seen = append(seen, v)
+        return v % 2 == 0
+    })
+    if expected := []int {1, 3, 5}; removed != 4 || !slices.Equal(ms.data, expected) {
+        t.Errorf("FlatMultiSet.RemoveIf(v %% 2 == 0): expected(4, %v), actual(%d, %v)", expected, removed, ms.data)
+    }
+    if expected := []int {0, 1, 2, 2, 3, 4, 5}; !slices.Equal(seen, expected) {
+        t.Errorf("FlatMultiSet.RemoveIf(): expected(%v) calls, actual(%v)", expected, seen)
+    }
+
+    fs := InitFlatSet[int]([]int {1, 2, 3}, lessInt)
+    if removed := fs.RemoveIf(func(v int) bool { return v > 3 }); removed != 0 || fs.Size() != 3 {
+        t.Errorf("FlatSet.RemoveIf(v > 3): expected(0), actual(%d)", removed)
+    }
+}
+
+
+// Test the RetainIf method keeps only the matching values and returns how many were removed.
+//
+func TestRetainIf(t *testing.T) {
+    fs := InitFlatSet[int]([]int {5, 0, 1, 2, 3, 4}, lessInt)
+    removed := fs.RetainIf(func(v int) bool { return v >= 3 })
+    if !slices.Equal(fs.data, []int {3, 4, 5}) || removed != 3 {
+        t.Errorf("FlatSet.RetainIf(v >= 3): expected(3, [3 4 5]), actual(%d, %v)", removed, fs.data)
+    }
+    if tail := fs.data[len(fs.data):6]; slices.ContainsFunc(tail, func(value int) bool {return value != 0}) {
+        t.Errorf("FlatSet.RetainIf(v >= 3): expected(zeroed tail), actual(%v)", tail)
+    }
+    if removed := fs.RetainIf(func(v int) bool { return false }); removed != 3 || fs.Size() != 0 {
+        t.Errorf("FlatSet.RetainIf(false): expected(3, []), actual(%d, %v)", removed, fs.data)
+    }
+}
+
+
+// Test EraseRange removes the values between two lower bounds and zeroes the vacated slots.
+//
+func TestEraseRange(t *testing.T) {
+    values := []int {0, 1, 2, 3, 4, 5}
+    fs := NewFlatSet[*int](func(a, b *int) bool { return *a < *b })
+    for i := range values {
+        fs.Insert(&values[i])
+    }
+    fs.EraseRange(fs.LowerBound(&values[1]), fs.LowerBound(&values[4]))
+
+    expected := []int {0, 4, 5}
+    actual := []int {}
+    for value := range fs.All() {
+        actual = append(actual, *value)
+    }
+    if !slices.Equal(actual, expected) {
+        t.Errorf("FlatSet.EraseRange(1, 4): expected(%v), actual(%v)", expected, actual)
+    }
+    if tail := fs.data[len(fs.data):len(values)]; slices.ContainsFunc(tail, func(p *int) bool { return p != nil }) {
+        t.Errorf("FlatSet.EraseRange(1, 4): expected(zeroed slots), actual(%v)", tail)
+    }
+    fs.EraseRange(1, 1)
+    if fs.Size() != 3 {
+        t.Errorf("FlatSet.EraseRange(1, 1): expected(3), actual(%d)", fs.Size())
+    }
+}
+
+
+// Test that sorted values are merged keeping the existing equivalent values first, by Merge and the Update fast path.
+//
+func TestMergeSortedStable(t *testing.T) {
+    update := InitFlatMultiSet[stableData](stableUpdate, stableCompare)
+
+    fs, fs2 := InitFlatSet[stableData](stableInit, stableCompare), InitFlatSet[stableData](stableInit, stableCompare)
+    fs.Merge(InitFlatSet[stableData](stableUpdate, stableCompare))
+    fs2.Update(update.All())
+    expected := []stableData {{1, 6}, {2, 2}, {3, 8}, {4, 0}, {5, 9}}
+    if !slices.Equal(fs.data, expected) || !slices.Equal(fs2.data, expected) {
+        t.Errorf("FlatSet sorted merge not stable expected(%+v), actual(%+v, %+v)", expected, fs.data, fs2.data)
+    }
+
+    fm, fm2 := InitFlatMultiSet[stableData](stableInit, stableCompare), InitFlatMultiSet[stableData](stableInit,
+                                                                                                   stableCompare)
+    fm.Merge(update)
+    fm2.Update(update.All())
+    expected = []stableData {{1, 6}, {2, 2}, {2, 4}, {2, 5}, {2, 10}, {3, 8}, {4, 0}, {4, 3}, {4, 7}, {5, 9}}
+    if !slices.Equal(fm.data, expected) || !slices.Equal(fm2.data, expected) {
+        t.Errorf("FlatMultiSet sorted merge not stable expected(%+v), actual(%+v, %+v)", expected, fm.data, fm2.data)
+    }
+}
+
+
+// Test that Merge is done in place when this container has the capacity for both containers.
+//
+func TestMergeInPlace(t *testing.T) {
+    fm := NewFlatMultiSet[stableData](stableCompare)
+    fm.data = append(make([]stableData, 0, 16), InitFlatMultiSet[stableData](stableInit, stableCompare).data...)
+    array := &fm.data[:1][0]
+
+    fm.Merge(InitFlatMultiSet[stableData](stableUpdate, stableCompare))
+    expected := []stableData {{1, 6}, {2, 2}, {2, 4}, {2, 5}, {2, 10}, {3, 8}, {4, 0}, {4, 3}, {4, 7}, {5, 9}}
+    if !slices.Equal(fm.data, expected) {
+        t.Errorf("FlatMultiSet.Merge() in place expected(%+v), actual(%+v)", expected, fm.data)
+    } else if &fm.data[0] != array {
+        t.Errorf("FlatMultiSet.Merge() reallocated the array")
+    }
+
+    fm.Merge(fm)
+    if fm.Size() != 2 * len(expected) {
+        t.Errorf("FlatMultiSet.Merge() with itself: expected(%d), actual(%d)", 2 * len(expected), fm.Size())
+    }
+}
+
+
 // Test the order stability of a FlatMultiSet.
 //
 func TestStableMulti(t *testing.T) {
@@ -224,6 +448,33 @@ func TestStableMulti(t *testing.T) {
 }
 
 
+// Test the Update method inserts blocks of sorted, reversed and random values in the same order as inserting each value.
+//
+func TestUpdateBlocks(t *testing.T) {
+    initial := randInt(0, 200, 100)
+    sorted := slices.Sorted(slices.Values(randInt(0, 200, 100)))
+    reversed := slices.Clone(sorted)
+    slices.Reverse(reversed)
+    for _, values := range [][]int {sorted, reversed, randInt(0, 200, 100), {-5, -5, -3, 500}} {
+        fs, expectedFs := InitFlatSet[int](initial, lessInt), InitFlatSet[int](initial, lessInt)
+        fm, expectedFm := InitFlatMultiSet[int](initial, lessInt), InitFlatMultiSet[int](initial, lessInt)
+        for _, value := range values {
+            expectedFs.Insert(value)
+            expectedFm.Insert(value)
+        }
+
+        fs.Update(slices.Values(values))
+        if !slices.Equal(fs.data, expectedFs.data) {
+            t.Errorf("FlatSet.Update(%v): expected(%v), actual(%v)", values, expectedFs.data, fs.data)
+        }
+        fm.Update(slices.Values(values))
+        if !slices.Equal(fm.data, expectedFm.data) {
+            t.Errorf("FlatMultiSet.Update(%v): expected(%v), actual(%v)", values, expectedFm.data, fm.data)
+        }
+    }
+}
+
+
 // Test the HasAny/HasAll/Union/Intersection/Difference methods of a FlatSet.
 //
 func TestSetOperations(t *testing.T) {
@@ -272,12 +523,1192 @@ func TestSetOperations(t *testing.T) {
 }
 
 
+// Test the ContainsEach method returns the membership of each value in the order they were given.
+//
+func TestContainsEach(t *testing.T) {
+    fs := InitFlatMultiSet[int]([]int {2, 4, 4, 5}, lessInt)
+    values := []int {5, 1, 4, 6, 2, 3, 4}
+    expected := []bool {true, false, true, false, true, false, true}
+
+    i := 0
+    for value, found := range fs.ContainsEach(slices.Values(values)) {
+        if value != values[i] || found != expected[i] {
+            t.Errorf("FlatMultiSet.ContainsEach(): expected(%d, %t), actual(%d, %t)", values[i], expected[i], value,
+                     found)
+        }
+        i++
+    }
+    if i != len(values) {
+        t.Errorf("FlatMultiSet.ContainsEach(): expected(%d) values, actual(%d)", len(values), i)
+    }
+}
+
+
+// Test the IntersectionCount/UnionCount/DifferenceCount/SymmetricDifferenceCount/Equal methods of a FlatSet.
+//
+func TestSetCounts(t *testing.T) {
+    fs := InitFlatSet[int]([]int {2, 4, 5}, lessInt)
+
+    for _, other := range []*FlatSet[int] {InitFlatSet[int]([]int {2, 3, 5, 6}, lessInt),
+                                          InitFlatSet[int]([]int {2, 3, 5, 6}, greaterInt)} {
+        if actual := fs.IntersectionCount(other); actual != 2 {
+            t.Errorf("FlatSet.IntersectionCount(): expected(2), actual(%d)", actual)
+        }
+        if actual := fs.UnionCount(other); actual != 5 {
+            t.Errorf("FlatSet.UnionCount(): expected(5), actual(%d)", actual)
+        }
+        if actual := fs.DifferenceCount(other); actual != 1 {
+            t.Errorf("FlatSet.DifferenceCount(): expected(1), actual(%d)", actual)
+        }
+        if actual := fs.SymmetricDifferenceCount(other); actual != 3 {
+            t.Errorf("FlatSet.SymmetricDifferenceCount(): expected(3), actual(%d)", actual)
+        }
+        if fs.Equal(other) {
+            t.Errorf("FlatSet.Equal(): expected(false), actual(true)")
+        }
+    }
+
+    for _, other := range []*FlatSet[int] {InitFlatSet[int]([]int {5, 4, 2}, lessInt),
+                                          InitFlatSet[int]([]int {5, 4, 2}, greaterInt)} {
+        if !fs.Equal(other) || fs.SymmetricDifferenceCount(other) != 0 {
+            t.Errorf("FlatSet.Equal(): expected(true, 0), actual(%t, %d)", fs.Equal(other),
+                     fs.SymmetricDifferenceCount(other))
+        }
+    }
+    if other := InitFlatSet[int]([]int {2, 4, 6}, lessInt); fs.Equal(other) || fs.SymmetricDifferenceCount(other) != 2 {
+        t.Errorf("FlatSet.Equal(): expected(false, 2), actual(%t, %d)", fs.Equal(other),
+                 fs.SymmetricDifferenceCount(other))
+    }
+}
+
+
+// Test the Union and Intersection methods allocate the resulting array once, and Intersection returns the original
+// values in order when the other values are unordered and repeated.
+//
+func TestSetOperationSizes(t *testing.T) {
+    fs := InitFlatSet[stableData](stableInit, stableCompare)
+    other := []stableData {{4, 7}, {2, 10}, {4, 7}, {3, 8}}
+
+    intersection := fs.Intersection(slices.Values(other))
+    expected := []stableData {{2, 2}, {4, 0}}
+    if !slices.Equal(intersection.data, expected) || cap(intersection.data) != len(expected) {
+        t.Errorf("FlatSet.Intersection(): expected(%+v), actual(%+v)", expected, intersection.data)
+    }
+
+    union := fs.Union(slices.Values(other), 4)
+    expected = []stableData {{1, 6}, {2, 2}, {3, 8}, {4, 0}}
+    if !slices.Equal(union.data, expected) || cap(union.data) != len(expected) {
+        t.Errorf("FlatSet.Union(): expected(%+v), actual(%+v) with capacity(%d)", expected, union.data,
+                 cap(union.data))
+    }
+}
+
+
+// Test the Difference method with a large overlap of unordered and repeated values.
+//
+func TestDifferenceOverlap(t *testing.T) {
+    values := make([]int, 0, 100)
+    expected := make([]int, 0, 50)
+    for i := 0; i < 100; i++ {
+        values = append(values, i)
+        if i % 2 == 1 {
+            expected = append(expected, i)
+        }
+    }
+    fs := InitFlatSet[int](values, lessInt)
+
+    other := []int {100, -1}
+    for i := 98; i >= 0; i -= 2 {
+        other = append(other, i, i)
+    }
+    if actual := slices.Collect(fs.Difference(slices.Values(other)).All()); !slices.Equal(actual, expected) {
+        t.Errorf("FlatSet.Difference(): expected(%v), actual(%v)", expected, actual)
+    }
+    if fs.Size() != 100 {
+        t.Errorf("FlatSet.Difference() modified this container")
+    }
+}
+
+
+// Test the SymmetricDifference method with unordered and repeated values on both sides of this container.
+//
+func TestSymmetricDifference(t *testing.T) {
+    fs := InitFlatSet[int]([]int {1, 3, 5, 7, 9}, lessInt)
+    for _, test := range []struct {
+        values []int
+        expected []int
+    }{
+        {[]int {}, []int {1, 3, 5, 7, 9}},
+        {[]int {9, 0, 3, 3, 4, 10, 0}, []int {0, 1, 4, 5, 7, 10}},
+        {[]int {1, 3, 5, 7, 9}, []int {}},
+        {[]int {2, 2, 4}, []int {1, 2, 3, 4, 5, 7, 9}},
+    } {
+        actual := slices.Collect(fs.SymmetricDifference(slices.Values(test.values)).All())
+        if !slices.Equal(actual, test.expected) {
+            t.Errorf("FlatSet.SymmetricDifference(%v): expected(%v), actual(%v)", test.values, test.expected, actual)
+        }
+    }
+    if fs.Size() != 5 {
+        t.Errorf("FlatSet.SymmetricDifference() modified this container")
+    }
+}
+
+
+// Test the IsSubsetOf and IsSupersetOf methods, including sets with different comparison functions.
+//
+func TestSubset(t *testing.T) {
+    fs := InitFlatSet[int]([]int {1, 3, 5, 7, 9}, lessInt)
+    for _, test := range []struct {
+        values []int
+        subset bool
+        superset bool
+    }{
+        {[]int {}, false, true},
+        {[]int {3, 9}, false, true},
+        {[]int {1, 3, 5, 7, 9}, true, true},
+        {[]int {0, 1, 3, 5, 7, 9, 10}, true, false},
+        {[]int {1, 3, 4}, false, false},
+        {[]int {1, 2, 3, 5, 7, 8, 9}, true, false},
+    } {
+        other := InitFlatSet[int](test.values, func(lhs, rhs int) bool {return lhs > rhs})
+        if actual := fs.IsSubsetOf(other); actual != test.subset {
+            t.Errorf("FlatSet.IsSubsetOf(%v): expected(%v), actual(%v)", test.values, test.subset, actual)
+        }
+        if actual := fs.IsSupersetOf(other); actual != test.superset {
+            t.Errorf("FlatSet.IsSupersetOf(%v): expected(%v), actual(%v)", test.values, test.superset, actual)
+        }
+    }
+    empty := NewFlatSet[int](lessInt)
+    if !empty.IsSubsetOf(fs) || !empty.IsSupersetOf(empty) || empty.IsSupersetOf(fs) {
+        t.Errorf("FlatSet.IsSubsetOf(): expected(empty set is a subset)")
+    }
+}
+
+
+// Test the Disjoint and IsDisjointFrom methods return true only when no values are shared.
+//
+func TestDisjoint(t *testing.T) {
+    fs := InitFlatSet[int]([]int {1, 3, 5, 7, 9}, lessInt)
+    for _, test := range []struct {
+        values []int
+        expected bool
+    }{
+        {[]int {}, true},
+        {[]int {10, 0, 4, 2}, true},
+        {[]int {8, 6, 9}, false},
+        {[]int {1}, false},
+        {[]int {-1, 2, 4, 6, 8, 10}, true},
+    } {
+        if actual := fs.Disjoint(slices.Values(test.values)); actual != test.expected {
+            t.Errorf("FlatSet.Disjoint(%v): expected(%v), actual(%v)", test.values, test.expected, actual)
+        }
+        other := InitFlatSet[int](test.values, func(lhs, rhs int) bool {return lhs > rhs})
+        if actual := fs.IsDisjointFrom(other); actual != test.expected {
+            t.Errorf("FlatSet.IsDisjointFrom(%v): expected(%v), actual(%v)", test.values, test.expected, actual)
+        }
+    }
+    ms := InitFlatMultiSet[int]([]int {2, 2, 4}, lessInt)
+    if !ms.Disjoint(fs.All()) || ms.Disjoint(slices.Values([]int {4})) {
+        t.Errorf("FlatMultiSet.Disjoint(): expected(true, false)")
+    }
+}
+
+
+// Test the IntersectUpdate and DifferenceUpdate methods match the values of Intersection and Difference.
+//
+func TestSetUpdates(t *testing.T) {
+    for _, values := range [][]int {{}, {9, 0, 3, 3, 4, 10}, {1, 3, 5, 7, 9}, {2, 4}} {
+        fs := InitFlatSet[int]([]int {1, 3, 5, 7, 9}, lessInt)
+        expected := slices.Collect(fs.Intersection(slices.Values(values)).All())
+        removed := fs.IntersectUpdate(slices.Values(values))
+        if actual := slices.Collect(fs.All()); !slices.Equal(actual, expected) || removed != 5 - len(expected) {
+            t.Errorf("FlatSet.IntersectUpdate(%v): expected(%v, %d), actual(%v, %d)", values, expected,
+                5 - len(expected), actual, removed)
+        }
+
+        fs = InitFlatSet[int]([]int {1, 3, 5, 7, 9}, lessInt)
+        expected = slices.Collect(fs.Difference(slices.Values(values)).All())
+        removed = fs.DifferenceUpdate(slices.Values(values))
+        if actual := slices.Collect(fs.All()); !slices.Equal(actual, expected) || removed != 5 - len(expected) {
+            t.Errorf("FlatSet.DifferenceUpdate(%v): expected(%v, %d), actual(%v, %d)", values, expected,
+                5 - len(expected), actual, removed)
+        }
+        if tail := fs.data[len(fs.data):5]; slices.ContainsFunc(tail, func(value int) bool {return value != 0}) {
+            t.Errorf("FlatSet.DifferenceUpdate(%v): expected(zeroed tail), actual(%v)", values, tail)
+        }
+    }
+}
+
+
 type person struct {
     age int
     name string
 }
 
 
+// Test the methods that take an iterator of values work for an empty container.
+//
+func TestEmptyIterators(t *testing.T) {
+    fs := NewFlatSet[int](lessInt)
+    if fs.HasAll(slices.Values([]int {1})) || fs.HasAny(slices.Values([]int {1})) {
+        t.Errorf("FlatSet.HasAll(): expected(false), actual(true)")
+    }
+    count := 0
+    for value, found := range fs.ContainsEach(slices.Values([]int {2, 1})) {
+        if found {
+            t.Errorf("FlatSet.ContainsEach(): expected(%d, false), actual(%d, true)", value, value)
+        }
+        count++
+    }
+    if count != 2 {
+        t.Errorf("FlatSet.ContainsEach(): expected(2) values, actual(%d)", count)
+    }
+
+    fs.Update(slices.Values([]int {3, 1, 2, 1}))
+    if !slices.Equal(fs.data, []int {1, 2, 3}) {
+        t.Errorf("FlatSet.Update(): expected([1 2 3]), actual(%v)", fs.data)
+    }
+    ms := NewFlatMultiSet[int](lessInt)
+    ms.Update(slices.Values([]int {3, 1, 2, 1}))
+    if !slices.Equal(ms.data, []int {1, 1, 2, 3}) {
+        t.Errorf("FlatMultiSet.Update(): expected([1 1 2 3]), actual(%v)", ms.data)
+    }
+}
+
+
+// Test an equality function that is stricter than the comparison function decides which values are duplicates.
+//
+func TestSetEquals(t *testing.T) {
+    type keyed struct {
+        key int
+        payload string
+    }
+    lessKey := func(lhs, rhs keyed) bool { return lhs.key < rhs.key }
+    fs := NewFlatSet(lessKey)
+    fs.SetEquals(func(lhs, rhs keyed) bool { return lhs == rhs })
+
+    fs.Update(slices.Values([]keyed {{2, "b"}, {1, "a"}, {2, "c"}, {2, "b"}, {1, "a"}}))
+    expected := []keyed {{1, "a"}, {2, "b"}, {2, "c"}}
+    if !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.Update(): expected(%v), actual(%v)", expected, fs.data)
+    }
+    if index, inserted := fs.Insert(keyed {2, "c"}); inserted || index != 2 {
+        t.Errorf("FlatSet.Insert({2 c}): expected(2, false), actual(%d, %t)", index, inserted)
+    }
+    if index, inserted := fs.Insert(keyed {2, "d"}); !inserted || index != 3 {
+        t.Errorf("FlatSet.Insert({2 d}): expected(3, true), actual(%d, %t)", index, inserted)
+    }
+    if fs.Contains(keyed {1, "z"}) || !fs.Contains(keyed {2, "d"}) || fs.Find(keyed {2, "c"}) != 2 {
+        t.Errorf("FlatSet.Contains(): expected the payload to be compared")
+    }
+    if !fs.Remove(keyed {2, "b"}) || fs.Find(keyed {2, "c"}) != 1 {
+        t.Errorf("FlatSet.Remove({2 b}): expected(true), actual(false)")
+    }
+
+    other := InitFlatSet([]keyed {{1, "a"}, {1, "b"}}, func(lhs, rhs keyed) bool { return lhs.key > rhs.key })
+    fs.Merge(other)
+    if expected := []keyed {{1, "a"}, {2, "c"}, {2, "d"}}; !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.Merge(): expected(%v), actual(%v)", expected, fs.data)
+    }
+}
+
+
+// Test the InsertRef methods return a pointer to the stored value that can be used to modify its payload.
+//
+func TestInsertRef(t *testing.T) {
+    fs := NewFlatSet(lessVersion)
+    fs.Insert(version{1, 0})
+    ref, inserted := fs.InsertRef(version{2, 0})
+    ref.rev = 5
+    if !inserted || fs.At(1) != (version{2, 5}) {
+        t.Errorf("FlatSet.InsertRef({2 0}): expected({2 5}, true), actual(%v, %t)", fs.At(1), inserted)
+    }
+    if ref, inserted = fs.InsertRef(version{1, 9}); inserted || *ref != (version{1, 0}) {
+        t.Errorf("FlatSet.InsertRef({1 9}): expected({1 0}, false), actual(%v, %t)", *ref, inserted)
+    }
+
+    ms := NewFlatMultiSet(lessVersion)
+    ms.Insert(version{1, 0})
+    ms.InsertRef(version{1, 0}).rev = 7
+    if expected := []version {{1, 0}, {1, 7}}; !slices.Equal(ms.data, expected) {
+        t.Errorf("FlatMultiSet.InsertRef(): expected(%v), actual(%v)", expected, ms.data)
+    }
+}
+
+
+// Test the AtRef method modifies the value stored in the container.
+//
+func TestAtRef(t *testing.T) {
+    fs := InitFlatSet([]version {{1, 0}, {2, 0}}, lessVersion)
+    fs.AtRef(1).rev = 3
+    if fs.At(1) != (version{2, 3}) {
+        t.Errorf("FlatSet.AtRef(1): expected({2 3}), actual(%v)", fs.At(1))
+    }
+    ms := InitFlatMultiSet([]version {{1, 0}, {1, 1}}, lessVersion)
+    ms.AtRef(0).rev = 4
+    if ms.At(0) != (version{1, 4}) {
+        t.Errorf("FlatMultiSet.AtRef(0): expected({1 4}), actual(%v)", ms.At(0))
+    }
+}
+
+
+// Test the TryAt method returns false for indices that are out of range.
+//
+func TestTryAt(t *testing.T) {
+    fs := InitFlatSet([]int {10, 20}, lessInt)
+    for index, expected := range map[int]int {-1: 0, 0: 10, 1: 20, 2: 0} {
+        actual, ok := fs.TryAt(index)
+        if actual != expected || ok != (expected != 0) {
+            t.Errorf("FlatSet.TryAt(%d): expected(%d, %t), actual(%d, %t)", index, expected, expected != 0, actual, ok)
+        }
+    }
+    if _, ok := fs.TryAt(fs.Find(15)); ok {
+        t.Errorf("FlatSet.TryAt(FlatSet.Find(15)): expected(false), actual(true)")
+    }
+}
+
+
+// Test the Release method returns the sorted array and empties the container without sharing the array.
+//
+func TestRelease(t *testing.T) {
+    fs := InitFlatSet([]int {3, 1, 2}, lessInt)
+    data := fs.Release()
+    if !slices.Equal(data, []int {1, 2, 3}) || fs.Size() != 0 {
+        t.Errorf("FlatSet.Release(): expected([1 2 3], 0), actual(%v, %d)", data, fs.Size())
+    }
+    fs.Insert(0)
+    if !slices.Equal(data, []int {1, 2, 3}) {
+        t.Errorf("FlatSet.Insert(0): expected the released array to be unchanged, actual(%v)", data)
+    }
+}
+
+
+// Test the AsMultiSet and ToFlatSet methods convert between the containers without copying the array.
+//
+func TestConversions(t *testing.T) {
+    fs := InitFlatSet([]int {3, 1, 2}, lessInt)
+    data := fs.data
+    ms := fs.AsMultiSet()
+    if fs.Size() != 0 || &ms.data[0] != &data[0] {
+        t.Errorf("FlatSet.AsMultiSet(): expected the array to be moved")
+    }
+    ms.Update(slices.Values([]int {2, 3, 3}))
+    if !slices.Equal(ms.data, []int {1, 2, 2, 3, 3, 3}) {
+        t.Errorf("FlatMultiSet.Update(): expected([1 2 2 3 3 3]), actual(%v)", ms.data)
+    }
+    data = ms.data
+    fs = ms.ToFlatSet()
+    if ms.Size() != 0 || &fs.data[0] != &data[0] || !slices.Equal(fs.data, []int {1, 2, 3}) {
+        t.Errorf("FlatMultiSet.ToFlatSet(): expected([1 2 3]), actual(%v)", fs.data)
+    }
+}
+
+
+// Test the PushBack and PushFront methods only insert values at the ends of the containers.
+//
+func TestPushBackFront(t *testing.T) {
+    fs := NewFlatSet[int](lessInt)
+    for _, test := range []struct {
+        push func(int) error
+        value int
+        err error
+    } {
+        {fs.PushBack, 5, nil}, {fs.PushBack, 7, nil}, {fs.PushBack, 6, ErrOutOfOrder}, {fs.PushBack, 7, ErrDuplicate},
+        {fs.PushFront, 3, nil}, {fs.PushFront, 4, ErrOutOfOrder}, {fs.PushFront, 3, ErrDuplicate},
+    } {
+        if err := test.push(test.value); err != test.err {
+            t.Errorf("FlatSet.Push(%d): expected(%v), actual(%v)", test.value, test.err, err)
+        }
+    }
+    if !slices.Equal(fs.data, []int {3, 5, 7}) {
+        t.Errorf("FlatSet.Push(): expected([3 5 7]), actual(%v)", fs.data)
+    }
+
+    ms := NewFlatMultiSet[int](lessInt)
+    for _, test := range []struct {
+        push func(int) error
+        value int
+        err error
+    } {
+        {ms.PushFront, 5, nil}, {ms.PushBack, 5, nil}, {ms.PushBack, 4, ErrOutOfOrder}, {ms.PushFront, 5, ErrOutOfOrder},
+        {ms.PushFront, 1, nil},
+    } {
+        if err := test.push(test.value); err != test.err {
+            t.Errorf("FlatMultiSet.Push(%d): expected(%v), actual(%v)", test.value, test.err, err)
+        }
+    }
+    if !slices.Equal(ms.data, []int {1, 5, 5}) {
+        t.Errorf("FlatMultiSet.Push(): expected([1 5 5]), actual(%v)", ms.data)
+    }
+}
+
+
+// Test the DrainRange methods move a range of values into a new container.
+//
+func TestDrainRange(t *testing.T) {
+    fs := InitFlatSet([]int {1, 2, 3, 4, 5}, lessInt)
+    drained := fs.DrainRange(fs.LowerBound(2), fs.LowerBound(4))
+    if !slices.Equal(drained.data, []int {2, 3}) || !slices.Equal(fs.data, []int {1, 4, 5}) {
+        t.Errorf("FlatSet.DrainRange(1, 3): expected([2 3], [1 4 5]), actual(%v, %v)", drained.data, fs.data)
+    }
+    drained.Insert(0)
+    if !slices.Equal(fs.data, []int {1, 4, 5}) {
+        t.Errorf("FlatSet.DrainRange(1, 3): expected the arrays not to be shared, actual(%v)", fs.data)
+    }
+
+    ms := InitFlatMultiSet([]int {1, 2, 2, 3}, lessInt)
+    from, upto := ms.Find(2)
+    if drained := ms.DrainRange(from, upto); !slices.Equal(drained.data, []int {2, 2}) ||
+                                             !slices.Equal(ms.data, []int {1, 3}) {
+        t.Errorf("FlatMultiSet.DrainRange(1, 3): expected([2 2], [1 3]), actual(%v, %v)", drained.data, ms.data)
+    }
+}
+
+
+// Test the Upsert method replaces the value with the same key or inserts a new value.
+//
+func TestUpsert(t *testing.T) {
+    fs := InitFlatSet([]version {{1, 0}, {3, 0}}, lessVersion)
+    for _, test := range []struct {
+        value version
+        index int
+        inserted bool
+    } {
+        {version{3, 1}, 1, false}, {version{2, 1}, 1, true}, {version{1, 2}, 0, false}, {version{4, 0}, 3, true},
+    } {
+        if index, inserted := fs.Upsert(test.value); index != test.index || inserted != test.inserted {
+            t.Errorf("FlatSet.Upsert(%v): expected(%d, %t), actual(%d, %t)", test.value, test.index, test.inserted,
+                     index, inserted)
+        }
+    }
+    if expected := []version {{1, 2}, {2, 1}, {3, 1}, {4, 0}}; !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.Upsert(): expected(%v), actual(%v)", expected, fs.data)
+    }
+}
+
+
+// Test the IsSorted and Repair methods detect and fix values that were modified outside of the containers.
+//
+func TestRepair(t *testing.T) {
+    keith, mick, ronnie := &person{80, "Keith"}, &person{81, "Mick"}, &person{77, "Ronnie"}
+    fs := InitFlatSet([]*person{keith, mick, ronnie}, comparePeople)
+    ms := InitFlatMultiSet([]*person{keith, mick, ronnie}, comparePeople)
+    if !fs.IsSorted() || !ms.IsSorted() || fs.Repair() || ms.Repair() {
+        t.Errorf("FlatSet.IsSorted(): expected(true, true), actual(%t, %t)", fs.IsSorted(), ms.IsSorted())
+    }
+
+    keith.age, keith.name = 81, "Mick"
+    if fs.IsSorted() || !ms.IsSorted() {
+        t.Errorf("FlatSet.IsSorted(): expected(false, true), actual(%t, %t)", fs.IsSorted(), ms.IsSorted())
+    }
+    ronnie.age = 90
+    if fs.IsSorted() || ms.IsSorted() {
+        t.Errorf("FlatSet.IsSorted(): expected(false, false), actual(%t, %t)", fs.IsSorted(), ms.IsSorted())
+    }
+    if !fs.Repair() || !fs.IsSorted() || fs.Size() != 2 || fs.At(0) != ronnie || fs.At(1) != mick {
+        t.Errorf("FlatSet.Repair(): expected([Ronnie Mick]), actual(%v)", fs.data)
+    }
+    if !ms.Repair() || !ms.IsSorted() || ms.Size() != 3 || ms.At(0) != ronnie || ms.At(1) != mick {
+        t.Errorf("FlatMultiSet.Repair(): expected([Ronnie Mick Mick]), actual(%v)", ms.data)
+    }
+}
+
+
+// Test the ReplaceSlice method only replaces blocks that keep the values in order.
+//
+func TestReplaceSlice(t *testing.T) {
+    type testData struct {
+        from int
+        values []int
+        success bool
+    }
+    for _, test := range []testData {{1, []int {3, 4}, true}, {0, []int {0, 2, 5}, true}, {2, []int {6, 8}, true},
+                                     {1, []int {4, 3}, false}, {1, []int {3, 3}, false}, {1, []int {1, 4}, false},
+                                     {1, []int {3, 7}, false}, {2, []int {6, 8, 9}, false}, {-1, []int {0}, false},
+                                     {3, nil, true}} {
+        fs := InitFlatSet([]int {1, 2, 5, 7}, lessInt)
+        expected := slices.Clone(fs.data)
+        if test.success {
+            copy(expected[test.from:], test.values)
+        }
+        success := fs.ReplaceSlice(test.from, test.values)
+        if success != test.success || !slices.Equal(fs.data, expected) {
+            t.Errorf("FlatSet.ReplaceSlice(%d, %v): expected(%t, %v), actual(%t, %v)", test.from, test.values,
+                     test.success, expected, success, fs.data)
+        }
+    }
+
+    ms := InitFlatMultiSet([]int {1, 2, 5, 7}, lessInt)
+    if !ms.ReplaceSlice(1, []int {1, 5}) || !slices.Equal(ms.data, []int {1, 1, 5, 7}) {
+        t.Errorf("FlatMultiSet.ReplaceSlice(1, [1 5]): expected(true, [1 1 5 7]), actual(%v)", ms.data)
+    }
+    if ms.ReplaceSlice(1, []int {2, 1}) || ms.ReplaceSlice(2, []int {0, 8}) ||
+       !slices.Equal(ms.data, []int {1, 1, 5, 7}) {
+        t.Errorf("FlatMultiSet.ReplaceSlice(): expected(false, [1 1 5 7]), actual(%v)", ms.data)
+    }
+}
+
+
+// Test the MapInPlace method only sorts the values again when the function changes their order.
+//
+func TestMapInPlace(t *testing.T) {
+    fs := InitFlatSet([]int {1, 2, 3, 4}, lessInt)
+    if fs.MapInPlace(func(v int) int { return v * 10 }) || !slices.Equal(fs.data, []int {10, 20, 30, 40}) {
+        t.Errorf("FlatSet.MapInPlace(v * 10): expected(false, [10 20 30 40]), actual(%v)", fs.data)
+    }
+    if !fs.MapInPlace(func(v int) int { return (v / 10) % 3 }) || !slices.Equal(fs.data, []int {0, 1, 2}) {
+        t.Errorf("FlatSet.MapInPlace(v %% 3): expected(true, [0 1 2]), actual(%v)", fs.data)
+    }
+
+    people := InitFlatSet([]person {{30, "b"}, {20, "a"}, {20, "c"}}, func(a, b person) bool { return a.age > b.age })
+    older := func(p person) person { p.age = 50; return p }
+    if !people.MapInPlace(older) || people.Size() != 1 || people.At(0).name != "b" {
+        t.Errorf("FlatSet.MapInPlace(): expected([{50 b}]), actual(%v)", people.data)
+    }
+
+    ms := InitFlatMultiSet([]int {1, 2, 3, 4}, lessInt)
+    if ms.MapInPlace(func(v int) int { return v / 2 }) || !slices.Equal(ms.data, []int {0, 1, 1, 2}) {
+        t.Errorf("FlatMultiSet.MapInPlace(v / 2): expected(false, [0 1 1 2]), actual(%v)", ms.data)
+    }
+    if !ms.MapInPlace(func(v int) int { return -v }) || !slices.Equal(ms.data, []int {-2, -1, -1, 0}) {
+        t.Errorf("FlatMultiSet.MapInPlace(-v): expected(true, [-2 -1 -1 0]), actual(%v)", ms.data)
+    }
+}
+
+
+// Test the Filter method keeps the matching values in order without modifying this container.
+//
+func TestFilter(t *testing.T) {
+    fs := InitFlatSet([]int {1, 2, 3, 4, 5, 6}, lessInt)
+    even := fs.Filter(func(v int) bool { return v % 2 == 0 })
+    if !slices.Equal(even.data, []int {2, 4, 6}) || fs.Size() != 6 {
+        t.Errorf("FlatSet.Filter(v %% 2 == 0): expected([2 4 6]), actual(%v)", even.data)
+    }
+    if even.Insert(3); !slices.Equal(even.data, []int {2, 3, 4, 6}) {
+        t.Errorf("FlatSet.Filter(): expected(same comparison function), actual(%v)", even.data)
+    }
+    if none := fs.Filter(func(v int) bool { return v > 6 }); none.Size() != 0 {
+        t.Errorf("FlatSet.Filter(v > 6): expected([]), actual(%v)", none.data)
+    }
+
+    ms := InitFlatMultiSet([]person {{30, "b"}, {20, "a"}, {30, "c"}, {20, "d"}}, func(a, b person) bool {
+        return a.age < b.age
+    })
+    kept := ms.Filter(func(p person) bool { return p.name != "a" })
+    if expected := []person {{20, "d"}, {30, "b"}, {30, "c"}}; !slices.Equal(kept.data, expected) {
+        t.Errorf("FlatMultiSet.Filter(): expected(%v), actual(%v)", expected, kept.data)
+    }
+}
+
+
+// Test the Transform method sorts and removes repeated values only when the function changes their order.
+//
+func TestTransform(t *testing.T) {
+    fs := InitFlatSet([]int {1, 2, 3, 4}, lessInt)
+    if actual := fs.Transform(func(v int) int { return v * 10 }); !slices.Equal(actual.data, []int {10, 20, 30, 40}) {
+        t.Errorf("FlatSet.Transform(v * 10): expected([10 20 30 40]), actual(%v)", actual.data)
+    }
+    if actual := fs.Transform(func(v int) int { return v % 3 }); !slices.Equal(actual.data, []int {0, 1, 2}) {
+        t.Errorf("FlatSet.Transform(v %% 3): expected([0 1 2]), actual(%v)", actual.data)
+    }
+    if actual := fs.Transform(func(v int) int { return -v }); !slices.Equal(actual.data, []int {-4, -3, -2, -1}) {
+        t.Errorf("FlatSet.Transform(-v): expected([-4 -3 -2 -1]), actual(%v)", actual.data)
+    }
+    if actual := fs.Transform(func(v int) int { return v + 1 }, true); !slices.Equal(actual.data, []int {2, 3, 4, 5}) {
+        t.Errorf("FlatSet.Transform(v + 1, true): expected([2 3 4 5]), actual(%v)", actual.data)
+    }
+    if !slices.Equal(fs.data, []int {1, 2, 3, 4}) {
+        t.Errorf("FlatSet.Transform() modified this container: %v", fs.data)
+    }
+}
+
+
+// Test the TrimToRange method keeps only the values within the range.
+//
+func TestTrimToRange(t *testing.T) {
+    type testData struct {
+        low, high int
+        expected []int
+    }
+    for _, test := range []testData {{3, 7, []int {3, 4, 6}}, {0, 100, []int {1, 3, 4, 6, 8}}, {5, 5, nil},
+                                     {7, 2, nil}, {8, 9, []int {8}}, {9, 20, nil}, {-5, 2, []int {1}}} {
+        fs := InitFlatSet([]int {1, 3, 4, 6, 8}, lessInt)
+        data := fs.data[:5]
+        removed := fs.TrimToRange(test.low, test.high)
+        if !slices.Equal(fs.data, test.expected) || removed != 5 - len(test.expected) {
+            t.Errorf("FlatSet.TrimToRange(%d, %d): expected(%v), actual(%v)", test.low, test.high, test.expected,
+                     fs.data)
+        }
+        if slices.ContainsFunc(data[len(fs.data):], func(v int) bool { return v != 0 }) {
+            t.Errorf("FlatSet.TrimToRange(%d, %d): expected(zeroed slots), actual(%v)", test.low, test.high, data)
+        }
+    }
+
+    ms := InitFlatMultiSet([]int {1, 3, 3, 4, 6, 6}, lessInt)
+    if removed := ms.TrimToRange(3, 6); removed != 3 || !slices.Equal(ms.data, []int {3, 3, 4}) {
+        t.Errorf("FlatMultiSet.TrimToRange(3, 6): expected(3, [3 3 4]), actual(%d, %v)", removed, ms.data)
+    }
+}
+
+
+// Test the UnionN and IntersectionN methods return the same values as chaining the Union and Intersection methods.
+//
+func TestUnionIntersectionN(t *testing.T) {
+    fs := InitFlatSet(randInt(0, 200, 150), lessInt)
+    others := []*FlatSet[int] {InitFlatSet(randInt(0, 200, 150), lessInt),
+                               InitFlatSet(randInt(50, 150, 80), greaterInt),
+                               InitFlatSet(randInt(0, 200, 150), lessInt)}
+    union, intersection := fs, fs
+    for _, other := range others {
+        union = union.Union(other.All())
+        intersection = intersection.Intersection(other.All())
+    }
+    if actual := fs.UnionN(others...); !slices.Equal(actual.data, union.data) {
+        t.Errorf("FlatSet.UnionN(): expected(%v), actual(%v)", union.data, actual.data)
+    }
+    if actual := fs.IntersectionN(others...); !slices.Equal(actual.data, intersection.data) {
+        t.Errorf("FlatSet.IntersectionN(): expected(%v), actual(%v)", intersection.data, actual.data)
+    }
+    if actual := fs.UnionN(); !slices.Equal(actual.data, fs.data) ||
+       fs.IntersectionN(NewFlatSet(lessInt)).Size() != 0 {
+        t.Errorf("FlatSet.UnionN(): expected(%v), actual(%v)", fs.data, actual.data)
+    }
+
+    byAge := func(a, b person) bool { return a.age < b.age }
+    people := InitFlatSet([]person {{30, "a"}, {20, "b"}}, byAge)
+    people.SetEquals(func(a, b person) bool { return a == b })
+    merged := people.UnionN(InitFlatSet([]person {{30, "a"}, {30, "c"}, {10, "d"}}, byAge),
+                            InitFlatSet([]person {{20, "e"}, {30, "c"}}, byAge))
+    expected := []person {{10, "d"}, {20, "b"}, {20, "e"}, {30, "a"}, {30, "c"}}
+    if !slices.Equal(merged.data, expected) {
+        t.Errorf("FlatSet.UnionN(): expected(%v), actual(%v)", expected, merged.data)
+    }
+}
+
+
+// Test the UpdateAll method inserts the values of several iterators like calling Update for each of them.
+//
+func TestUpdateAll(t *testing.T) {
+    sources := [][]int {randInt(0, 100, 50), {5, 4, 3}, nil, randInt(50, 150, 50)}
+    fs, expected := InitFlatSet(randInt(0, 100, 30), lessInt), InitFlatSet([]int(nil), lessInt)
+    expected.Update(fs.All())
+    for _, source := range sources {
+        expected.Update(slices.Values(source))
+    }
+    fs.UpdateAll(slices.Values(sources[0]), slices.Values(sources[1]), slices.Values(sources[2]),
+                 slices.Values(sources[3]))
+    if !slices.Equal(fs.data, expected.data) {
+        t.Errorf("FlatSet.UpdateAll(): expected(%v), actual(%v)", expected.data, fs.data)
+    }
+
+    people := NewFlatSet(func(a, b person) bool { return a.age < b.age })
+    people.Insert(person {20, "a"})
+    people.UpdateAll(slices.Values([]person {{30, "b"}, {20, "c"}}), slices.Values([]person {{30, "d"}, {10, "e"}}))
+    if expected := []person {{10, "e"}, {20, "a"}, {30, "b"}}; !slices.Equal(people.data, expected) {
+        t.Errorf("FlatSet.UpdateAll(): expected(%v), actual(%v)", expected, people.data)
+    }
+
+    ms := InitFlatMultiSet([]person {{20, "a"}}, func(a, b person) bool { return a.age < b.age })
+    ms.UpdateAll(slices.Values([]person {{30, "b"}, {20, "c"}}), slices.Values([]person {{30, "d"}, {20, "e"}}))
+    if expected := []person {{20, "a"}, {20, "c"}, {20, "e"}, {30, "b"}, {30, "d"}}; !slices.Equal(ms.data, expected) {
+        t.Errorf("FlatMultiSet.UpdateAll(): expected(%v), actual(%v)", expected, ms.data)
+    }
+}
+
+
+// Test the UpdateCount and MergeCount methods return the number of values inserted and discarded.
+//
+func TestUpdateMergeCount(t *testing.T) {
+    fs := InitFlatSet([]int {1, 3, 5}, lessInt)
+    if inserted, discarded := fs.UpdateCount(slices.Values([]int {2, 3, 4, 2, 9})); inserted != 3 || discarded != 2 {
+        t.Errorf("FlatSet.UpdateCount(): expected(3, 2), actual(%d, %d)", inserted, discarded)
+    }
+    if inserted, discarded := fs.UpdateCount(slices.Values([]int(nil))); inserted != 0 || discarded != 0 {
+        t.Errorf("FlatSet.UpdateCount(): expected(0, 0), actual(%d, %d)", inserted, discarded)
+    }
+    inserted, discarded := fs.MergeCount(InitFlatSet([]int {0, 1, 2, 10}, lessInt))
+    if inserted != 2 || discarded != 2 {
+        t.Errorf("FlatSet.MergeCount(): expected(2, 2), actual(%d, %d)", inserted, discarded)
+    }
+    inserted, discarded = fs.MergeCount(InitFlatSet([]int {0, 11, 2}, greaterInt))
+    if inserted != 1 || discarded != 2 {
+        t.Errorf("FlatSet.MergeCount(): expected(1, 2), actual(%d, %d)", inserted, discarded)
+    }
+    if expected := []int {0, 1, 2, 3, 4, 5, 9, 10, 11}; !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.MergeCount(): expected(%v), actual(%v)", expected, fs.data)
+    }
+}
+
+
+// Test the growth hook is called whenever inserting values reallocates the array.
+//
+func TestGrowthHook(t *testing.T) {
+    var calls [][2]int
+    hook := func(oldCap, newCap int) { calls = append(calls, [2]int {oldCap, newCap}) }
+
+    fs := NewFlatSet[int](lessInt)
+    fs.SetGrowthHook(hook)
+    for i := 0; i < 100; i++ {
+        fs.Insert(i)
+    }
+    if len(calls) == 0 || calls[0][0] != 0 || calls[len(calls) - 1][1] != cap(fs.data) {
+        t.Errorf("FlatSet.Insert(): expected(growth from 0 to %d), actual(%v)", cap(fs.data), calls)
+    }
+    for i := 1; i < len(calls); i++ {
+        if calls[i][0] != calls[i - 1][1] || calls[i][1] <= calls[i][0] {
+            t.Errorf("FlatSet.Insert(): expected(increasing capacities), actual(%v)", calls)
+        }
+    }
+
+    calls = nil
+    fs.data = slices.Clip(fs.data)
+    fs.Erase(0)
+    fs.Insert(-1)
+    fs.Remove(-1)
+    if len(calls) != 0 {
+        t.Errorf("FlatSet.Insert(): expected(no growth), actual(%v)", calls)
+    }
+    fs.Update(slices.Values([]int {200, 201, 202}))
+    fs.PushBack(300)
+    if len(calls) != 2 || calls[0] != [2]int {100, 102} {
+        t.Errorf("FlatSet.Update(): expected([[100 102] ...]), actual(%v)", calls)
+    }
+
+    calls = nil
+    ms := InitFlatMultiSet([]int {1, 2, 3}, lessInt)
+    ms.SetGrowthHook(hook)
+    ms.PushBack(3)
+    ms.SetGrowthHook(nil)
+    ms.PushBack(3)
+    ms.Update(slices.Values(randInt(0, 10, 100)))
+    if len(calls) != 1 || calls[0][0] != 3 {
+        t.Errorf("FlatMultiSet.PushBack(): expected([[3 ...]]), actual(%v)", calls)
+    }
+}
+
+
+// Test the Merged method returns the same values as Merge without modifying either container.
+//
+func TestMerged(t *testing.T) {
+    for _, values := range [][]int {randInt(0, 100, 60), nil} {
+        fs := InitFlatSet(randInt(0, 100, 60), lessInt)
+        fs.data = append(make([]int, 0, 200), fs.data...)
+        for _, other := range []*FlatSet[int] {InitFlatSet(values, lessInt), InitFlatSet(values, greaterInt)} {
+            before, otherBefore := slices.Clone(fs.data), slices.Clone(other.data)
+            expected := InitFlatSet(fs.data, lessInt)
+            expected.Merge(other)
+            merged := fs.Merged(other)
+            if !slices.Equal(merged.data, expected.data) {
+                t.Errorf("FlatSet.Merged(): expected(%v), actual(%v)", expected.data, merged.data)
+            }
+            merged.Insert(1000)
+            if !slices.Equal(fs.data, before) || !slices.Equal(other.data, otherBefore) {
+                t.Errorf("FlatSet.Merged(): expected(unmodified), actual(%v, %v)", fs.data, other.data)
+            }
+        }
+    }
+
+    byAge := func(a, b person) bool { return a.age < b.age }
+    ms := InitFlatMultiSet([]person {{20, "a"}, {30, "b"}}, byAge)
+    merged := ms.Merged(InitFlatMultiSet([]person {{30, "c"}, {20, "d"}}, byAge))
+    if expected := []person {{20, "a"}, {20, "d"}, {30, "b"}, {30, "c"}}; !slices.Equal(merged.data, expected) {
+        t.Errorf("FlatMultiSet.Merged(): expected(%v), actual(%v)", expected, merged.data)
+    }
+    if ms.Size() != 2 {
+        t.Errorf("FlatMultiSet.Merged(): expected(unmodified), actual(%v)", ms.data)
+    }
+}
+
+
+// Test the ExtendDisjoint method only appends values that are ordered after the existing values.
+//
+func TestExtendDisjoint(t *testing.T) {
+    fs := InitFlatSet([]int {1, 2, 3}, lessInt)
+    for _, test := range []struct { values []int; cmp Compare[int]; err error } {
+        {[]int {4, 6}, lessInt, nil}, {[]int {8, 7}, greaterInt, nil}, {nil, lessInt, nil},
+        {[]int {5, 9}, lessInt, ErrOutOfOrder}, {[]int {8, 9}, lessInt, ErrDuplicate}} {
+        if err := fs.ExtendDisjoint(InitFlatSet(test.values, test.cmp)); err != test.err {
+            t.Errorf("FlatSet.ExtendDisjoint(%v): expected(%v), actual(%v)", test.values, test.err, err)
+        }
+    }
+    if expected := []int {1, 2, 3, 4, 6, 7, 8}; !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.ExtendDisjoint(): expected(%v), actual(%v)", expected, fs.data)
+    }
+
+    ms := InitFlatMultiSet([]int {1, 2, 3}, lessInt)
+    if err := ms.ExtendDisjoint(InitFlatMultiSet([]int {3, 4, 3}, lessInt)); err != nil {
+        t.Errorf("FlatMultiSet.ExtendDisjoint([3 3 4]): expected(nil), actual(%v)", err)
+    }
+    if err := ms.ExtendDisjoint(InitFlatMultiSet([]int {5, 3}, lessInt)); err != ErrOutOfOrder {
+        t.Errorf("FlatMultiSet.ExtendDisjoint([3 5]): expected(%v), actual(%v)", ErrOutOfOrder, err)
+    }
+    if expected := []int {1, 2, 3, 3, 3, 4}; !slices.Equal(ms.data, expected) {
+        t.Errorf("FlatMultiSet.ExtendDisjoint(): expected(%v), actual(%v)", expected, ms.data)
+    }
+}
+
+
+// Test strict mode panics when a modification finds neighbouring values out of order.
+//
+func TestStrict(t *testing.T) {
+    panics := func(fn func()) (panicked bool) {
+        defer func() { panicked = recover() != nil }()
+        fn()
+        return
+    }
+    lessPtr := func(a, b *int) bool { return *a < *b }
+    values := []int {10, 20, 30, 40}
+    fs := NewFlatSet[*int](lessPtr)
+    for i := range values {
+        fs.Insert(&values[i])
+    }
+    fs.SetStrict(true)
+    for i := 0; i < 100; i++ {
+        if panics(func() { fs.Insert(&[]int {25}[0]); fs.Remove(&[]int {25}[0]) }) {
+            t.Errorf("FlatSet.Insert(25): expected(no panic), actual(panic)")
+        }
+    }
+    values[2] = 5
+    found := false
+    for i := 0; i < 100 && !found; i++ {
+        found = panics(func() { fs.Insert(&[]int {35}[0]); fs.Remove(&[]int {35}[0]) })
+    }
+    if !found {
+        t.Errorf("FlatSet.Insert(35): expected(panic), actual(no panic)")
+    }
+    if !panics(func() { fs.Erase(1) }) {
+        t.Errorf("FlatSet.Erase(1): expected(panic), actual(no panic)")
+    }
+
+    ms := InitFlatMultiSet([]int {1, 2, 2, 3}, lessInt)
+    ms.SetStrict(true)
+    ms.data[1] = 4
+    if !panics(func() { ms.Erase(2, 3) }) {
+        t.Errorf("FlatMultiSet.Erase(2, 3): expected(panic), actual(no panic)")
+    }
+    extended := InitFlatMultiSet([]int {1, 2, 3}, lessInt)
+    extended.SetStrict(true)
+    other := InitFlatMultiSet([]int {5, 6}, lessInt)
+    other.data[0], other.data[1] = 6, 5
+    if !panics(func() { extended.ExtendDisjoint(other) }) {
+        t.Errorf("FlatMultiSet.ExtendDisjoint([6 5]): expected(panic), actual(no panic)")
+    }
+    unique := InitFlatSet([]int {1, 2, 3}, lessInt)
+    unique.SetStrict(true)
+    disjoint := InitFlatSet([]int {5, 6}, lessInt)
+    disjoint.data[0], disjoint.data[1] = 6, 5
+    if !panics(func() { unique.ExtendDisjoint(disjoint) }) {
+        t.Errorf("FlatSet.ExtendDisjoint([6 5]): expected(panic), actual(no panic)")
+    }
+
+    ms.SetStrict(false)
+    if panics(func() { ms.Insert(0) }) {
+        t.Errorf("FlatMultiSet.Insert(0): expected(no panic), actual(panic)")
+    }
+}
+
+
+// Test Reserve allocates the array once before inserting a known number of values.
+//
+func TestReserve(t *testing.T) {
+    var calls int
+    fs := NewFlatSet[int](lessInt)
+    fs.SetGrowthHook(func(oldCap, newCap int) { calls++ })
+    fs.Reserve(1000)
+    if fs.Cap() < 1000 || calls != 1 {
+        t.Errorf("FlatSet.Reserve(1000): expected(>= 1000, 1), actual(%d, %d)", fs.Cap(), calls)
+    }
+    for _, value := range randInt(0, 100000, 1000) {
+        fs.Insert(value)
+    }
+    fs.Reserve(10)
+    if calls != 1 {
+        t.Errorf("FlatSet.Insert(): expected(1), actual(%d)", calls)
+    }
+
+    ms := InitFlatMultiSet[int]([]int {3, 1, 2}, lessInt)
+    ms.Reserve(2)
+    if !slices.Equal(ms.data, []int {1, 2, 3}) || ms.Cap() != cap(ms.data) {
+        t.Errorf("FlatMultiSet.Reserve(2): expected([1 2 3]), actual(%v)", ms.data)
+    }
+}
+
+
+// Test Clear releases the array and ClearRetainingCapacity zeroes the values but keeps the array.
+//
+func TestClear(t *testing.T) {
+    values := []int {1, 2, 3}
+    fs := NewFlatSet[*int](func(a, b *int) bool { return *a < *b })
+    for i := range values {
+        fs.Insert(&values[i])
+    }
+    capacity := fs.Cap()
+    fs.ClearRetainingCapacity()
+    if fs.Size() != 0 || fs.Cap() != capacity {
+        t.Errorf("FlatSet.ClearRetainingCapacity(): expected(0, %d), actual(%d, %d)", capacity, fs.Size(), fs.Cap())
+    }
+    if slots := fs.data[:len(values)]; slices.ContainsFunc(slots, func(p *int) bool { return p != nil }) {
+        t.Errorf("FlatSet.ClearRetainingCapacity(): expected(zeroed slots), actual(%v)", slots)
+    }
+
+    ms := InitFlatMultiSet[int]([]int {1, 1, 2}, lessInt)
+    ms.Clear()
+    if ms.Size() != 0 || ms.Cap() != 0 {
+        t.Errorf("FlatMultiSet.Clear(): expected(0, 0), actual(%d, %d)", ms.Size(), ms.Cap())
+    }
+    ms.Insert(4)
+    if !slices.Equal(ms.data, []int {4}) {
+        t.Errorf("FlatMultiSet.Insert(4): expected([4]), actual(%v)", ms.data)
+    }
+}
+
+
+// Test Clone returns an independent container with the same comparison and equality functions.
+//
+func TestClone(t *testing.T) {
+    fs := InitFlatSet[int]([]int {3, 1, 2}, lessInt)
+    clone := fs.Clone()
+    clone.Insert(0)
+    fs.Erase(0)
+    if !slices.Equal(fs.data, []int {2, 3}) || !slices.Equal(clone.data, []int {0, 1, 2, 3}) {
+        t.Errorf("FlatSet.Clone(): expected([2 3], [0 1 2 3]), actual(%v, %v)", fs.data, clone.data)
+    }
+
+    ms := InitFlatMultiSet[int]([]int {2, 1, 2}, lessInt)
+    ms.Reserve(10)
+    mclone := ms.Clone()
+    ms.Insert(1)
+    mclone.Insert(3)
+    if !slices.Equal(ms.data, []int {1, 1, 2, 2}) || !slices.Equal(mclone.data, []int {1, 2, 2, 3}) {
+        t.Errorf("FlatMultiSet.Clone(): expected([1 1 2 2], [1 2 2 3]), actual(%v, %v)", ms.data, mclone.data)
+    }
+}
+
+
+// Test FlatMultiSet.Equal compares the number of each equivalent value, including with a different comparison function.
+//
+func TestMultiSetEqual(t *testing.T) {
+    ms := InitFlatMultiSet[int]([]int {3, 1, 2, 2}, lessInt)
+    for _, test := range []struct {
+        other *FlatMultiSet[int]
+        expected bool
+    }{
+        {InitFlatMultiSet[int]([]int {2, 1, 3, 2}, lessInt), true},
+        {InitFlatMultiSet[int]([]int {2, 1, 3, 2}, greaterInt), true},
+        {InitFlatMultiSet[int]([]int {1, 1, 2, 3}, lessInt), false},
+        {InitFlatMultiSet[int]([]int {1, 2, 3}, lessInt), false},
+    } {
+        if actual := ms.Equal(test.other); actual != test.expected {
+            t.Errorf("FlatMultiSet.Equal(%v): expected(%t), actual(%t)", test.other.data, test.expected, actual)
+        }
+    }
+}
+
+
+// Test the FlatSet and FlatMultiSet are encoded as JSON arrays and are sorted when they are decoded.
+//
+func TestSetJSON(t *testing.T) {
+    type config struct {
+        Tags *FlatSet[string]
+        Scores *FlatMultiSet[int]
+    }
+    cfg := config{NewFlatSet[string](lessString), NewFlatMultiSet[int](lessInt)}
+    data, err := json.Marshal(cfg)
+    if expected := `{"Tags":[],"Scores":[]}`; err != nil || string(data) != expected {
+        t.Errorf("FlatSet.MarshalJSON(): expected(%s), actual(%s, %v)", expected, data, err)
+    }
+
+    if err := json.Unmarshal([]byte(`{"Tags":["b","c","a","b"],"Scores":[3,1,3,2]}`), &cfg); err != nil {
+        t.Errorf("FlatSet.UnmarshalJSON() failed: %v", err)
+    }
+    if expected := []string {"a", "b", "c"}; !slices.Equal(cfg.Tags.data, expected) {
+        t.Errorf("FlatSet.UnmarshalJSON(): expected(%v), actual(%v)", expected, cfg.Tags.data)
+    }
+    if expected := []int {1, 2, 3, 3}; !slices.Equal(cfg.Scores.data, expected) {
+        t.Errorf("FlatMultiSet.UnmarshalJSON(): expected(%v), actual(%v)", expected, cfg.Scores.data)
+    }
+
+    data, err = json.Marshal(cfg)
+    if expected := `{"Tags":["a","b","c"],"Scores":[1,2,3,3]}`; err != nil || string(data) != expected {
+        t.Errorf("FlatSet.MarshalJSON(): expected(%s), actual(%s, %v)", expected, data, err)
+    }
+    if err := json.Unmarshal([]byte(`[1]`), &FlatSet[int]{}); err == nil {
+        t.Errorf("FlatSet.UnmarshalJSON() expected an error without a comparison function")
+    }
+
+    // the containers are also encoded as arrays when they are fields of a structure that is marshalled by value
+    type embedded struct {
+        Tags FlatSet[string]
+        Scores FlatMultiSet[int]
+    }
+    value := embedded{MakeFlatSet[string](lessString), MakeFlatMultiSet[int](lessInt)}
+    if err := json.Unmarshal([]byte(`{"Tags":["b","a"],"Scores":[2,1,2]}`), &value); err != nil {
+        t.Errorf("FlatSet.UnmarshalJSON() failed: %v", err)
+    }
+    data, err = json.Marshal(value)
+    if expected := `{"Tags":["a","b"],"Scores":[1,2,2]}`; err != nil || string(data) != expected {
+        t.Errorf("FlatSet.MarshalJSON(): expected(%s), actual(%s, %v)", expected, data, err)
+    }
+
+    // the bytes are encoded as an array of numbers rather than a base64 string
+    bs := InitOrderedFlatSet([]uint8 {3, 1, 2})
+    data, err = json.Marshal(bs)
+    if expected := `[1,2,3]`; err != nil || string(data) != expected {
+        t.Errorf("FlatSet.MarshalJSON(): expected(%s), actual(%s, %v)", expected, data, err)
+    }
+    decodedBytes := MakeFlatMultiSet[byte](lessOrdered[byte])
+    if err := json.Unmarshal(data, &decodedBytes); err != nil || !slices.Equal(decodedBytes.data, []byte {1, 2, 3}) {
+        t.Errorf("FlatMultiSet.UnmarshalJSON(): expected([1 2 3]), actual(%v, %v)", decodedBytes.data, err)
+    }
+    data, err = json.Marshal(decodedBytes)
+    if expected := `[1,2,3]`; err != nil || string(data) != expected {
+        t.Errorf("FlatMultiSet.MarshalJSON(): expected(%s), actual(%s, %v)", expected, data, err)
+    }
+}
+
+
+// Test the Enumerate iterator returns the index of each value in order and stops early when asked.
+//
+func TestEnumerate(t *testing.T) {
+    ms := InitFlatMultiSet[int]([]int {3, 1, 2, 1}, lessInt)
+    for i, value := range ms.Enumerate() {
+        if expected := ms.At(i); value != expected {
+            t.Errorf("FlatMultiSet.Enumerate(): expected(%d, %d), actual(%d, %d)", i, expected, i, value)
+        }
+    }
+    count := 0
+    for i := range ms.Enumerate() {
+        if i == 1 {
+            break
+        }
+        count++
+    }
+    if count != 1 {
+        t.Errorf("FlatMultiSet.Enumerate(): expected(1) before break, actual(%d)", count)
+    }
+}
+
+
+// Test Range returns the values from lo (inclusive) upto hi (exclusive).
+//
+func TestRange(t *testing.T) {
+    fs := InitFlatSet[int]([]int {1, 3, 5, 7, 9}, lessInt)
+    for _, test := range []struct {
+        lo, hi int
+        expected []int
+    }{
+        {3, 7, []int {3, 5}},
+        {2, 8, []int {3, 5, 7}},
+        {0, 100, []int {1, 3, 5, 7, 9}},
+        {5, 5, []int {}},
+        {7, 3, []int {}},
+        {10, 20, []int {}},
+    } {
+        if actual := slices.Collect(fs.Range(test.lo, test.hi)); !slices.Equal(actual, test.expected) {
+            t.Errorf("FlatSet.Range(%d, %d): expected(%v), actual(%v)", test.lo, test.hi, test.expected, actual)
+        }
+    }
+
+    ms := InitFlatMultiSet[int]([]int {2, 2, 4, 4, 6}, lessInt)
+    if actual := slices.Collect(ms.Range(2, 5)); !slices.Equal(actual, []int {2, 2, 4, 4}) {
+        t.Errorf("FlatMultiSet.Range(2, 5): expected([2 2 4 4]), actual(%v)", actual)
+    }
+}
+
+
+// Test Count returns the number of equivalent values in a FlatMultiSet.
+//
+func TestMultiSetCount(t *testing.T) {
+    ms := InitFlatMultiSet[int]([]int {4, 1, 2, 2, 4, 4}, lessInt)
+    for value, expected := range map[int]int {0: 0, 1: 1, 2: 2, 3: 0, 4: 3, 5: 0} {
+        if actual := ms.Count(value); actual != expected {
+            t.Errorf("FlatMultiSet.Count(%d): expected(%d), actual(%d)", value, expected, actual)
+        }
+    }
+}
+
+
+// Test the ordered constructors sort integers and strings without a comparison function.
+//
+func TestOrderedFlatSet(t *testing.T) {
+    fs := InitOrderedFlatSet([]int {5, 1, 3, 1, 5})
+    if expected, actual := []int {1, 3, 5}, slices.Collect(fs.All()); !slices.Equal(actual, expected) {
+        t.Errorf("InitOrderedFlatSet(): expected(%v), actual(%v)", expected, actual)
+    }
+    ss := NewOrderedFlatSet[string]()
+    for _, value := range []string {"pear", "apple", "fig", "apple"} {
+        ss.Insert(value)
+    }
+    expected, actual := []string {"apple", "fig", "pear"}, slices.Collect(ss.All())
+    if !slices.Equal(actual, expected) {
+        t.Errorf("NewOrderedFlatSet(): expected(%v), actual(%v)", expected, actual)
+    }
+}
+
+
+// Test the Front and Back methods return the smallest and largest values, or false when the container is empty.
+//
+func TestFrontBack(t *testing.T) {
+    fs := NewFlatSet[int](lessInt)
+    if _, ok := fs.Front(); ok {
+        t.Errorf("FlatSet.Front(): expected(0, false), actual(true)")
+    }
+    if _, ok := fs.Back(); ok {
+        t.Errorf("FlatSet.Back(): expected(0, false), actual(true)")
+    }
+    fs.Update(slices.Values([]int {5, 2, 9}))
+    if value, ok := fs.Front(); value != 2 || !ok {
+        t.Errorf("FlatSet.Front(): expected(2, true), actual(%d, %v)", value, ok)
+    }
+    if value, ok := fs.Back(); value != 9 || !ok {
+        t.Errorf("FlatSet.Back(): expected(9, true), actual(%d, %v)", value, ok)
+    }
+
+    ms := InitFlatMultiSet[person]([]person {{30, "a"}, {20, "b"}, {30, "c"}, {20, "d"}},
+        func(lhs, rhs person) bool {return lhs.age < rhs.age})
+    if value, ok := ms.Front(); value.name != "b" || !ok {
+        t.Errorf("FlatMultiSet.Front(): expected({20 b}, true), actual(%v, %v)", value, ok)
+    }
+    if value, ok := ms.Back(); value.name != "c" || !ok {
+        t.Errorf("FlatMultiSet.Back(): expected({30 c}, true), actual(%v, %v)", value, ok)
+    }
+}
+
+
+// Test the PopFront and PopBack methods drain a FlatMultiSet in order and zero the vacated slots.
+//
+func TestPopFrontBack(t *testing.T) {
+    ms := InitFlatMultiSet[person]([]person {{30, "a"}, {20, "b"}, {30, "c"}, {20, "d"}, {40, "e"}},
+        func(lhs, rhs person) bool {return lhs.age < rhs.age})
+    data := ms.data
+    var actual []string
+    for {
+        value, ok := ms.PopFront()
+        if !ok {
+            break
+        }
+        actual = append(actual, value.name)
+        if value, ok := ms.PopBack(); ok {
+            actual = append(actual, value.name)
+        }
+    }
+    if expected := []string {"b", "e", "d", "c", "a"}; !slices.Equal(actual, expected) {
+        t.Errorf("FlatMultiSet.PopFront(): expected(%v), actual(%v)", expected, actual)
+    }
+    if slices.ContainsFunc(data, func(value person) bool {return value != person{}}) {
+        t.Errorf("FlatMultiSet.PopFront(): expected(zeroed array), actual(%v)", data)
+    }
+
+    fs := InitFlatSet[int]([]int {3, 1, 2}, lessInt)
+    if value, ok := fs.PopFront(); value != 1 || !ok || fs.Size() != 2 {
+        t.Errorf("FlatSet.PopFront(): expected(1, true), actual(%d, %v)", value, ok)
+    }
+    if value, ok := fs.PopBack(); value != 3 || !ok || fs.Size() != 1 {
+        t.Errorf("FlatSet.PopBack(): expected(3, true), actual(%d, %v)", value, ok)
+    }
+}
+
+
 func comparePeople(lhs, rhs *person) bool { // oldest to youngest, then alphabetically
     if lhs.age > rhs.age {
         return true
@@ -0,0 +1,650 @@
+package flatset
+
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "slices"
+    "sort"
+)
+
+// The serialized format starts with a header of the magic bytes, the format version, the flags, the element version and
+// the number of values. The values follow in blocks, each of them starting with the number of values in the block and
+// the size of the encoded values in bytes, and if the checksums flag is set they end with a CRC-32C of the block so
+// that corruption is detected in the block where it occurred. If the compressed flag is set the encoded values of each
+// block are compressed separately, so that a block can be read without reading the blocks before it. If the number of
+// values was not known when they were written, the number in the header is the maximum uint64 and the values end with
+// an empty block.
+//
+// If the index flag is set the blocks are followed by a sparse index of the number of blocks and values, and the
+// offset, rank and first value of each block, with a checksum of the index. The data then ends with a trailer of the
+// offset of the index and the index magic bytes, so the index can be found from the end of the data.
+//
+// All of the numbers are little-endian and nothing in the format is aligned, so the serialized data can be loaded on
+// any architecture if the codec is also independent of the architecture, like the built-in codecs.
+//
+const (
+    formatMagic = "FSET"
+    formatVersion = 1
+    headerSize = 16
+    blockHeaderSize = 8
+    blockBytes = 64 << 10
+    maxValueBytes = 16 << 20
+    maxBlockBytes = blockBytes + maxValueBytes
+    maxStoredBytes = maxBlockBytes + maxBlockBytes / 8 + 1024
+    checksumSize = 4
+    unknownSize = ^uint64(0)
+    indexMagic = "FIDX"
+    indexHeaderSize = 12
+    trailerSize = 12
+)
+
+// The flags in the header of the serialized format.
+//
+const (
+    flagChecksums = 1 << iota
+    flagCompressed
+    flagIndex
+)
+
+// The table for the CRC-32C checksums of the blocks.
+//
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// The error returned by Load when the serialized data is not in the format written by Save.
+//
+var ErrInvalidFormat = errors.New("flatset: invalid serialized format")
+
+// The error returned by Load when the checksum of a block does not match its contents. It wraps ErrInvalidFormat.
+//
+var ErrChecksum = fmt.Errorf("%w: checksum mismatch", ErrInvalidFormat)
+
+// The error returned by Save when a value is encoded into more than 16 MiB, which is the largest value that Load will
+// read so that a corrupted block length can not allocate a huge buffer.
+//
+var ErrValueTooLarge = errors.New("flatset: encoded value exceeds 16 MiB")
+
+
+// A VersionedCodec is a Codec that has a version for the encoding of its values. Save stores the version of the codec
+// with the values, so that values stored with a previous version can be upgraded by a Migration when they are loaded.
+// The version of a Codec that does not implement this interface is zero.
+//
+type VersionedCodec[V any] interface {
+    Codec[V]
+    Version() uint16
+}
+
+
+// A Migration decodes a value from the start of a buffer that was stored with a previous version of the codec, and
+// returns it with the number of bytes that were read, or an error if the version is not supported.
+//
+type Migration[V any] func(version uint16, buf []byte) (V, int, error)
+
+
+// A Compression compresses the blocks of the serialized format using a compressor from the standard library or another
+// package that implements the io interfaces. Each block is compressed separately by a new writer and decompressed by a
+// new reader, so that a block can be read without reading the blocks before it.
+//
+type Compression struct {
+    NewWriter func(w io.Writer) io.WriteCloser
+    NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+
+// A Compression that uses gzip with the default compression level.
+//
+var Gzip = Compression{
+    NewWriter: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+    NewReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+}
+
+
+// Private function that returns the version of the codec.
+//
+func codecVersion[V any](codec Codec[V]) uint16 {
+    if versioned, ok := codec.(VersionedCodec[V]); ok {
+        return versioned.Version()
+    }
+    return 0
+}
+
+
+// Write the values of this container to the writer in order, using the codec to encode each of them. The values are
+// written in blocks with a checksum, so that they can be read back without buffering the whole of the serialized data
+// and corruption is detected in the block where it occurred.
+//
+func (self *base[V]) Save(w io.Writer, codec Codec[V]) error {
+    return self.save(w, codec, nil)
+}
+
+
+// Write the values of this container to the writer like Save, except the encoded values of each block are compressed.
+// The values must be read back using LoadCompressed with the same compression.
+//
+func (self *base[V]) SaveCompressed(w io.Writer, codec Codec[V], compression Compression) error {
+    return self.save(w, codec, &compression)
+}
+
+
+// Shared private method that writes the values of this container in blocks, compressing them if there is a compression.
+//
+func (self *base[V]) save(w io.Writer, codec Codec[V], compression *Compression) error {
+    if debug {
+        defer self.guard.read()()
+    }
+    defer traceOperation("flatset.Save", len(self.data))()
+    enc, err := newEncoder(w, codec, compression, uint64(len(self.data)))
+    if err != nil {
+        return err
+    }
+    for _, value := range self.data {
+        if err := enc.add(value); err != nil {
+            return err
+        }
+    }
+    return enc.close()
+}
+
+
+// Shared private method that reads the values written by Save, decoding them directly into the array of this container.
+// Values stored with a different version of the codec are decoded by the migration if there is one, and compressed
+// blocks are decompressed by the compression. Returns true if the values are sorted using the comparison function of
+// this container.
+//
+func (self *base[V]) load(r io.Reader, codec Codec[V], migrate Migration[V], compression *Compression) (bool, error) {
+    dec, err := newDecoder(r, codec, migrate, compression)
+    if err != nil {
+        return false, err
+    }
+
+    // The size is not trusted when allocating the array, to avoid allocating a huge array for a corrupted header.
+    data := make([]V, 0, min(dec.size, blockBytes))
+    sorted := true
+    for {
+        value, ok, err := dec.next()
+        if err != nil {
+            return false, err
+        } else if !ok {
+            break
+        }
+        if sorted && len(data) > 0 && self.cmp(value, data[len(data) - 1]) {
+            sorted = false
+        }
+        data = append(data, value)
+    }
+    if !self.shared {
+        clear(self.data)
+    }
+    self.data = data
+    self.shared = false
+    return sorted, nil
+}
+
+
+// Private structure that writes values in the serialized format one at a time, buffering a single block.
+//
+type encoder[V any] struct {
+    w io.Writer
+    codec Codec[V]
+    compression *Compression
+    unknownSize bool
+    buf []byte
+    compressed bytes.Buffer
+    count int
+    offset uint64
+    index []byte
+    blocks uint32
+    total uint64
+}
+
+
+// Private function that writes the header of the serialized format and returns an encoder for the values. If the
+// number of values is unknownSize the values end with an empty block instead.
+//
+func newEncoder[V any](w io.Writer, codec Codec[V], compression *Compression, size uint64) (*encoder[V], error) {
+    flags := byte(flagChecksums | flagIndex)
+    if compression != nil {
+        flags |= flagCompressed
+    }
+    header := make([]byte, 0, headerSize)
+    header = append(header, formatMagic...)
+    header = append(header, formatVersion, flags)
+    header = binary.LittleEndian.AppendUint16(header, codecVersion(codec))
+    header = binary.LittleEndian.AppendUint64(header, size)
+    if _, err := w.Write(header); err != nil {
+        return nil, err
+    }
+    return &encoder[V]{
+        w: w,
+        codec: codec,
+        compression: compression,
+        unknownSize: size == unknownSize,
+        buf: make([]byte, blockHeaderSize, blockHeaderSize + blockBytes + checksumSize),
+        offset: headerSize,
+        index: make([]byte, indexHeaderSize),
+    }, nil
+}
+
+
+// Private method that encodes a value, and writes the block if it is full. The first value of each block is also added
+// to the index with the offset of the block.
+//
+func (self *encoder[V]) add(value V) error {
+    start := len(self.buf)
+    self.buf = self.codec.Encode(value, self.buf)
+    if len(self.buf) - start > maxValueBytes {
+        self.buf = self.buf[:start]
+        return ErrValueTooLarge
+    }
+    if self.count == 0 {
+        self.index = binary.LittleEndian.AppendUint64(self.index, self.offset)
+        self.index = binary.LittleEndian.AppendUint64(self.index, self.total)
+        self.index = append(self.index, self.buf[start:]...)
+        self.blocks++
+    }
+    self.count++
+    self.total++
+    if len(self.buf) - blockHeaderSize < blockBytes {
+        return nil
+    }
+    return self.flush()
+}
+
+
+// Private method that writes the values in the current block with their checksum.
+//
+func (self *encoder[V]) flush() error {
+    if self.compression != nil && self.count > 0 {
+        self.compressed.Reset()
+        self.compressed.Write(self.buf[:blockHeaderSize])
+        zw := self.compression.NewWriter(&self.compressed)
+        if _, err := zw.Write(self.buf[blockHeaderSize:]); err != nil {
+            return err
+        }
+        if err := zw.Close(); err != nil {
+            return err
+        }
+        self.buf = append(self.buf[:0], self.compressed.Bytes()...)
+    }
+    binary.LittleEndian.PutUint32(self.buf, uint32(self.count))
+    binary.LittleEndian.PutUint32(self.buf[4:], uint32(len(self.buf) - blockHeaderSize))
+    self.buf = binary.LittleEndian.AppendUint32(self.buf, crc32.Checksum(self.buf, castagnoli))
+    if _, err := self.w.Write(self.buf); err != nil {
+        return err
+    }
+    self.offset += uint64(len(self.buf))
+    self.buf = self.buf[:blockHeaderSize]
+    self.count = 0
+    return nil
+}
+
+
+// Private method that writes the last block, the empty block that ends the values if their number was unknown, and
+// then the index of the blocks.
+//
+func (self *encoder[V]) close() error {
+    if self.count > 0 {
+        if err := self.flush(); err != nil {
+            return err
+        }
+    }
+    if self.unknownSize {
+        if err := self.flush(); err != nil {
+            return err
+        }
+    }
+    binary.LittleEndian.PutUint32(self.index, self.blocks)
+    binary.LittleEndian.PutUint64(self.index[4:], self.total)
+    self.index = binary.LittleEndian.AppendUint32(self.index, crc32.Checksum(self.index, castagnoli))
+    self.index = binary.LittleEndian.AppendUint64(self.index, self.offset)
+    self.index = append(self.index, indexMagic...)
+    _, err := self.w.Write(self.index)
+    return err
+}
+
+
+// Private structure that reads values in the serialized format one at a time, buffering a single block.
+//
+type decoder[V any] struct {
+    r io.Reader
+    decode func(buf []byte) (V, int, error)
+    compression *Compression
+    flags byte
+    size uint64
+    read uint64
+    blocks int
+    count uint32
+    buf []byte
+    decompressed []byte
+    block []byte
+}
+
+
+// Private function that reads the header of the serialized format and returns a decoder for the values.
+//
+func newDecoder[V any](r io.Reader, codec Codec[V], migrate Migration[V],
+                       compression *Compression) (*decoder[V], error) {
+    var header [headerSize]byte
+    if _, err := io.ReadFull(r, header[:]); err != nil {
+        return nil, err
+    }
+    if string(header[:4]) != formatMagic {
+        return nil, ErrInvalidFormat
+    }
+    if header[4] != formatVersion {
+        return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidFormat, header[4])
+    }
+    self := &decoder[V]{r: r, decode: codec.Decode, compression: compression, flags: header[5]}
+    if self.flags &^ (flagChecksums | flagCompressed | flagIndex) != 0 {
+        return nil, fmt.Errorf("%w: unsupported flags %#x", ErrInvalidFormat, self.flags)
+    }
+    if self.flags & flagCompressed != 0 && compression == nil {
+        return nil, fmt.Errorf("%w: the values are compressed", ErrInvalidFormat)
+    }
+    self.size = binary.LittleEndian.Uint64(header[8:])
+
+    if version := binary.LittleEndian.Uint16(header[6:]); version != codecVersion(codec) {
+        if migrate == nil {
+            return nil, fmt.Errorf("%w: element version %d, expected %d", ErrInvalidFormat, version,
+                                   codecVersion(codec))
+        }
+        self.decode = func(buf []byte) (V, int, error) { return migrate(version, buf) }
+    }
+    return self, nil
+}
+
+
+// Private method that returns the next value and true, or false when there are no more values.
+//
+func (self *decoder[V]) next() (V, bool, error) {
+    var zero V
+    for self.count == 0 {
+        if len(self.block) > 0 {
+            return zero, false, fmt.Errorf("%w: %d bytes left over in block", ErrInvalidFormat, len(self.block))
+        }
+        if self.read == self.size {
+            return zero, false, nil
+        }
+        if err := self.readBlock(); err != nil {
+            return zero, false, err
+        }
+        if self.count == 0 {
+            return zero, false, nil
+        }
+    }
+    value, n, err := self.decode(self.block)
+    if err != nil {
+        return zero, false, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+    }
+    self.block = self.block[n:]
+    self.count--
+    self.read++
+    return value, true, nil
+}
+
+
+// Private method that reads the next block and verifies its checksum. A block without any values is only valid if the
+// number of values was unknown, as it ends the values.
+//
+func (self *decoder[V]) readBlock() error {
+    trailer := 0
+    if self.flags & flagChecksums != 0 {
+        trailer = checksumSize
+    }
+    self.buf = slices.Grow(self.buf[:0], blockHeaderSize)[:blockHeaderSize]
+    if _, err := io.ReadFull(self.r, self.buf); err != nil {
+        return unexpectedEOF(err)
+    }
+    count := binary.LittleEndian.Uint32(self.buf)
+    length := int(binary.LittleEndian.Uint32(self.buf[4:]))
+
+    // the length is not trusted until the checksum is verified, so it is limited to the largest block that is written
+    limit := maxBlockBytes
+    if self.flags & flagCompressed != 0 {
+        limit = maxStoredBytes
+    }
+    if length > limit {
+        return fmt.Errorf("%w: invalid block of %d bytes", ErrInvalidFormat, length)
+    }
+    self.buf = slices.Grow(self.buf, length + trailer)[:blockHeaderSize + length + trailer]
+    if _, err := io.ReadFull(self.r, self.buf[blockHeaderSize:]); err != nil {
+        return unexpectedEOF(err)
+    }
+    if trailer > 0 {
+        checksum := binary.LittleEndian.Uint32(self.buf[blockHeaderSize + length:])
+        if crc32.Checksum(self.buf[:blockHeaderSize + length], castagnoli) != checksum {
+            return fmt.Errorf("%w in block %d", ErrChecksum, self.blocks)
+        }
+    }
+    self.blocks++
+    if count == 0 && self.size == unknownSize && length == 0 {
+        return nil
+    }
+    if count == 0 || (self.size != unknownSize && uint64(count) > self.size - self.read) {
+        return fmt.Errorf("%w: invalid block of %d values", ErrInvalidFormat, count)
+    }
+    self.count = count
+    self.block = self.buf[blockHeaderSize:blockHeaderSize + length]
+    if self.flags & flagCompressed != 0 {
+        var err error
+        if self.decompressed, err = decompress(self.decompressed[:0], self.block, self.compression); err != nil {
+            return fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+        }
+        self.block = self.decompressed
+    }
+    return nil
+}
+
+
+// Private function that appends the decompressed contents of a block to the buffer. The decompressed contents are
+// limited to the largest block that is written, so that a corrupted block can not expand into a huge buffer.
+//
+func decompress(buf []byte, block []byte, compression *Compression) ([]byte, error) {
+    zr, err := compression.NewReader(bytes.NewReader(block))
+    if err != nil {
+        return buf, err
+    }
+    out := bytes.NewBuffer(buf)
+    if _, err := out.ReadFrom(io.LimitReader(zr, maxBlockBytes + 1)); err != nil {
+        return buf, err
+    } else if out.Len() - len(buf) > maxBlockBytes {
+        return buf, errors.New("decompressed block exceeds the largest block")
+    }
+    return out.Bytes(), zr.Close()
+}
+
+
+// Private function that converts the end of a stream in the middle of the serialized data into an error.
+//
+func unexpectedEOF(err error) error {
+    if err == io.EOF {
+        return io.ErrUnexpectedEOF
+    }
+    return err
+}
+
+
+// Replace the values of this FlatSet with the values read from the reader, that were written by Save using the same
+// codec. If the values were written by a container with a different ordering they will be sorted, and values that are
+// repeated will be discarded. If an error is returned the FlatSet is unchanged.
+//
+func (self *FlatSet[V]) Load(r io.Reader, codec Codec[V]) error {
+    return self.LoadMigrate(r, codec, nil)
+}
+
+
+// Replace the values of this FlatSet with the values read from the reader like Load, except values that were stored
+// with a different version of the codec are upgraded by the migration instead of returning an error. The values that
+// are returned by the migration can be ordered differently from the values that were stored.
+//
+func (self *FlatSet[V]) LoadMigrate(r io.Reader, codec Codec[V], migrate Migration[V]) error {
+    return self.loadSorted(r, codec, migrate, nil)
+}
+
+
+// Replace the values of this FlatSet with the values read from the reader like LoadMigrate, except the values were
+// written by SaveCompressed with this compression. The migration can be nil if the values were stored with the same
+// version of the codec.
+//
+func (self *FlatSet[V]) LoadCompressed(r io.Reader, codec Codec[V], compression Compression,
+                                       migrate Migration[V]) error {
+    return self.loadSorted(r, codec, migrate, &compression)
+}
+
+
+// Private method that loads the values and sorts them if they were written by a container with a different ordering.
+//
+func (self *FlatSet[V]) loadSorted(r io.Reader, codec Codec[V], migrate Migration[V], compression *Compression) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.Load", len(self.data))()
+    sorted, err := self.load(r, codec, migrate, compression)
+    if err != nil {
+        return err
+    }
+    if !sorted {
+        sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
+    }
+    self.removeDuplicates()
+    return nil
+}
+
+
+// Replace the values of this FlatMultiSet with the values read from the reader, that were written by Save using the
+// same codec. If the values were written by a container with a different ordering they will be sorted, and the order of
+// equivalent values will be maintained. If an error is returned the FlatMultiSet is unchanged.
+//
+func (self *FlatMultiSet[V]) Load(r io.Reader, codec Codec[V]) error {
+    return self.LoadMigrate(r, codec, nil)
+}
+
+
+// Replace the values of this FlatMultiSet with the values read from the reader like Load, except values that were
+// stored with a different version of the codec are upgraded by the migration instead of returning an error. The values
+// that are returned by the migration can be ordered differently from the values that were stored.
+//
+func (self *FlatMultiSet[V]) LoadMigrate(r io.Reader, codec Codec[V], migrate Migration[V]) error {
+    return self.loadSorted(r, codec, migrate, nil)
+}
+
+
+// Replace the values of this FlatMultiSet with the values read from the reader like LoadMigrate, except the values were
+// written by SaveCompressed with this compression. The migration can be nil if the values were stored with the same
+// version of the codec.
+//
+func (self *FlatMultiSet[V]) LoadCompressed(r io.Reader, codec Codec[V], compression Compression,
+                                            migrate Migration[V]) error {
+    return self.loadSorted(r, codec, migrate, &compression)
+}
+
+
+// Private method that loads the values and sorts them if they were written by a container with a different ordering.
+//
+func (self *FlatMultiSet[V]) loadSorted(r io.Reader, codec Codec[V], migrate Migration[V],
+                                        compression *Compression) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.Load", len(self.data))()
+    sorted, err := self.load(r, codec, migrate, compression)
+    if err != nil {
+        return err
+    }
+    if !sorted {
+        sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
+    }
+    return nil
+}
+
+
+// A Serializer binds a container to a codec so that it implements io.WriterTo and io.ReaderFrom, for APIs that copy
+// to or from any value implementing these interfaces. WriteTo writes the values like Save and ReadFrom replaces them
+// like Load, so the values are streamed one block at a time without building an intermediate array.
+//
+type Serializer struct {
+    save func(w io.Writer) error
+    load func(r io.Reader) error
+}
+
+
+// Returns a Serializer that writes and reads the values of this FlatSet using this codec.
+//
+func (self *FlatSet[V]) Serializer(codec Codec[V]) Serializer {
+    return Serializer{
+        save: func(w io.Writer) error { return self.Save(w, codec) },
+        load: func(r io.Reader) error { return self.Load(r, codec) },
+    }
+}
+
+
+// Returns a Serializer that writes and reads the values of this FlatMultiSet using this codec.
+//
+func (self *FlatMultiSet[V]) Serializer(codec Codec[V]) Serializer {
+    return Serializer{
+        save: func(w io.Writer) error { return self.Save(w, codec) },
+        load: func(r io.Reader) error { return self.Load(r, codec) },
+    }
+}
+
+
+// Implements io.WriterTo to write the values of the container like Save, and returns the number of bytes written.
+//
+func (self Serializer) WriteTo(w io.Writer) (int64, error) {
+    cw := &countingWriter{w: w}
+    err := self.save(cw)
+    return cw.n, err
+}
+
+
+// Implements io.ReaderFrom to replace the values of the container like Load, and returns the number of bytes read. Like
+// other implementations of io.ReaderFrom it reads until EOF, so the index of the blocks that follows the values is
+// read and discarded. If an error is returned the container is unchanged.
+//
+func (self Serializer) ReadFrom(r io.Reader) (int64, error) {
+    cr := &countingReader{r: r}
+    if err := self.load(cr); err != nil {
+        return cr.n, err
+    }
+    _, err := io.Copy(io.Discard, cr)
+    return cr.n, err
+}
+
+
+// Private structure that counts the bytes written to a writer.
+//
+type countingWriter struct {
+    w io.Writer     // writer the bytes are written to
+    n int64         // number of bytes written
+}
+
+
+// Private method that writes to the writer and counts the bytes that were written.
+//
+func (self *countingWriter) Write(p []byte) (int, error) {
+    n, err := self.w.Write(p)
+    self.n += int64(n)
+    return n, err
+}
+
+
+// Private structure that counts the bytes read from a reader.
+//
+type countingReader struct {
+    r io.Reader     // reader the bytes are read from
+    n int64         // number of bytes read
+}
+
+
+// Private method that reads from the reader and counts the bytes that were read.
+//
+func (self *countingReader) Read(p []byte) (int, error) {
+    n, err := self.r.Read(p)
+    self.n += int64(n)
+    return n, err
+}
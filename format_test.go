@@ -0,0 +1,359 @@
+package flatset
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/binary"
+    "errors"
+    "flag"
+    "hash/crc32"
+    "io"
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+)
+
+
+type point struct {
+    X, Y int32
+}
+
+
+func lessPoint(lhs, rhs point) bool { return lhs.X < rhs.X || (lhs.X == rhs.X && lhs.Y < rhs.Y) }
+
+
+// Test the built-in codecs decode the values they encode.
+//
+func TestCodecs(t *testing.T) {
+    var buf []byte
+    for _, value := range []int16 {0, 1, -1, 32767, -32768} {
+        buf = IntegerCodec[int16]{}.Encode(value, buf[:0])
+        if actual, n, err := (IntegerCodec[int16]{}).Decode(buf); err != nil || n != 2 || actual != value {
+            t.Errorf("IntegerCodec.Decode(): expected(%d, 2), actual(%d, %d, %v)", value, actual, n, err)
+        }
+    }
+    for _, value := range []string {"", "a", "hello world"} {
+        buf = StringCodec[string]{}.Encode(value, buf[:0])
+        if actual, n, err := (StringCodec[string]{}).Decode(buf); err != nil || n != len(buf) || actual != value {
+            t.Errorf("StringCodec.Decode(): expected(%q, %d), actual(%q, %d, %v)", value, len(buf), actual, n, err)
+        }
+    }
+    value := point{-3, 7}
+    buf = FixedCodec[point]{}.Encode(value, buf[:0])
+    if actual, n, err := (FixedCodec[point]{}).Decode(buf); err != nil || n != 8 || actual != value {
+        t.Errorf("FixedCodec.Decode(): expected(%v, 8), actual(%v, %d, %v)", value, actual, n, err)
+    }
+
+    if _, _, err := (IntegerCodec[uint64]{}).Decode(buf[:4]); err != io.ErrUnexpectedEOF {
+        t.Errorf("IntegerCodec.Decode(): expected(%v), actual(%v)", io.ErrUnexpectedEOF, err)
+    }
+    if _, _, err := (StringCodec[string]{}).Decode([]byte {5, 'a'}); err != io.ErrUnexpectedEOF {
+        t.Errorf("StringCodec.Decode(): expected(%v), actual(%v)", io.ErrUnexpectedEOF, err)
+    }
+    if _, _, err := (FixedCodec[point]{}).Decode(buf[:7]); err != io.ErrUnexpectedEOF {
+        t.Errorf("FixedCodec.Decode(): expected(%v), actual(%v)", io.ErrUnexpectedEOF, err)
+    }
+}
+
+
+// Test the values of a FlatSet and FlatMultiSet can be saved and loaded again, including across multiple blocks.
+//
+func TestSaveLoad(t *testing.T) {
+    values := make([]string, 0, 20000)
+    for i := range 20000 {
+        values = append(values, string(rune('a' + i % 26)) + string(make([]byte, i % 13)))
+    }
+
+    fs := InitFlatSet(values, lessString)
+    var buf bytes.Buffer
+    if err := fs.Save(&buf, StringCodec[string]{}); err != nil {
+        t.Fatalf("FlatSet.Save() failed: %v", err)
+    }
+    loaded := NewFlatSet(lessString)
+    loaded.Insert("stale")
+    if err := loaded.Load(bytes.NewReader(buf.Bytes()), StringCodec[string]{}); err != nil {
+        t.Fatalf("FlatSet.Load() failed: %v", err)
+    }
+    if !slices.Equal(loaded.data, fs.data) {
+        t.Errorf("FlatSet.Load(): expected(%d) values, actual(%d)", fs.Size(), loaded.Size())
+    }
+
+    ms := InitFlatMultiSet(values, lessString)
+    buf.Reset()
+    if err := ms.Save(&buf, StringCodec[string]{}); err != nil {
+        t.Fatalf("FlatMultiSet.Save() failed: %v", err)
+    }
+    if buf.Len() <= blockBytes {
+        t.Errorf("FlatMultiSet.Save(): expected more than one block, actual(%d) bytes", buf.Len())
+    }
+    reversed := NewFlatMultiSet(func(lhs, rhs string) bool { return lhs > rhs })
+    if err := reversed.Load(bytes.NewReader(buf.Bytes()), StringCodec[string]{}); err != nil {
+        t.Fatalf("FlatMultiSet.Load() failed: %v", err)
+    }
+    expected := slices.Clone(ms.data)
+    slices.Reverse(expected)
+    if !slices.Equal(reversed.data, expected) {
+        t.Errorf("FlatMultiSet.Load(): expected values in reverse order")
+    }
+}
+
+
+// Test Load returns an error and leaves the container unchanged when the serialized data is invalid.
+//
+func TestLoadInvalid(t *testing.T) {
+    fs := InitFlatSet([]point {{1, 2}, {3, 4}}, lessPoint)
+    var buf bytes.Buffer
+    if err := fs.Save(&buf, FixedCodec[point]{}); err != nil {
+        t.Fatalf("FlatSet.Save() failed: %v", err)
+    }
+    data := buf.Bytes()
+    blockEnd := headerSize + blockHeaderSize + 16 + checksumSize
+
+    magic := slices.Clone(data)
+    magic[0] = 'X'
+    block := slices.Clone(data)
+    block[headerSize + 4]--
+    checksum := slices.Clone(data)
+    checksum[blockEnd - checksumSize - 1]++
+    length := slices.Clone(data)
+    binary.LittleEndian.PutUint32(length[headerSize + 4:], 0xfffffff0)
+
+    target := InitFlatSet([]point {{5, 6}}, lessPoint)
+    for name, tc := range map[string]struct {
+        data []byte
+        err error
+    } {
+        "magic": {magic, ErrInvalidFormat},
+        "block": {block, ErrChecksum},
+        "checksum": {checksum, ErrChecksum},
+        "length": {length, ErrInvalidFormat},
+        "truncated": {data[:blockEnd - 1], io.ErrUnexpectedEOF},
+        "empty": {nil, io.EOF},
+    } {
+        if err := target.Load(bytes.NewReader(tc.data), FixedCodec[point]{}); !errors.Is(err, tc.err) {
+            t.Errorf("FlatSet.Load(%s): expected(%v), actual(%v)", name, tc.err, err)
+        }
+        if target.Size() != 1 || target.At(0) != (point{5, 6}) {
+            t.Errorf("FlatSet.Load(%s): expected the FlatSet to be unchanged", name)
+        }
+    }
+}
+
+
+// A codec for points that were stored with a third coordinate in a previous version.
+//
+type pointCodecV2 struct {
+    FixedCodec[point]
+}
+
+
+func (pointCodecV2) Version() uint16 { return 2 }
+
+
+// Test values stored with a previous version of a codec are upgraded by the migration when they are loaded.
+//
+func TestLoadMigrate(t *testing.T) {
+    type point3 struct {
+        X, Y, Z int32
+    }
+    old := InitFlatSet([]point3 {{1, 2, 9}, {3, 4, 9}, {3, 4, 8}}, func(lhs, rhs point3) bool { return lhs.Z < rhs.Z })
+    var buf bytes.Buffer
+    if err := old.Save(&buf, FixedCodec[point3]{}); err != nil {
+        t.Fatalf("FlatSet.Save() failed: %v", err)
+    }
+
+    fs := NewFlatSet(lessPoint)
+    if err := fs.Load(bytes.NewReader(buf.Bytes()), pointCodecV2{}); !errors.Is(err, ErrInvalidFormat) {
+        t.Errorf("FlatSet.Load(): expected(%v), actual(%v)", ErrInvalidFormat, err)
+    }
+
+    migrate := func(version uint16, buf []byte) (point, int, error) {
+        if version != 0 {
+            return point{}, 0, errors.New("unsupported version")
+        }
+        value, n, err := FixedCodec[point3]{}.Decode(buf)
+        return point{value.X, value.Y}, n, err
+    }
+    if err := fs.LoadMigrate(bytes.NewReader(buf.Bytes()), pointCodecV2{}, migrate); err != nil {
+        t.Fatalf("FlatSet.LoadMigrate() failed: %v", err)
+    }
+    if expected := []point {{1, 2}, {3, 4}}; !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.LoadMigrate(): expected(%v), actual(%v)", expected, fs.data)
+    }
+
+    buf.Reset()
+    if err := fs.Save(&buf, pointCodecV2{}); err != nil {
+        t.Fatalf("FlatSet.Save() failed: %v", err)
+    }
+    ms := NewFlatMultiSet(lessPoint)
+    if err := ms.LoadMigrate(bytes.NewReader(buf.Bytes()), pointCodecV2{}, migrate); err != nil || ms.Size() != 2 {
+        t.Errorf("FlatMultiSet.LoadMigrate(): expected(2, nil), actual(%d, %v)", ms.Size(), err)
+    }
+}
+
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+
+// Test the serialized format matches the golden files in testdata, so that the format does not change between versions
+// of this package or depend on the architecture where the data was saved.
+//
+func TestGoldenFormat(t *testing.T) {
+    golden := func(name string, save func(w io.Writer) error, load func(r io.Reader) error) {
+        var buf bytes.Buffer
+        if err := save(&buf); err != nil {
+            t.Fatalf("Save(%s) failed: %v", name, err)
+        }
+        path := filepath.Join("testdata", name + ".golden")
+        if *update {
+            if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+                t.Fatal(err)
+            }
+        }
+        expected, err := os.ReadFile(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !bytes.Equal(buf.Bytes(), expected) {
+            t.Errorf("Save(%s): expected(%x), actual(%x)", name, expected, buf.Bytes())
+        }
+        if err := load(bytes.NewReader(expected)); err != nil {
+            t.Errorf("Load(%s) failed: %v", name, err)
+        }
+    }
+
+    ints := InitFlatSet([]int {-1 << 30, -2, 0, 3, 1 << 30}, lessInt)
+    legacy, err := os.ReadFile(filepath.Join("testdata", "int-nochecksum.golden"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    loaded := NewFlatSet(lessInt)
+    if err := loaded.Load(bytes.NewReader(legacy), IntegerCodec[int]{}); err != nil || !slices.Equal(loaded.data,
+                                                                                                     ints.data) {
+        t.Errorf("FlatSet.Load(int-nochecksum): expected(%v), actual(%v, %v)", ints.data, loaded.data, err)
+    }
+
+    golden("int", func(w io.Writer) error { return ints.Save(w, IntegerCodec[int]{}) }, func(r io.Reader) error {
+        loaded := NewFlatSet(lessInt)
+        err := loaded.Load(r, IntegerCodec[int]{})
+        if !slices.Equal(loaded.data, ints.data) {
+            t.Errorf("FlatSet.Load(int): expected(%v), actual(%v)", ints.data, loaded.data)
+        }
+        return err
+    })
+
+    strs := InitFlatMultiSet([]string {"b", "", "héllo", "b"}, lessString)
+    golden("string", func(w io.Writer) error { return strs.Save(w, StringCodec[string]{}) }, func(r io.Reader) error {
+        loaded := NewFlatMultiSet(lessString)
+        err := loaded.Load(r, StringCodec[string]{})
+        if !slices.Equal(loaded.data, strs.data) {
+            t.Errorf("FlatMultiSet.Load(string): expected(%q), actual(%q)", strs.data, loaded.data)
+        }
+        return err
+    })
+
+    points := InitFlatSet([]point {{-1, 2}, {3, -4}}, lessPoint)
+    golden("point", func(w io.Writer) error { return points.Save(w, pointCodecV2{}) }, func(r io.Reader) error {
+        loaded := NewFlatSet(lessPoint)
+        err := loaded.Load(r, pointCodecV2{})
+        if !slices.Equal(loaded.data, points.data) {
+            t.Errorf("FlatSet.Load(point): expected(%v), actual(%v)", points.data, loaded.data)
+        }
+        return err
+    })
+}
+
+
+// Test the values can be saved with compression and loaded again, and compressed values cannot be loaded by Load.
+//
+func TestSaveLoadCompressed(t *testing.T) {
+    values := make([]uint32, 0, 50000)
+    for i := range 50000 {
+        values = append(values, uint32(i * 3))
+    }
+    fs := InitFlatSet(values, func(lhs, rhs uint32) bool { return lhs < rhs })
+    var plain, compressed bytes.Buffer
+    if err := fs.Save(&plain, IntegerCodec[uint32]{}); err != nil {
+        t.Fatalf("FlatSet.Save() failed: %v", err)
+    }
+    if err := fs.SaveCompressed(&compressed, IntegerCodec[uint32]{}, Gzip); err != nil {
+        t.Fatalf("FlatSet.SaveCompressed() failed: %v", err)
+    }
+    if compressed.Len() >= plain.Len() {
+        t.Errorf("FlatSet.SaveCompressed(): expected less than (%d) bytes, actual(%d)", plain.Len(), compressed.Len())
+    }
+
+    loaded := NewFlatSet(fs.cmp)
+    if err := loaded.Load(bytes.NewReader(compressed.Bytes()), IntegerCodec[uint32]{}); !errors.Is(err,
+                                                                                                   ErrInvalidFormat) {
+        t.Errorf("FlatSet.Load(): expected(%v), actual(%v)", ErrInvalidFormat, err)
+    }
+    err := loaded.LoadCompressed(bytes.NewReader(compressed.Bytes()), IntegerCodec[uint32]{}, Gzip, nil)
+    if err != nil || !slices.Equal(loaded.data, fs.data) {
+        t.Errorf("FlatSet.LoadCompressed(): expected(%d) values, actual(%d, %v)", fs.Size(), loaded.Size(), err)
+    }
+
+    ms := NewFlatMultiSet(fs.cmp)
+    err = ms.LoadCompressed(bytes.NewReader(plain.Bytes()), IntegerCodec[uint32]{}, Gzip, nil)
+    if err != nil || !slices.Equal(ms.data, fs.data) {
+        t.Errorf("FlatMultiSet.LoadCompressed(): expected(%d) values, actual(%d, %v)", fs.Size(), ms.Size(), err)
+    }
+}
+
+
+// Test the size of the values and blocks is limited, so that corrupted data can not allocate a huge buffer.
+//
+func TestSaveLoadLimits(t *testing.T) {
+    fs := InitFlatSet([]string {"a", string(make([]byte, maxValueBytes + 1))}, lessString)
+    if err := fs.Save(io.Discard, StringCodec[string]{}); !errors.Is(err, ErrValueTooLarge) {
+        t.Errorf("FlatSet.Save(): expected(%v), actual(%v)", ErrValueTooLarge, err)
+    }
+
+    // a block with a valid checksum that decompresses into more than the largest block
+    var buf bytes.Buffer
+    if err := InitFlatSet([]string {"a"}, lessString).SaveCompressed(&buf, StringCodec[string]{}, Gzip); err != nil {
+        t.Fatalf("FlatSet.SaveCompressed() failed: %v", err)
+    }
+    var payload bytes.Buffer
+    zw := gzip.NewWriter(&payload)
+    zw.Write(make([]byte, maxBlockBytes + 1))
+    zw.Close()
+    block := binary.LittleEndian.AppendUint32(nil, 1)
+    block = binary.LittleEndian.AppendUint32(block, uint32(payload.Len()))
+    block = append(block, payload.Bytes()...)
+    block = binary.LittleEndian.AppendUint32(block, crc32.Checksum(block, castagnoli))
+    data := append(slices.Clone(buf.Bytes()[:headerSize]), block...)
+
+    loaded := NewFlatSet[string](lessString)
+    err := loaded.LoadCompressed(bytes.NewReader(data), StringCodec[string]{}, Gzip, nil)
+    if !errors.Is(err, ErrInvalidFormat) || loaded.Size() != 0 {
+        t.Errorf("FlatSet.LoadCompressed(): expected(%v), actual(%v)", ErrInvalidFormat, err)
+    }
+}
+
+
+// Test a Serializer writes and reads the values of a container, and counts the bytes written and read.
+//
+func TestSerializer(t *testing.T) {
+    fs := InitFlatSet([]int64 {5, 3, 1, 3}, func(lhs, rhs int64) bool { return lhs < rhs })
+    var buf bytes.Buffer
+    var writer io.WriterTo = fs.Serializer(IntegerCodec[int64]{})
+    written, err := writer.WriteTo(&buf)
+    if err != nil || written != int64(buf.Len()) {
+        t.Fatalf("Serializer.WriteTo(): expected(%d, nil), actual(%d, %v)", buf.Len(), written, err)
+    }
+    size := buf.Len()
+
+    ms := NewFlatMultiSet(func(lhs, rhs int64) bool { return lhs < rhs })
+    read, err := ms.Serializer(IntegerCodec[int64]{}).ReadFrom(&buf)
+    if err != nil || read != int64(size) {
+        t.Errorf("Serializer.ReadFrom(): expected(%d, nil), actual(%d, %v)", size, read, err)
+    }
+    if expected := []int64 {1, 3, 5}; !slices.Equal(ms.data, expected) {
+        t.Errorf("Serializer.ReadFrom(): expected(%v), actual(%v)", expected, ms.data)
+    }
+    if _, err := ms.Serializer(IntegerCodec[int64]{}).ReadFrom(bytes.NewReader([]byte("FSET"))); err == nil {
+        t.Errorf("Serializer.ReadFrom(): expected(an error), actual(nil)")
+    }
+}
@@ -0,0 +1,308 @@
+package flatset
+
+
+import (
+    "errors"
+    "iter"
+    "reflect"
+    "unsafe"
+)
+
+// A Layout defines how the values of a FrozenFlatSet are arranged in memory for searching.
+//
+type Layout int
+
+const (
+    // The values are searched with a binary search of the sorted array.
+    SortedLayout Layout = iota
+
+    // The sorted array is split into blocks the size of a cache line, and a small index of the greatest value in each
+    // block is searched first so that the search of the array only touches a single block. This reduces the cache
+    // misses for each search of sets that are much larger than the CPU cache.
+    BlockLayout
+
+    // A copy of the values is arranged as a complete binary search tree in van Emde Boas order, where the top half of
+    // the tree is stored before each of the subtrees below it and the same order is repeated within each half. The
+    // nodes visited by each search are close together in memory for every cache size, so this reduces the cache misses
+    // of very large sets without knowing the cache hierarchy, at the cost of storing the values twice.
+    VanEmdeBoasLayout
+)
+
+
+// The size in bytes of the blocks that are used by the BlockLayout.
+//
+const cacheLine = 64
+
+
+// A FrozenFlatSet is a read-only copy of a FlatSet that can arrange its values in memory using a Layout that is
+// optimized for searching. As it can not be modified the indices of the values are never invalidated.
+//
+type FrozenFlatSet[V any] struct {
+    sorted base[V]  // values stored in sorted order
+    layout Layout   // layout used to search the values
+    block int       // number of values in each block of the BlockLayout
+    index []V       // greatest value in each block of the BlockLayout
+    tree []V        // values in van Emde Boas order for the VanEmdeBoasLayout
+    height int      // height of the tree for the VanEmdeBoasLayout
+}
+
+
+// Private function that returns the in-order ranks of the nodes of a complete binary tree with this height, listed in
+// van Emde Boas order. The top half of the tree is listed first followed by each of the subtrees below it from left to
+// right, and each half is listed in van Emde Boas order.
+//
+func vebOrder(height int) []int {
+    if height == 1 {
+        return []int{0}
+    }
+    topHeight := height / 2
+    top, bottom := vebOrder(topHeight), vebOrder(height - topHeight)
+    stride := 1 << (height - topHeight)
+
+    out := make([]int, 0, 1 << height - 1)
+    for _, rank := range top {
+        out = append(out, (rank + 1) * stride - 1)
+    }
+    for k := 0; k < 1 << topHeight; k++ {
+        for _, rank := range bottom {
+            out = append(out, k * stride + rank)
+        }
+    }
+    return out
+}
+
+
+// Returns a read-only copy of this FlatSet that is searched using this layout. The FlatSet can still be modified
+// without affecting the FrozenFlatSet.
+//
+func (self *FlatSet[V]) Freeze(layout Layout) *FrozenFlatSet[V] {
+    return freeze(append([]V(nil), self.data...), self.cmp, layout)
+}
+
+
+// Returns a read-only snapshot of this FlatSet that shares its array instead of copying it, so taking a snapshot is
+// constant time. The array is copied the first time this FlatSet modifies a value that is in the snapshot, so that
+// long running readers can iterate a consistent snapshot while the FlatSet keeps being modified, without a copy for
+// every reader. The snapshot uses the SortedLayout. Values that are appended, such as by PushBack, do not copy the
+// array as they are not in the snapshot.
+//
+func (self *FlatSet[V]) Snapshot() *FrozenFlatSet[V] {
+    if debug {
+        defer self.guard.update()()
+    }
+    self.shared = true
+    size := len(self.data)
+    return freeze(self.data[:size:size], self.cmp, SortedLayout)
+}
+
+
+// Private function that creates a FrozenFlatSet that owns this array of values sorted by the comparison function.
+//
+func freeze[V any](data []V, cmp Compare[V], layout Layout) *FrozenFlatSet[V] {
+    out := &FrozenFlatSet[V]{sorted: base[V]{cmp: cmp, data: data}, layout: layout}
+
+    size := len(out.sorted.data)
+    if layout == VanEmdeBoasLayout && size > 0 {
+        for 1 << out.height - 1 < size {
+            out.height++
+        }
+        // the tree is padded with copies of the greatest value so that it is complete
+        out.tree = make([]V, 1 << out.height - 1)
+        for i, rank := range vebOrder(out.height) {
+            out.tree[i] = out.sorted.data[min(rank, size - 1)]
+        }
+    } else if layout == BlockLayout {
+        var zero V
+        out.block = max(cacheLine / max(int(unsafe.Sizeof(zero)), 1), 4)
+        for upto := out.block; upto - out.block < size; upto += out.block {
+            out.index = append(out.index, out.sorted.data[min(upto, size) - 1])
+        }
+    }
+    return out
+}
+
+
+// Private method to search a subtree of the van Emde Boas tree stored from this offset, and return the number of nodes
+// in the subtree where the comparison with the value is true. The top half of the subtree is searched first to find
+// which of the subtrees below it to search next.
+//
+func (self *FrozenFlatSet[V]) vebSearch(value V, offset, height int, cmp Compare[V]) int {
+    if height == 1 {
+        if cmp(self.tree[offset], value) {
+            return 1
+        }
+        return 0
+    }
+    topHeight := height / 2
+    bottomHeight := height - topHeight
+    k := self.vebSearch(value, offset, topHeight, cmp)
+    offset += 1 << topHeight - 1 + k * (1 << bottomHeight - 1)
+    return k << bottomHeight + self.vebSearch(value, offset, bottomHeight, cmp)
+}
+
+
+// Private method to search for a value using the layout of this container and a comparison function.
+//
+func (self *FrozenFlatSet[V]) bounds(value V, cmp Compare[V]) int {
+    switch self.layout {
+    case VanEmdeBoasLayout:
+        if self.height == 0 {
+            return 0
+        }
+        return min(self.vebSearch(value, 0, self.height, cmp), len(self.sorted.data))
+    case BlockLayout:
+        index := base[V]{data: self.index}
+        b := index.bounds(value, 0, len(self.index) - 1, cmp)
+        if b == len(self.index) {
+            return len(self.sorted.data)
+        }
+        from := b * self.block
+        return self.sorted.bounds(value, from, min(from + self.block, len(self.sorted.data)) - 1, cmp)
+    default:
+        return self.sorted.bounds(value, 0, len(self.sorted.data) - 1, cmp)
+    }
+}
+
+
+// Returns the layout that is used to search this container.
+//
+func (self *FrozenFlatSet[V]) Layout() Layout {
+    return self.layout
+}
+
+
+// Returns a copy of the value at the given index.
+//
+func (self *FrozenFlatSet[V]) At(index int) V {
+    return self.sorted.At(index)
+}
+
+
+// Returns the number of values stored in this container.
+//
+func (self *FrozenFlatSet[V]) Size() int {
+    return self.sorted.Size()
+}
+
+
+// Returns an iterator that returns a copy of each value in order.
+//
+func (self *FrozenFlatSet[V]) All() iter.Seq[V] {
+    return self.sorted.All()
+}
+
+
+// Returns an iterator that iterates in reverse order returning a copy of each value.
+//
+func (self *FrozenFlatSet[V]) Backward() iter.Seq[V] {
+    return self.sorted.Backward()
+}
+
+
+// Returns an index to the first value in the range where the comparison is not less than.
+//
+func (self *FrozenFlatSet[V]) LowerBound(value V) int {
+    return self.bounds(value, self.sorted.cmp)
+}
+
+
+// Returns an index to the first value in the range where the comparison is greater.
+//
+func (self *FrozenFlatSet[V]) UpperBound(value V) int {
+    return self.bounds(value, func(lhs, rhs V) bool { return !self.sorted.cmp(rhs, lhs) })
+}
+
+
+// Searches for a value within this container and returns the index of the lower bound and true if an equivalent value
+// is found at this index, otherwise it returns the index where the value would be inserted and false.
+//
+func (self *FrozenFlatSet[V]) Search(value V) (int, bool) {
+    lb := self.LowerBound(value)
+    return lb, lb < len(self.sorted.data) && !self.sorted.cmp(value, self.sorted.data[lb])
+}
+
+
+// Returns true if this container has this value or false if it does not.
+//
+func (self *FrozenFlatSet[V]) Contains(value V) bool {
+    _, found := self.Search(value)
+    return found
+}
+
+
+// Searches for a value within this container, and returns the index for the location of the value or -1 if not found.
+//
+func (self *FrozenFlatSet[V]) Find(value V) int {
+    lb, found := self.Search(value)
+    if found {
+        return lb
+    } else {
+        return -1
+    }
+}
+
+
+// Private function that returns true if values of this type have a fixed size and do not contain any pointers, so that
+// they can be safely reinterpreted from an array of bytes.
+//
+func isFixedSize(t reflect.Type) bool {
+    switch t.Kind() {
+    case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint,
+        reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32,
+        reflect.Float64, reflect.Complex64, reflect.Complex128:
+        return true
+    case reflect.Array:
+        return isFixedSize(t.Elem())
+    case reflect.Struct:
+        for i := 0; i < t.NumField(); i++ {
+            if !isFixedSize(t.Field(i).Type) {
+                return false
+            }
+        }
+        return true
+    default:
+        return false
+    }
+}
+
+
+// Create a FrozenFlatSet that uses the SortedLayout to search the fixed size values stored in this array of bytes,
+// without copying or decoding them. The bytes must contain the values in the order defined by the comparison function
+// in the memory representation of this machine, such as the bytes returned by the Bytes method on a machine with the
+// same architecture. The values must not contain any pointers, the length of the bytes must be a multiple of the size
+// of the values and the bytes must be aligned for the values, otherwise an error is returned. The FrozenFlatSet refers
+// to these bytes so they must not be modified while it is in use.
+//
+func ViewFrozenFlatSet[V any](buf []byte, cmp Compare[V]) (*FrozenFlatSet[V], error) {
+    var zero V
+    size, align := int(unsafe.Sizeof(zero)), uintptr(unsafe.Alignof(zero))
+    out := &FrozenFlatSet[V]{sorted: base[V]{cmp: cmp}, layout: SortedLayout}
+
+    if !isFixedSize(reflect.TypeFor[V]()) {
+        return nil, errors.New("flatset: values must have a fixed size without pointers to view them as bytes")
+    } else if size == 0 || len(buf) % size != 0 {
+        return nil, errors.New("flatset: length of the bytes is not a multiple of the size of the values")
+    } else if len(buf) > 0 {
+        ptr := unsafe.Pointer(unsafe.SliceData(buf))
+        if uintptr(ptr) % align != 0 {
+            return nil, errors.New("flatset: bytes are not aligned for the values")
+        }
+        out.sorted.data = unsafe.Slice((*V)(ptr), len(buf) / size)
+    }
+    return out, nil
+}
+
+
+// Returns the values of this container as an array of bytes in the memory representation of this machine without
+// copying them, so they can be written to a file and viewed again with ViewFrozenFlatSet. The values must have a fixed
+// size without any pointers, otherwise an error is returned. The bytes refer to this container so they must not be
+// modified.
+//
+func (self *FrozenFlatSet[V]) Bytes() ([]byte, error) {
+    if !isFixedSize(reflect.TypeFor[V]()) {
+        return nil, errors.New("flatset: values must have a fixed size without pointers to view them as bytes")
+    }
+    var zero V
+    data := self.sorted.data
+    return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(data))), len(data) * int(unsafe.Sizeof(zero))), nil
+}
@@ -0,0 +1,151 @@
+package flatset
+
+import (
+    "slices"
+    "testing"
+)
+
+
+// Test the FrozenFlatSet layouts return the same bounds as the FlatSet they were created from.
+//
+func TestFrozenLayouts(t *testing.T) {
+    for size := 0; size < 100; size += 7 {
+        fs := InitFlatSet(randInt(0, 200, size), lessInt)
+        for _, layout := range []Layout {SortedLayout, BlockLayout, VanEmdeBoasLayout} {
+            frozen := fs.Freeze(layout)
+            if !slices.Equal(slices.Collect(frozen.All()), fs.data) {
+                t.Errorf("FlatSet.Freeze(%d) size(%d): unexpected values", layout, size)
+            }
+            for value := -1; value <= 201; value++ {
+                if actual, expected := frozen.LowerBound(value), fs.LowerBound(value); actual != expected {
+                    t.Errorf("FrozenFlatSet.LowerBound(%d) layout(%d) size(%d): expected(%d), actual(%d)", value,
+                             layout, size, expected, actual)
+                }
+                if actual, expected := frozen.UpperBound(value), fs.UpperBound(value); actual != expected {
+                    t.Errorf("FrozenFlatSet.UpperBound(%d) layout(%d) size(%d): expected(%d), actual(%d)", value,
+                             layout, size, expected, actual)
+                }
+                if actual, expected := frozen.Contains(value), fs.Contains(value); actual != expected {
+                    t.Errorf("FrozenFlatSet.Contains(%d) layout(%d) size(%d): expected(%t), actual(%t)", value,
+                             layout, size, expected, actual)
+                }
+            }
+        }
+    }
+}
+
+
+// Test the values of a FrozenFlatSet can be viewed as bytes and a new FrozenFlatSet can be viewed from these bytes.
+//
+func TestFrozenBytes(t *testing.T) {
+    type point struct {
+        x, y int32
+    }
+    fs := InitFlatSet([]point {{3, 1}, {1, 2}, {2, 0}}, func(lhs, rhs point) bool { return lhs.x < rhs.x })
+
+    buf, err := fs.Freeze(SortedLayout).Bytes()
+    if err != nil || len(buf) != 24 {
+        t.Fatalf("FrozenFlatSet.Bytes(): expected(24 bytes), actual(%d bytes, %v)", len(buf), err)
+    }
+    file := append(make([]byte, 0, len(buf)), buf...)
+
+    view, err := ViewFrozenFlatSet(file, fs.cmp)
+    if err != nil || !slices.Equal(slices.Collect(view.All()), fs.data) || view.Find(point{2, 0}) != 1 {
+        t.Errorf("ViewFrozenFlatSet(): expected(%v), actual(%v, %v)", fs.data, slices.Collect(view.All()), err)
+    }
+
+    if _, err := ViewFrozenFlatSet(file[:23], fs.cmp); err == nil {
+        t.Errorf("ViewFrozenFlatSet() expected an error for a partial value")
+    }
+    if _, err := ViewFrozenFlatSet(append([]byte {0}, file...)[1:], fs.cmp); err == nil {
+        t.Errorf("ViewFrozenFlatSet() expected an error for bytes that are not aligned")
+    }
+    if _, err := ViewFrozenFlatSet([]byte {}, lessString); err == nil {
+        t.Errorf("ViewFrozenFlatSet() expected an error for values with pointers")
+    }
+}
+
+
+func bmFrozen(layout Layout) *FrozenFlatSet[int] {
+    return InitFlatSet(randInt(0, 100000000, 4000000), lessInt).Freeze(layout)
+}
+
+
+// Search a large set with a binary search of the sorted array.
+//
+func BenchmarkFrozenSorted(b *testing.B) {
+    frozen := bmFrozen(SortedLayout)
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for _, value := range bmBatchProbes {
+            frozen.Contains(value)
+        }
+    }
+}
+
+
+// Search a large set using the index of blocks to reduce the cache misses.
+//
+func BenchmarkFrozenBlock(b *testing.B) {
+    frozen := bmFrozen(BlockLayout)
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for _, value := range bmBatchProbes {
+            frozen.Contains(value)
+        }
+    }
+}
+
+
+// Search a large set using the van Emde Boas tree to reduce the cache misses.
+//
+func BenchmarkFrozenVanEmdeBoas(b *testing.B) {
+    frozen := bmFrozen(VanEmdeBoasLayout)
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for _, value := range bmBatchProbes {
+            frozen.Contains(value)
+        }
+    }
+}
+
+
+// Test a Snapshot shares the array of the FlatSet until the FlatSet modifies a value in the snapshot.
+//
+func TestSnapshot(t *testing.T) {
+    fs := InitFlatSet([]int {1, 3, 5, 7}, lessInt)
+    fs.Reserve(10)
+    snapshot := fs.Snapshot()
+    fs.PushBack(9)
+    if &fs.data[0] != &snapshot.sorted.data[0] {
+        t.Errorf("FlatSet.PushBack(9): expected(shared array), actual(copied array)")
+    }
+
+    modify := []func() {
+        func() { fs.Insert(4) },
+        func() { fs.Erase(0) },
+        func() { fs.Replace(1, 2) },
+        func() { fs.Update(slices.Values([]int {0, 6})) },
+        func() { fs.Merge(InitFlatSet([]int {8}, lessInt)) },
+        func() { fs.EraseIndices([]int {1, 2}) },
+        func() { fs.MapInPlace(func(value int) int { return value * 2 }) },
+        func() { fs.AtRef(0) },
+    }
+    for i, fn := range modify {
+        fs = InitFlatSet([]int {1, 3, 5, 7}, lessInt)
+        fs.Reserve(10)
+        snapshot = fs.Snapshot()
+        fn()
+        if !slices.Equal(slices.Collect(snapshot.All()), []int {1, 3, 5, 7}) || !snapshot.Contains(3) {
+            t.Errorf("FlatSet.Snapshot(%d): expected([1 3 5 7]), actual(%v)", i, slices.Collect(snapshot.All()))
+        }
+    }
+
+    fs = InitFlatSet([]int {1, 3, 5, 7}, lessInt)
+    snapshot = fs.Snapshot()
+    released := fs.Release()
+    released[0] = 100
+    if snapshot.At(0) != 1 {
+        t.Errorf("FlatSet.Release(): expected(1), actual(%d)", snapshot.At(0))
+    }
+}
@@ -0,0 +1,50 @@
+//go:build !flatsetdebug
+
+package flatset
+
+
+// Enables the detection of unsynchronized use of the containers when built with the flatsetdebug build tag.
+//
+const debug = false
+
+
+// A guard detects the unsynchronized use of a container, and modifications of a container while it is being iterated,
+// in debug builds. It has no size or cost in other builds.
+//
+type guard struct{}
+
+
+// Private method that marks the start of a method that modifies the container, and returns a function to mark the end.
+//
+func (self *guard) write() func() {
+    return nil
+}
+
+
+// Private method that marks the start of a method that modifies values in place without moving them, and returns a
+// function to mark the end.
+//
+func (self *guard) update() func() {
+    return nil
+}
+
+
+// Private method that marks the start of a method that reads the container, and returns a function to mark the end.
+//
+func (self *guard) read() func() {
+    return nil
+}
+
+
+// Private method that returns the number of times the container has been modified.
+//
+func (self *guard) generation() uint64 {
+    return 0
+}
+
+
+// Private method that is called by an iterator after each value, to check the container has not been modified since
+// this generation.
+//
+func (self *guard) iterated(generation uint64) {
+}
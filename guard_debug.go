@@ -0,0 +1,100 @@
+//go:build flatsetdebug
+
+package flatset
+
+
+import (
+    "bytes"
+    "runtime"
+    "strconv"
+    "sync/atomic"
+)
+
+// Enables the detection of unsynchronized use of the containers when built with the flatsetdebug build tag.
+//
+const debug = true
+
+
+// A guard detects the unsynchronized use of a container in debug builds. It records the goroutine that is modifying
+// the container and the number of goroutines that are reading it, and panics when a goroutine modifies the container
+// while another goroutine is reading or modifying it, instead of silently corrupting the values. It also counts the
+// modifications so that the iterators can panic if the container is modified while they are iterating, like a map.
+// The fields are updated with the sync/atomic functions rather than the atomic types, so that a container can still be
+// copied by the methods with a value receiver, and the 64-bit fields are first so that they are aligned.
+//
+type guard struct {
+    writer int64            // id of the goroutine that is modifying the container, or 0
+    modified uint64         // number of times the container has been modified
+    readers int32           // number of methods that are reading the container
+}
+
+
+// Private function that returns the id of the current goroutine, parsed from the first line of its stack trace.
+//
+func goroutineID() int64 {
+    var buf [64]byte
+    line := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+    id, _ := strconv.ParseInt(string(line[:bytes.IndexByte(line, ' ')]), 10, 64)
+    return id
+}
+
+
+// Private method that marks the start of a method that modifies the container, and returns a function to mark the end.
+// It panics if another goroutine is reading or modifying the container. The modification is counted even when it is
+// nested within another method of the same goroutine, such as Set inserting a new key after it has searched for it.
+//
+func (self *guard) write() func() {
+    done := self.update()
+    atomic.AddUint64(&self.modified, 1)
+    return done
+}
+
+
+// Private method that marks the start of a method that modifies values in place without moving them, and returns a
+// function to mark the end. It panics like write, but it does not stop the iterators as no values are skipped.
+//
+func (self *guard) update() func() {
+    id := goroutineID()
+    if !atomic.CompareAndSwapInt64(&self.writer, 0, id) {
+        if atomic.LoadInt64(&self.writer) != id {
+            panic("flatset: concurrent modification of a container by multiple goroutines")
+        }
+        return func() {}
+    }
+    if atomic.LoadInt32(&self.readers) > 0 {
+        atomic.StoreInt64(&self.writer, 0)
+        panic("flatset: concurrent modification and read of a container by multiple goroutines")
+    }
+    return func() { atomic.StoreInt64(&self.writer, 0) }
+}
+
+
+// Private method that marks the start of a method that reads the container, and returns a function to mark the end.
+// It panics if another goroutine is modifying the container.
+//
+func (self *guard) read() func() {
+    id := goroutineID()
+    atomic.AddInt32(&self.readers, 1)
+    if writer := atomic.LoadInt64(&self.writer); writer != 0 && writer != id {
+        atomic.AddInt32(&self.readers, -1)
+        panic("flatset: concurrent read and modification of a container by multiple goroutines")
+    }
+    return func() { atomic.AddInt32(&self.readers, -1) }
+}
+
+
+// Private method that returns the number of times the container has been modified.
+//
+func (self *guard) generation() uint64 {
+    return atomic.LoadUint64(&self.modified)
+}
+
+
+// Private method that is called by an iterator after each value, to check the container has not been modified since
+// this generation. It panics if the container was modified, as the remaining values could be skipped or repeated.
+//
+func (self *guard) iterated(generation uint64) {
+    if atomic.LoadUint64(&self.modified) != generation {
+        panic("flatset: container modified during iteration")
+    }
+}
@@ -0,0 +1,110 @@
+//go:build flatsetdebug
+
+package flatset
+
+
+import (
+    "sync"
+    "testing"
+)
+
+// Test that reading or modifying a container while another goroutine is modifying it panics in debug builds.
+//
+func TestConcurrentMisuse(t *testing.T) {
+    entered, blocked := make(chan struct{}), make(chan struct{})
+    var once sync.Once
+    cmp := func(lhs, rhs int) bool {
+        if lhs == -1 || rhs == -1 {
+            once.Do(func() { close(entered) })
+            <-blocked
+        }
+        return lhs < rhs
+    }
+    fs := InitFlatSet([]int {1, 2, 3}, cmp)
+
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        fs.Insert(-1)
+    }()
+    <-entered
+
+    panics := func(fn func()) (panicked bool) {
+        defer func() { panicked = recover() != nil }()
+        fn()
+        return
+    }
+    if !panics(func() { fs.Contains(2) }) {
+        t.Errorf("FlatSet.Contains(2): expected(panic), actual(none)")
+    }
+    if !panics(func() { fs.Insert(4) }) {
+        t.Errorf("FlatSet.Insert(4): expected(panic), actual(none)")
+    }
+    close(blocked)
+    <-done
+
+    if !fs.Contains(2) || fs.Size() != 4 {
+        t.Errorf("FlatSet.Contains(2): expected(true, 4), actual(%t, %d)", fs.Contains(2), fs.Size())
+    }
+}
+
+
+// Test that modifying a container while iterating over it panics in debug builds.
+//
+func TestModifiedDuringIteration(t *testing.T) {
+    panics := func(fn func()) (panicked bool) {
+        defer func() { panicked = recover() != nil }()
+        fn()
+        return
+    }
+    fs := InitFlatSet([]int {1, 2, 3}, lessInt)
+    if !panics(func() { for value := range fs.All() { fs.Insert(value + 10) } }) {
+        t.Errorf("FlatSet.All(): expected(panic), actual(none)")
+    }
+    if !panics(func() { for value := range fs.Backward() { fs.Remove(value) } }) {
+        t.Errorf("FlatSet.Backward(): expected(panic), actual(none)")
+    }
+    if !panics(func() { for value := range fs.ContainsEach(fs.All()) { fs.Insert(value + 20) } }) {
+        t.Errorf("FlatSet.ContainsEach(): expected(panic), actual(none)")
+    }
+
+    if panics(func() { for range fs.All() { fs.Snapshot() } }) {
+        t.Errorf("FlatSet.Snapshot(): expected(no panic), actual(panic)")
+    }
+
+    ms := InitFlatMultiSet([]int {1, 2, 2}, lessInt)
+    if panics(func() { for value := range ms.All() { _ = ms.Contains(value) } }) {
+        t.Errorf("FlatMultiSet.All(): expected(no panic), actual(panic)")
+    }
+    if panics(func() { for range ms.All() { break }; ms.Insert(3) }) {
+        t.Errorf("FlatMultiSet.Insert(3): expected(no panic), actual(panic)")
+    }
+
+    fm := FromMap(map[int]string {1: "a", 2: "b"}, lessInt)
+    if !panics(func() { for key := range fm.Keys() { fm.Set(key + 10, "c") } }) {
+        t.Errorf("FlatMap.Keys(): expected(panic), actual(none)")
+    }
+    if !panics(func() { for key := range fm.Entries() { fm.Delete(key) } }) {
+        t.Errorf("FlatMap.Entries(): expected(panic), actual(none)")
+    }
+    if panics(func() { for key := range fm.Keys() { fm.UpdateValue(key, func(*string) {}); _, _ = fm.Get(key) } }) {
+        t.Errorf("FlatMap.Get(): expected(no panic), actual(panic)")
+    }
+    if panics(func() { for key, value := range fm.Entries() { fm.Set(key, value + "x") } }) {
+        t.Errorf("FlatMap.Set(): expected(no panic), actual(panic)")
+    }
+    mm := MakeFlatMultiMap[int, string](lessInt)
+    mm.Insert(1, "a")
+    if !panics(func() { for value := range mm.ValuesFor(1) { mm.Insert(1, value) } }) {
+        t.Errorf("FlatMultiMap.ValuesFor(1): expected(panic), actual(none)")
+    }
+
+    ss := InitStringSet([]string {"a", "b"})
+    if !panics(func() { for value := range ss.All() { ss.Insert(value + "x") } }) {
+        t.Errorf("StringSet.All(): expected(panic), actual(none)")
+    }
+    ds := InitDenseIntSet([]int {1, 2, 3})
+    if !panics(func() { for value := range ds.Backward() { ds.Remove(value) } }) {
+        t.Errorf("DenseIntSet.Backward(): expected(panic), actual(none)")
+    }
+}
@@ -0,0 +1,56 @@
+package flatset
+
+
+import (
+    "math/bits"
+)
+
+// This is the constraint for the numeric types that can be searched with InterpolationSearch.
+//
+type Number interface {
+    Integer | ~float32 | ~float64
+}
+
+
+// Searches for a value within a FlatSet of numbers that is sorted in ascending order and returns the index of the lower
+// bound and true if the value is found at this index, otherwise it returns the index where the value would be inserted
+// and false. Instead of halving the range each time, the position of the value is estimated from the values at each end
+// of the range. If the values are roughly uniformly distributed this will take O(log log n) operations instead of the
+// O(log n) operations of Search. If the estimates do not find the value within log n steps the remaining range is
+// searched with a binary search, so the worst case is O(log n). The values are only known to be sorted with the <
+// operator if the FlatSet was created by NewOrderedFlatSet or InitOrderedFlatSet, so for any other comparison function
+// this function falls back to Search.
+//
+func InterpolationSearch[V Number](self *FlatSet[V], value V) (int, bool) {
+    data := self.data
+    size := len(data)
+    if !isLessOrdered(self.cmp) {
+        return self.Search(value)
+    }
+
+    lo, hi := 0, size - 1
+    for steps := bits.Len(uint(size)); lo <= hi; steps-- {
+        if value <= data[lo] {
+            return lo, value == data[lo]
+        } else if value > data[hi] {
+            return hi + 1, false
+        } else if value == data[hi] {
+            return hi, true
+        } else if steps == 0 {
+            lb := self.bounds(value, lo, hi, self.cmp)
+            return lb, lb < size && data[lb] == value
+        }
+
+        // data[lo] < value < data[hi] so the estimate is between lo and hi exclusive
+        pos := lo + int(float64(value - data[lo]) * float64(hi - lo) / float64(data[hi] - data[lo]))
+        pos = min(max(pos, lo + 1), hi - 1)
+        if data[pos] < value {
+            lo = pos + 1
+        } else if value < data[pos] {
+            hi = pos - 1
+        } else {
+            return pos, true
+        }
+    }
+    return lo, false
+}
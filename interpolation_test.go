@@ -0,0 +1,59 @@
+package flatset
+
+import (
+    "testing"
+)
+
+
+// Test the InterpolationSearch function returns the same results as Search for uniform, skewed and descending sets.
+//
+func TestInterpolationSearch(t *testing.T) {
+    skewed := []int {1, 2, 3, 4, 5, 6, 7, 8, 1000000, 1000001}
+    abs := func(lhs, rhs int) bool { return max(lhs, -lhs) < max(rhs, -rhs) }
+    for _, fs := range []*FlatSet[int] {InitOrderedFlatSet(randInt(0, 1000, 300)), InitOrderedFlatSet(skewed),
+                                        InitFlatSet(randInt(0, 1000, 300), lessInt),
+                                        InitFlatSet(randInt(0, 1000, 300), greaterInt), NewOrderedFlatSet[int](),
+                                        InitOrderedFlatSet([]int {5}), InitFlatSet(randInt(-1000, 1000, 300), abs)} {
+        for _, value := range append(randInt(-10, 1010, 1000), skewed...) {
+            index, found := InterpolationSearch(fs, value)
+            expectedIndex, expectedFound := fs.Search(value)
+            if index != expectedIndex || found != expectedFound {
+                t.Errorf("InterpolationSearch(%d) size(%d): expected(%d, %t), actual(%d, %t)", value, fs.Size(),
+                         expectedIndex, expectedFound, index, found)
+            }
+        }
+    }
+
+    fs := InitOrderedFlatSet([]float64 {0.5, 1.25, 2.0, 7.5})
+    if index, found := InterpolationSearch(fs, 2.0); index != 2 || !found {
+        t.Errorf("InterpolationSearch(2.0): expected(2, true), actual(%d, %t)", index, found)
+    }
+
+    // the first and last values are in ascending order but the other values are not
+    mixed := InitFlatSet([]int {-1, 2, -3, 4}, abs)
+    if index, found := InterpolationSearch(mixed, -3); index != 2 || !found {
+        t.Errorf("InterpolationSearch(-3): expected(2, true), actual(%d, %t)", index, found)
+    }
+}
+
+
+// Search a set of uniformly distributed values using a binary search.
+//
+func BenchmarkSearchUniform(b *testing.B) {
+    for i := 0; i < b.N; i++ {
+        for _, value := range bmBatchProbes {
+            bmInit.Search(value)
+        }
+    }
+}
+
+
+// Search a set of uniformly distributed values using an interpolation search.
+//
+func BenchmarkInterpolationSearchUniform(b *testing.B) {
+    for i := 0; i < b.N; i++ {
+        for _, value := range bmBatchProbes {
+            InterpolationSearch(bmOrdered, value)
+        }
+    }
+}
@@ -0,0 +1,125 @@
+package flatset
+
+
+import (
+    "iter"
+)
+
+// This is the interface for the comparison function that is passed to the join functions, which compares values of two
+// different types that are sorted in the same order. It returns a negative number if a is less than b, a positive
+// number if a is greater than b, or zero if they are equivalent.
+//
+type JoinCompare[A, B any] func(a A, b B) int
+
+
+// Returns an iterator that yields each pair of equivalent values from two sorted iterators, for example the All or Keys
+// iterators of two containers. Both iterators are walked once in order without building any intermediate containers.
+// If an iterator contains equivalent values, each value is paired with at most one value from the other iterator.
+//
+func Join[A, B any](lhs iter.Seq[A], rhs iter.Seq[B], cmp JoinCompare[A, B]) iter.Seq2[A, B] {
+    return func(yield func(A, B) bool) {
+        next, stop := iter.Pull(rhs)
+        defer stop()
+
+        b, ok := next()
+        for a := range lhs {
+            for ok && cmp(a, b) > 0 {
+                b, ok = next()
+            }
+            if !ok {
+                return
+            }
+            if cmp(a, b) == 0 {
+                if !yield(a, b) {
+                    return
+                }
+                b, ok = next()
+            }
+        }
+    }
+}
+
+
+// Returns an iterator that yields the values from the sorted lhs iterator that have no equivalent value in the sorted
+// rhs iterator. Both iterators are walked once in order without building any intermediate containers.
+//
+func JoinLeftOnly[A, B any](lhs iter.Seq[A], rhs iter.Seq[B], cmp JoinCompare[A, B]) iter.Seq[A] {
+    return func(yield func(A) bool) {
+        next, stop := iter.Pull(rhs)
+        defer stop()
+
+        b, ok := next()
+        for a := range lhs {
+            for ok && cmp(a, b) > 0 {
+                b, ok = next()
+            }
+            if ok && cmp(a, b) == 0 {
+                b, ok = next()
+            } else if !yield(a) {
+                return
+            }
+        }
+    }
+}
+
+
+// Returns an iterator that yields the values from the sorted rhs iterator that have no equivalent value in the sorted
+// lhs iterator. Both iterators are walked once in order without building any intermediate containers.
+//
+func JoinRightOnly[A, B any](lhs iter.Seq[A], rhs iter.Seq[B], cmp JoinCompare[A, B]) iter.Seq[B] {
+    return func(yield func(B) bool) {
+        next, stop := iter.Pull(lhs)
+        defer stop()
+
+        a, ok := next()
+        for b := range rhs {
+            for ok && cmp(a, b) < 0 {
+                a, ok = next()
+            }
+            if ok && cmp(a, b) == 0 {
+                a, ok = next()
+            } else if !yield(b) {
+                return
+            }
+        }
+    }
+}
+
+
+// Private function that returns a new FlatMap containing the entries whose keys are contained, or not contained, within
+// the FlatSet by walking both containers once.
+//
+func filterByKeys[K, V any](keys *FlatSet[K], entries *FlatMap[K, V], contained bool, capacity int) *FlatMap[K, V] {
+    if !sameCompare(keys.cmp, entries.less) && !sortedWith(keys.data, entries.less, true) {
+        keys = InitFlatSet[K](keys.data, entries.less)
+    }
+    out := &FlatMap[K, V]{makeMapBase[K, V](entries.less)}
+    out.entries.data = make([]Entry[K, V], 0, capacity)
+
+    i, size := 0, len(keys.data)
+    for _, entry := range entries.entries.data {
+        for i < size && entries.less(keys.data[i], entry.Key) {
+            i++
+        }
+        if (i < size && !entries.less(entry.Key, keys.data[i])) == contained {
+            out.entries.data = append(out.entries.data, entry)
+        }
+    }
+    return out
+}
+
+
+// Returns a new FlatMap containing a copy of the entries whose keys are contained within this FlatSet. Both containers
+// are walked once in order.
+//
+func SemiJoin[K, V any](keys *FlatSet[K], entries *FlatMap[K, V]) *FlatMap[K, V] {
+    return filterByKeys(keys, entries, true, min(len(keys.data), len(entries.entries.data)))
+}
+
+
+// Returns a new FlatMap containing a copy of the entries whose keys are not contained within this FlatSet. Both
+// containers are walked once in order.
+//
+func AntiJoin[K, V any](keys *FlatSet[K], entries *FlatMap[K, V]) *FlatMap[K, V] {
+    return filterByKeys(keys, entries, false, len(entries.entries.data))
+}
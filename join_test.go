@@ -0,0 +1,63 @@
+package flatset
+
+import (
+    "slices"
+    "strconv"
+    "testing"
+)
+
+
+func compareIntString(lhs int, rhs string) int {
+    value, _ := strconv.Atoi(rhs)
+    return lhs - value
+}
+
+
+// Test the Join/JoinLeftOnly/JoinRightOnly functions walk a FlatSet and the keys of a FlatMap.
+//
+func TestJoin(t *testing.T) {
+    fs := InitFlatSet[int]([]int {1, 3, 4, 6}, lessInt)
+    fm := NewFlatMap[string, bool](func(lhs, rhs string) bool {
+        return compareIntString(0, lhs) > compareIntString(0, rhs)
+    })
+    for _, key := range []string {"2", "3", "6", "7"} {
+        fm.Set(key, true)
+    }
+
+    expectedLhs, expectedRhs := []int {3, 6}, []string {"3", "6"}
+    i := 0
+    for lhs, rhs := range Join(fs.All(), fm.Keys(), compareIntString) {
+        if lhs != expectedLhs[i] || rhs != expectedRhs[i] {
+            t.Errorf("Join(): expected(%d, %q), actual(%d, %q)", expectedLhs[i], expectedRhs[i], lhs, rhs)
+        }
+        i++
+    }
+    if i != len(expectedLhs) {
+        t.Errorf("Join(): expected(%d) pairs, actual(%d)", len(expectedLhs), i)
+    }
+
+    leftOnly := slices.Collect(JoinLeftOnly(fs.All(), fm.Keys(), compareIntString))
+    if !slices.Equal(leftOnly, []int {1, 4}) {
+        t.Errorf("JoinLeftOnly(): expected([1 4]), actual(%v)", leftOnly)
+    }
+
+    rightOnly := slices.Collect(JoinRightOnly(fs.All(), fm.Keys(), compareIntString))
+    if !slices.Equal(rightOnly, []string {"2", "7"}) {
+        t.Errorf("JoinRightOnly(): expected([2 7]), actual(%v)", rightOnly)
+    }
+}
+
+
+// Test the SemiJoin/AntiJoin functions filter the entries of a FlatMap by the keys in a FlatSet.
+//
+func TestSemiAntiJoin(t *testing.T) {
+    ids := InitFlatSet[int]([]int {5, 1, 3, 8}, greaterInt)
+    details := FromMap(map[int]string {1: "a", 2: "b", 3: "c", 4: "d"}, lessInt)
+
+    if actual := slices.Collect(SemiJoin(ids, details).Values()); !slices.Equal(actual, []string {"a", "c"}) {
+        t.Errorf("SemiJoin(): expected([a c]), actual(%v)", actual)
+    }
+    if actual := slices.Collect(AntiJoin(ids, details).Values()); !slices.Equal(actual, []string {"b", "d"}) {
+        t.Errorf("AntiJoin(): expected([b d]), actual(%v)", actual)
+    }
+}
@@ -0,0 +1,221 @@
+package flatset
+
+
+import (
+    "cmp"
+    "fmt"
+    "reflect"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "unsafe"
+)
+
+// An Order compares two values by a single field, returning a negative number if a is ordered before b, a positive
+// number if a is ordered after b, or zero if the field does not decide the order. Orders are composed by OrderBy.
+//
+type Order[V any] func(a, b V) int
+
+
+// Returns an Order that sorts the values by the key returned by this projection in ascending order.
+//
+func Asc[V any, K cmp.Ordered](key func(V) K) Order[V] {
+    return func(a, b V) int {
+        return cmp.Compare(key(a), key(b))
+    }
+}
+
+
+// Returns an Order that sorts the values by the key returned by this projection in descending order.
+//
+func Desc[V any, K cmp.Ordered](key func(V) K) Order[V] {
+    return func(a, b V) int {
+        return cmp.Compare(key(b), key(a))
+    }
+}
+
+
+// Returns a comparison function that sorts the values by each of these orders in turn, so that the next order is only
+// used when the values are equivalent using all of the orders before it. For example, OrderBy(Desc(age), Asc(name))
+// sorts people from the oldest to the youngest and then alphabetically.
+//
+func OrderBy[V any](orders ...Order[V]) Compare[V] {
+    return func(a, b V) bool {
+        for _, order := range orders {
+            if c := order(a, b); c != 0 {
+                return c < 0
+            }
+        }
+        return false
+    }
+}
+
+
+// Private structure for a field of a structure that is compared by a derived comparison function.
+//
+type derivedField struct {
+    offset uintptr      // offset of the field in the structure
+    kind reflect.Kind   // kind of the field
+    desc bool           // true if the field is sorted in descending order
+}
+
+// The fields that are compared for each type of structure, which are cached so that each type is only parsed once.
+//
+var derivedFields sync.Map
+
+
+// Private function that returns the fields of this type of structure that have a flatset tag, sorted by their order.
+//
+func parseDerivedFields(t reflect.Type) ([]derivedField, error) {
+    if cached, ok := derivedFields.Load(t); ok {
+        return cached.([]derivedField), nil
+    }
+    type tagged struct {
+        order int
+        field derivedField
+    }
+    var fields []tagged
+    for i := range t.NumField() {
+        sf := t.Field(i)
+        tag, ok := sf.Tag.Lookup("flatset")
+        if !ok {
+            continue
+        }
+        field := tagged{field: derivedField{offset: sf.Offset, kind: sf.Type.Kind()}}
+        found := false
+        for _, option := range strings.Split(tag, ",") {
+            switch option = strings.TrimSpace(option); {
+            case strings.HasPrefix(option, "order="):
+                order, err := strconv.Atoi(option[len("order="):])
+                if err != nil {
+                    return nil, fmt.Errorf("flatset: invalid order of field %s: %w", sf.Name, err)
+                }
+                field.order, found = order, true
+            case option == "desc":
+                field.field.desc = true
+            case option == "asc":
+                field.field.desc = false
+            default:
+                return nil, fmt.Errorf("flatset: invalid option %q of field %s", option, sf.Name)
+            }
+        }
+        switch field.field.kind {
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+             reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64,
+             reflect.String, reflect.Bool:
+        default:
+            return nil, fmt.Errorf("flatset: field %s of kind %s can not be compared", sf.Name, field.field.kind)
+        }
+        if !found {
+            return nil, fmt.Errorf("flatset: field %s does not have an order", sf.Name)
+        }
+        fields = append(fields, field)
+    }
+    if len(fields) == 0 {
+        return nil, fmt.Errorf("flatset: %s does not have any fields with a flatset tag", t)
+    }
+    slices.SortStableFunc(fields, func(a, b tagged) int { return cmp.Compare(a.order, b.order) })
+
+    out := make([]derivedField, len(fields))
+    for i, field := range fields {
+        if i > 0 && field.order == fields[i - 1].order {
+            return nil, fmt.Errorf("flatset: order %d is used by more than one field of %s", field.order, t)
+        }
+        out[i] = field.field
+    }
+    derivedFields.Store(t, out)
+    return out, nil
+}
+
+
+// Private function that compares the values of a field at this offset from the start of two structures.
+//
+func compareField[K cmp.Ordered](a, b unsafe.Pointer, offset uintptr) int {
+    return cmp.Compare(*(*K)(unsafe.Add(a, offset)), *(*K)(unsafe.Add(b, offset)))
+}
+
+
+// Private method that compares this field of two structures.
+//
+func (self *derivedField) compare(a, b unsafe.Pointer) int {
+    var c int
+    switch self.kind {
+    case reflect.Int:
+        c = compareField[int](a, b, self.offset)
+    case reflect.Int8:
+        c = compareField[int8](a, b, self.offset)
+    case reflect.Int16:
+        c = compareField[int16](a, b, self.offset)
+    case reflect.Int32:
+        c = compareField[int32](a, b, self.offset)
+    case reflect.Int64:
+        c = compareField[int64](a, b, self.offset)
+    case reflect.Uint:
+        c = compareField[uint](a, b, self.offset)
+    case reflect.Uint8:
+        c = compareField[uint8](a, b, self.offset)
+    case reflect.Uint16:
+        c = compareField[uint16](a, b, self.offset)
+    case reflect.Uint32:
+        c = compareField[uint32](a, b, self.offset)
+    case reflect.Uint64:
+        c = compareField[uint64](a, b, self.offset)
+    case reflect.Uintptr:
+        c = compareField[uintptr](a, b, self.offset)
+    case reflect.Float32:
+        c = compareField[float32](a, b, self.offset)
+    case reflect.Float64:
+        c = compareField[float64](a, b, self.offset)
+    case reflect.String:
+        c = compareField[string](a, b, self.offset)
+    case reflect.Bool:
+        lhs, rhs := *(*bool)(unsafe.Add(a, self.offset)), *(*bool)(unsafe.Add(b, self.offset))
+        if lhs != rhs {
+            c = 1
+            if !lhs {
+                c = -1
+            }
+        }
+    }
+    if self.desc {
+        return -c
+    }
+    return c
+}
+
+
+// Returns a comparison function for a structure, or a pointer to a structure, that is derived from the flatset tags of
+// its fields. Each field that is compared has a tag such as `flatset:"order=1,desc"`, and the fields are compared in
+// the order of their tags, in ascending order unless the tag has the desc option. The fields can be integers, floats,
+// strings or booleans, where false is ordered before true. The tags are parsed once for each type, and the fields are
+// read directly from their offsets in the structure so the comparisons do not use reflection. Pointers to structures
+// must not be nil. An error is returned if the tags are not valid.
+//
+func DeriveCompare[V any]() (Compare[V], error) {
+    t := reflect.TypeFor[V]()
+    pointer := t.Kind() == reflect.Pointer
+    if pointer {
+        t = t.Elem()
+    }
+    if t.Kind() != reflect.Struct {
+        return nil, fmt.Errorf("flatset: can not derive a comparison function for %s", reflect.TypeFor[V]())
+    }
+    fields, err := parseDerivedFields(t)
+    if err != nil {
+        return nil, err
+    }
+
+    return func(a, b V) bool {
+        pa, pb := unsafe.Pointer(&a), unsafe.Pointer(&b)
+        if pointer {
+            pa, pb = *(*unsafe.Pointer)(pa), *(*unsafe.Pointer)(pb)
+        }
+        for i := range fields {
+            if c := fields[i].compare(pa, pb); c != 0 {
+                return c < 0
+            }
+        }
+        return false
+    }, nil
+}
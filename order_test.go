@@ -0,0 +1,148 @@
+package flatset
+
+import (
+    "math/rand"
+    "slices"
+    "testing"
+)
+
+
+// Test a comparison function composed by OrderBy matches a hand written comparison function.
+//
+func TestOrderBy(t *testing.T) {
+    age := func(p *person) int { return p.age }
+    name := func(p *person) string { return p.name }
+    cmp := OrderBy(Desc(age), Asc(name))
+
+    names := []string {"Bill", "Brian", "Charlie", "Keith", "Mick"}
+    people := make([]*person, 0, 50)
+    for range 50 {
+        people = append(people, &person{age: 75 + rand.Intn(5), name: names[rand.Intn(len(names))]})
+    }
+    for _, lhs := range people {
+        for _, rhs := range people {
+            if cmp(lhs, rhs) != comparePeople(lhs, rhs) {
+                t.Errorf("OrderBy()(%v, %v): expected(%t), actual(%t)", *lhs, *rhs, comparePeople(lhs, rhs),
+                         cmp(lhs, rhs))
+            }
+        }
+    }
+
+    fs := InitFlatSet([]int {3, 1, 2}, OrderBy(Desc(func(value int) int { return value })))
+    if !slices.Equal(fs.data, []int {3, 2, 1}) {
+        t.Errorf("OrderBy(Desc()): expected([3 2 1]), actual(%v)", fs.data)
+    }
+    if OrderBy[int]()(1, 2) {
+        t.Errorf("OrderBy()(1, 2): expected(false), actual(true)")
+    }
+}
+
+
+
+// Test containers sorted by different OrderBy comparison functions are merged in the order of the receiver, as the
+// closures returned by OrderBy share the same code.
+//
+func TestOrderByMerge(t *testing.T) {
+    age := func(p person) int { return p.age }
+    people := []person {{2, "b"}, {3, "c"}, {1, "a"}}
+    fs := InitFlatSet(people[:1], OrderBy(Asc(age)))
+    fs.Merge(InitFlatSet(people[1:], OrderBy(Desc(age))))
+    if expected := []person {{1, "a"}, {2, "b"}, {3, "c"}}; !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.Merge(Desc): expected(%v), actual(%v)", expected, fs.data)
+    }
+
+    ms := InitFlatMultiSet(people[:1], OrderBy(Asc(age)))
+    ms.Merge(InitFlatMultiSet(people, OrderBy(Desc(age))))
+    if expected := []person {{1, "a"}, {2, "b"}, {2, "b"}, {3, "c"}}; !slices.Equal(ms.data, expected) {
+        t.Errorf("FlatMultiSet.Merge(Desc): expected(%v), actual(%v)", expected, ms.data)
+    }
+
+    key := func(value int) int { return value }
+    fm := MakeFlatMap[int, string](OrderBy(Asc(key)))
+    fm.Set(2, "b")
+    other := NewFlatMap[int, string](OrderBy(Desc(key)))
+    other.Set(1, "a")
+    other.Set(3, "c")
+    fm.MergeWith(other, func(old, new string) string { return old + new })
+    if expected := []int {1, 2, 3}; !slices.Equal(slices.Collect(fm.Keys()), expected) {
+        t.Errorf("FlatMap.MergeWith(Desc): expected(%v), actual(%v)", expected, slices.Collect(fm.Keys()))
+    }
+}
+
+
+type taggedPerson struct {
+    Name string `flatset:"order=2"`
+    Age int     `flatset:"order=1,desc"`
+    note string
+}
+
+
+// Test a comparison function derived from struct tags matches a hand written comparison function.
+//
+func TestDeriveCompare(t *testing.T) {
+    byValue, err := DeriveCompare[taggedPerson]()
+    if err != nil {
+        t.Fatalf("DeriveCompare() failed: %v", err)
+    }
+    byPointer, err := DeriveCompare[*taggedPerson]()
+    if err != nil {
+        t.Fatalf("DeriveCompare() failed: %v", err)
+    }
+
+    names := []string {"Bill", "Brian", "Charlie", "Keith", "Mick"}
+    people := make([]taggedPerson, 0, 50)
+    for range 50 {
+        people = append(people, taggedPerson{Age: 75 + rand.Intn(5), Name: names[rand.Intn(len(names))], note: "x"})
+    }
+    for _, lhs := range people {
+        for _, rhs := range people {
+            expected := comparePeople(&person{lhs.Age, lhs.Name}, &person{rhs.Age, rhs.Name})
+            if byValue(lhs, rhs) != expected || byPointer(&lhs, &rhs) != expected {
+                t.Errorf("DeriveCompare()(%v, %v): expected(%t), actual(%t, %t)", lhs, rhs, expected,
+                         byValue(lhs, rhs), byPointer(&lhs, &rhs))
+            }
+        }
+    }
+
+    type flags struct {
+        On bool       `flatset:"order=1"`
+        Score float64 `flatset:"order=2,desc"`
+    }
+    fs := InitFlatSet([]flags {{true, 1}, {false, 1}, {false, 2}}, mustCompare(DeriveCompare[flags]()))
+    if expected := []flags {{false, 2}, {false, 1}, {true, 1}}; !slices.Equal(fs.data, expected) {
+        t.Errorf("DeriveCompare(): expected(%v), actual(%v)", expected, fs.data)
+    }
+
+    type invalid struct {
+        A int `flatset:"order=1"`
+        B int `flatset:"order=1"`
+    }
+    type unsupported struct {
+        A []int `flatset:"order=1"`
+    }
+    type unordered struct {
+        A int `flatset:"desc"`
+    }
+    for name, err := range map[string]error {
+        "invalid": second(DeriveCompare[invalid]()),
+        "unsupported": second(DeriveCompare[unsupported]()),
+        "unordered": second(DeriveCompare[unordered]()),
+        "int": second(DeriveCompare[int]()),
+        "untagged": second(DeriveCompare[person]()),
+    } {
+        if err == nil {
+            t.Errorf("DeriveCompare[%s](): expected an error", name)
+        }
+    }
+}
+
+
+func mustCompare[V any](cmp Compare[V], err error) Compare[V] {
+    if err != nil {
+        panic(err)
+    }
+    return cmp
+}
+
+
+func second[A, B any](_ A, b B) B { return b }
@@ -0,0 +1,162 @@
+package flatset
+
+
+import (
+    "errors"
+    "iter"
+    "sort"
+)
+
+// A DuplicatePolicy decides which value is kept when a value is inserted into a FlatSet that already has an equivalent
+// value. It returns the value to keep, which must be equivalent to both values so that the order is maintained, or an
+// error to reject the incoming value.
+//
+type DuplicatePolicy[V any] func(existing, incoming V) (V, error)
+
+// The error returned by the RejectDuplicates policy.
+//
+var ErrDuplicate = errors.New("flatset: duplicate value")
+
+
+// A DuplicatePolicy that keeps the existing value, which is the default behaviour of a FlatSet.
+//
+func KeepFirst[V any](existing, incoming V) (V, error) {
+    return existing, nil
+}
+
+
+// A DuplicatePolicy that replaces the existing value with the incoming value, so that the latest value wins.
+//
+func KeepLast[V any](existing, incoming V) (V, error) {
+    return incoming, nil
+}
+
+
+// A DuplicatePolicy that rejects the incoming value with ErrDuplicate.
+//
+func RejectDuplicates[V any](existing, incoming V) (V, error) {
+    return existing, ErrDuplicate
+}
+
+
+// Returns a DuplicatePolicy that keeps the result of combining the existing and incoming values with this function.
+//
+func Combine[V any](combine func(existing, incoming V) V) DuplicatePolicy[V] {
+    return func(existing, incoming V) (V, error) {
+        return combine(existing, incoming), nil
+    }
+}
+
+
+// Returns a DuplicatePolicy that keeps the existing value like KeepFirst, but first calls this function with the kept
+// and discarded values. This allows loaders to log or count the conflicting records of dirty input that would
+// otherwise be dropped silently by InitFlatSet, Update or Merge.
+//
+func ReportDuplicates[V any](report func(kept, discarded V)) DuplicatePolicy[V] {
+    return func(existing, incoming V) (V, error) {
+        report(existing, incoming)
+        return existing, nil
+    }
+}
+
+
+// Private method that resolves the repeated values using the policy, where the values that are ordered first are the
+// existing values. If there is an equality function only equal values are resolved. If the policy returns an error the
+// array is left partially resolved.
+//
+func (self *FlatSet[V]) resolveDuplicates(policy DuplicatePolicy[V]) error {
+    size := len(self.data)
+    if size < 2 {
+        return nil
+    }
+    upto, run := 1, 0
+    for next := 1; next < size; next++ {
+        value := self.data[next]
+        if self.cmp(self.data[upto - 1], value) {
+            run = upto
+        } else {
+            existing := upto - 1
+            if self.eq != nil {
+                for existing = run; existing < upto && !self.eq(self.data[existing], value); existing++ {}
+            }
+            if existing < upto {
+                var err error
+                if self.data[existing], err = policy(self.data[existing], value); err != nil {
+                    return err
+                }
+                continue
+            }
+        }
+        self.data[upto] = value
+        upto++
+    }
+    clear(self.data[upto:size])
+    self.data = self.data[:upto]
+    return nil
+}
+
+
+// Private method that merges these sorted values into a copy of this container and resolves the repeated values using
+// the policy. The copy replaces the values of this container unless the policy returns an error.
+//
+func (self *FlatSet[V]) mergePolicy(values []V, policy DuplicatePolicy[V]) error {
+    out := FlatSet[V]{base[V]{cmp: self.cmp, eq: self.eq}}
+    out.data = append(make([]V, 0, len(self.data) + len(values)), self.data...)
+    out.mergeSorted(&base[V]{cmp: self.cmp, data: values})
+    if err := out.resolveDuplicates(policy); err != nil {
+        return err
+    }
+    capacity := cap(self.data)
+    self.data = out.data
+    self.shared = false
+    self.grown(capacity)
+    self.check(0)
+    return nil
+}
+
+
+// Create a new FlatSet and initialize it with some values, using the policy to decide which value is kept when values
+// are repeated. Values earlier in the array are the existing values. If the policy returns an error it is returned
+// without a FlatSet.
+//
+func InitFlatSetWith[V any](values []V, cmp Compare[V], policy DuplicatePolicy[V]) (*FlatSet[V], error) {
+    defer traceOperation("flatset.InitFlatSet", len(values))()
+    self := &FlatSet[V]{base[V]{cmp: cmp}}
+    self.data = append([]V(nil), values...)
+    sort.SliceStable(self.data, func(lhs, rhs int) bool {return self.cmp(self.data[lhs], self.data[rhs])})
+    if err := self.resolveDuplicates(policy); err != nil {
+        return nil, err
+    }
+    return self, nil
+}
+
+
+// Insert these values into this container like Update, using the policy to decide which value is kept when a value is
+// already contained in this container or is repeated in the values. If the policy returns an error this container is
+// unchanged and the error is returned. This method updates this container so it will invalidate any previous indices.
+//
+func (self *FlatSet[V]) UpdateWith(values iter.Seq[V], policy DuplicatePolicy[V]) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.FlatSet.Update", len(self.data))()
+    buffer, sorted := self.collect(values)
+    if !sorted {
+        sort.SliceStable(buffer, func(lhs, rhs int) bool {return self.cmp(buffer[lhs], buffer[rhs])})
+    }
+    return self.mergePolicy(buffer, policy)
+}
+
+
+// Append another FlatSet into this one like Merge, using the policy to decide which value is kept when a value is
+// contained in both FlatSets. If the policy returns an error this container is unchanged and the error is returned.
+// This method updates this container so it will invalidate any previous indices.
+//
+func (self *FlatSet[V]) MergeWith(other *FlatSet[V], policy DuplicatePolicy[V]) error {
+    if debug {
+        defer self.guard.write()()
+    }
+    defer traceOperation("flatset.FlatSet.Merge", len(self.data) + len(other.data))()
+    other = self.sameOrder(other)
+    return self.mergePolicy(other.data, policy)
+}
@@ -0,0 +1,89 @@
+package flatset
+
+import (
+    "errors"
+    "slices"
+    "testing"
+)
+
+
+type version struct {
+    key int
+    rev int
+}
+
+
+func lessVersion(lhs, rhs version) bool { return lhs.key < rhs.key }
+
+
+// Test the duplicate policies decide which value is kept by InitFlatSetWith, UpdateWith and MergeWith.
+//
+func TestDuplicatePolicy(t *testing.T) {
+    values := []version {{2, 1}, {1, 1}, {2, 2}, {1, 2}, {3, 1}}
+
+    fs, err := InitFlatSetWith(values, lessVersion, KeepLast[version])
+    if expected := []version {{1, 2}, {2, 2}, {3, 1}}; err != nil || !slices.Equal(fs.data, expected) {
+        t.Errorf("InitFlatSetWith(KeepLast): expected(%v), actual(%v, %v)", expected, fs.data, err)
+    }
+    fs, err = InitFlatSetWith(values, lessVersion, KeepFirst[version])
+    if expected := []version {{1, 1}, {2, 1}, {3, 1}}; err != nil || !slices.Equal(fs.data, expected) {
+        t.Errorf("InitFlatSetWith(KeepFirst): expected(%v), actual(%v, %v)", expected, fs.data, err)
+    }
+    if _, err := InitFlatSetWith(values, lessVersion, RejectDuplicates[version]); !errors.Is(err, ErrDuplicate) {
+        t.Errorf("InitFlatSetWith(RejectDuplicates): expected(%v), actual(%v)", ErrDuplicate, err)
+    }
+
+    sum := Combine(func(existing, incoming version) version { return version{existing.key, existing.rev + incoming.rev} })
+    if err := fs.UpdateWith(slices.Values([]version {{3, 5}, {0, 1}, {3, 10}}), sum); err != nil {
+        t.Errorf("FlatSet.UpdateWith(Combine) failed: %v", err)
+    }
+    if expected := []version {{0, 1}, {1, 1}, {2, 1}, {3, 16}}; !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.UpdateWith(Combine): expected(%v), actual(%v)", expected, fs.data)
+    }
+
+    before := slices.Clone(fs.data)
+    other := InitFlatSet([]version {{4, 1}, {1, 7}}, func(lhs, rhs version) bool { return lhs.key > rhs.key })
+    if err := fs.MergeWith(other, RejectDuplicates[version]); !errors.Is(err, ErrDuplicate) {
+        t.Errorf("FlatSet.MergeWith(RejectDuplicates): expected(%v), actual(%v)", ErrDuplicate, err)
+    }
+    if !slices.Equal(fs.data, before) {
+        t.Errorf("FlatSet.MergeWith(RejectDuplicates): expected(%v), actual(%v)", before, fs.data)
+    }
+    if err := fs.MergeWith(other, KeepLast[version]); err != nil {
+        t.Errorf("FlatSet.MergeWith(KeepLast) failed: %v", err)
+    }
+    if expected := []version {{0, 1}, {1, 7}, {2, 1}, {3, 16}, {4, 1}}; !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.MergeWith(KeepLast): expected(%v), actual(%v)", expected, fs.data)
+    }
+}
+
+
+// Test the ReportDuplicates policy keeps the first value and reports each discarded value.
+//
+func TestReportDuplicates(t *testing.T) {
+    var discarded []version
+    report := ReportDuplicates(func(kept, dropped version) {
+        if kept.key != dropped.key {
+            t.Errorf("ReportDuplicates(%v, %v): expected(equivalent values)", kept, dropped)
+        }
+        discarded = append(discarded, dropped)
+    })
+    fs, err := InitFlatSetWith([]version {{2, 1}, {1, 1}, {2, 2}, {1, 2}, {2, 3}}, lessVersion, report)
+    if expected := []version {{1, 1}, {2, 1}}; err != nil || !slices.Equal(fs.data, expected) {
+        t.Errorf("InitFlatSetWith(ReportDuplicates): expected(%v), actual(%v, %v)", expected, fs.data, err)
+    }
+    if expected := []version {{1, 2}, {2, 2}, {2, 3}}; !slices.Equal(discarded, expected) {
+        t.Errorf("InitFlatSetWith(ReportDuplicates): expected(%v), actual(%v)", expected, discarded)
+    }
+
+    discarded = nil
+    if err := fs.MergeWith(InitFlatSet([]version {{3, 1}, {1, 9}}, lessVersion), report); err != nil {
+        t.Errorf("FlatSet.MergeWith(ReportDuplicates) failed: %v", err)
+    }
+    if expected := []version {{1, 1}, {2, 1}, {3, 1}}; !slices.Equal(fs.data, expected) {
+        t.Errorf("FlatSet.MergeWith(ReportDuplicates): expected(%v), actual(%v)", expected, fs.data)
+    }
+    if expected := []version {{1, 9}}; !slices.Equal(discarded, expected) {
+        t.Errorf("FlatSet.MergeWith(ReportDuplicates): expected(%v), actual(%v)", expected, discarded)
+    }
+}
@@ -0,0 +1,165 @@
+package flatset
+
+
+import (
+    "encoding/binary"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "iter"
+    "sort"
+)
+
+// A SavedFlatSet searches the values written by Save without loading them, using the sparse index written after the
+// blocks to read only the blocks that can contain the values that are searched for. It can be used for very large
+// sets that are rarely searched, or to search a file before deciding whether to load it.
+//
+type SavedFlatSet[V any] struct {
+    r io.ReaderAt       // reader of the serialized data
+    cmp Compare[V]      // comparison function that the values are sorted by
+    template decoder[V] // decoder copied to read each block
+    size uint64         // number of values
+    first []V           // first value of each block
+    offsets []int64     // offset of each block
+    ranks []uint64      // number of values before each block
+}
+
+
+// Open the values written by Save to the reader with this size in bytes, which must be sorted using the comparison
+// function. Only the header and the index are read. If the values were written compressed the compression must be
+// passed. An error is returned if the data does not have an index or it is not valid.
+//
+func OpenSaved[V any](r io.ReaderAt, size int64, codec Codec[V], cmp Compare[V],
+                      compression ...Compression) (*SavedFlatSet[V], error) {
+    dec, err := newDecoder(io.NewSectionReader(r, 0, size), codec, nil, optionalCompression(compression))
+    if err != nil {
+        return nil, err
+    } else if dec.flags & flagIndex == 0 {
+        return nil, fmt.Errorf("%w: the values do not have an index", ErrInvalidFormat)
+    } else if size < headerSize + trailerSize {
+        return nil, io.ErrUnexpectedEOF
+    }
+
+    var trailer [trailerSize]byte
+    if _, err := r.ReadAt(trailer[:], size - trailerSize); err != nil {
+        return nil, unexpectedEOF(err)
+    }
+    indexOffset := int64(binary.LittleEndian.Uint64(trailer[:]))
+    if string(trailer[8:]) != indexMagic || indexOffset < headerSize ||
+       indexOffset > size - trailerSize - indexHeaderSize - checksumSize {
+        return nil, fmt.Errorf("%w: invalid index trailer", ErrInvalidFormat)
+    }
+    index := make([]byte, size - trailerSize - indexOffset)
+    if _, err := r.ReadAt(index, indexOffset); err != nil {
+        return nil, unexpectedEOF(err)
+    }
+    checksum := binary.LittleEndian.Uint32(index[len(index) - checksumSize:])
+    index = index[:len(index) - checksumSize]
+    if crc32.Checksum(index, castagnoli) != checksum {
+        return nil, fmt.Errorf("%w in the index", ErrChecksum)
+    }
+
+    blocks := int(binary.LittleEndian.Uint32(index))
+    self := &SavedFlatSet[V]{r: r, cmp: cmp, template: *dec, size: binary.LittleEndian.Uint64(index[4:])}
+    for entries := index[indexHeaderSize:]; len(entries) > 0; {
+        if len(entries) < 16 {
+            return nil, fmt.Errorf("%w: invalid index entry", ErrInvalidFormat)
+        }
+        offset, rank := binary.LittleEndian.Uint64(entries), binary.LittleEndian.Uint64(entries[8:])
+        value, n, err := dec.decode(entries[16:])
+        if err != nil {
+            return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+        }
+        self.offsets = append(self.offsets, int64(offset))
+        self.ranks = append(self.ranks, rank)
+        self.first = append(self.first, value)
+        entries = entries[16 + n:]
+    }
+    if len(self.first) != blocks {
+        return nil, fmt.Errorf("%w: index of %d blocks, expected %d", ErrInvalidFormat, len(self.first), blocks)
+    }
+    return self, nil
+}
+
+
+// Private method that returns the index of the block that contains the lower bound of this value, which is the last
+// block with a first value less than the value.
+//
+func (self *SavedFlatSet[V]) block(value V) int {
+    return max(sort.Search(len(self.first), func(i int) bool { return !self.cmp(self.first[i], value) }) - 1, 0)
+}
+
+
+// Private method that returns the index of the block that contains a value equivalent to this value if there is one,
+// which is the last block with a first value that is not greater than the value.
+//
+func (self *SavedFlatSet[V]) search(value V) int {
+    return max(sort.Search(len(self.first), func(i int) bool { return self.cmp(value, self.first[i]) }) - 1, 0)
+}
+
+
+// Private method that returns a decoder for the values from the start of this block up to the end of the values.
+//
+func (self *SavedFlatSet[V]) decoder(block int) *decoder[V] {
+    dec := self.template
+    dec.r = io.NewSectionReader(self.r, self.offsets[block], 1 << 62)
+    dec.size = self.size - self.ranks[block]
+    dec.blocks = block
+    dec.buf, dec.decompressed = nil, nil
+    return &dec
+}
+
+
+// Returns the number of values.
+//
+func (self *SavedFlatSet[V]) Size() int {
+    return int(self.size)
+}
+
+
+// Returns true if there is a value equivalent to this value, reading a single block.
+//
+func (self *SavedFlatSet[V]) Contains(value V) (bool, error) {
+    if len(self.first) == 0 {
+        return false, nil
+    }
+    block := self.search(value)
+    dec := self.decoder(block)
+    if block + 1 < len(self.ranks) {
+        dec.size = self.ranks[block + 1] - self.ranks[block]
+    }
+    for {
+        next, ok, err := dec.next()
+        if err != nil || !ok {
+            return false, err
+        } else if !self.cmp(next, value) {
+            return !self.cmp(value, next), nil
+        }
+    }
+}
+
+
+// Returns an iterator over the values that are greater than or equivalent to the low value and less than the high
+// value, reading only the blocks that contain them. If an error occurs it is returned with the zero value as the last
+// pair of the iterator.
+//
+func (self *SavedFlatSet[V]) Range(low, high V) iter.Seq2[V, error] {
+    return func(yield func(V, error) bool) {
+        if len(self.first) == 0 || !self.cmp(low, high) {
+            return
+        }
+        dec := self.decoder(self.block(low))
+        for {
+            value, ok, err := dec.next()
+            if err != nil {
+                var zero V
+                yield(zero, err)
+                return
+            } else if !ok || !self.cmp(value, high) {
+                return
+            } else if !self.cmp(value, low) && !yield(value, nil) {
+                return
+            }
+        }
+    }
+}
@@ -0,0 +1,119 @@
+package flatset
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+)
+
+
+// A reader that counts the number of bytes that are read.
+//
+type countingReaderAt struct {
+    r io.ReaderAt
+    read int
+}
+
+
+func (self *countingReaderAt) ReadAt(buf []byte, offset int64) (int, error) {
+    n, err := self.r.ReadAt(buf, offset)
+    self.read += n
+    return n, err
+}
+
+
+// Test the SavedFlatSet searches the saved values using only the blocks that contain them.
+//
+func TestSavedFlatSet(t *testing.T) {
+    fs := InitFlatSet(randInt(0, 1000000, 100000), lessInt)
+    for _, compression := range [][]Compression {nil, {Gzip}} {
+        var buf bytes.Buffer
+        var err error
+        if compression == nil {
+            err = fs.Save(&buf, IntegerCodec[int]{})
+        } else {
+            err = fs.SaveCompressed(&buf, IntegerCodec[int]{}, Gzip)
+        }
+        if err != nil {
+            t.Fatalf("FlatSet.Save() failed: %v", err)
+        }
+
+        reader := &countingReaderAt{r: bytes.NewReader(buf.Bytes())}
+        saved, err := OpenSaved(reader, int64(buf.Len()), IntegerCodec[int]{}, lessInt, compression...)
+        if err != nil {
+            t.Fatalf("OpenSaved() failed: %v", err)
+        }
+        if saved.Size() != fs.Size() {
+            t.Errorf("SavedFlatSet.Size(): expected(%d), actual(%d)", fs.Size(), saved.Size())
+        }
+
+        for _, value := range append(randInt(-10, 1000010, 100), fs.At(0), fs.At(fs.Size() - 1)) {
+            reader.read = 0
+            found, err := saved.Contains(value)
+            if err != nil || found != fs.Contains(value) {
+                t.Errorf("SavedFlatSet.Contains(%d): expected(%t), actual(%t, %v)", value, fs.Contains(value), found,
+                         err)
+            }
+            if reader.read > blockBytes * 2 {
+                t.Errorf("SavedFlatSet.Contains(%d): expected to read one block, actual(%d) bytes", value, reader.read)
+            }
+        }
+
+        for _, bounds := range [][2]int {{-5, 10}, {250000, 260000}, {990000, 2000000}, {10, 5}} {
+            expected := fs.data[fs.LowerBound(bounds[0]):max(fs.LowerBound(bounds[1]), fs.LowerBound(bounds[0]))]
+            var actual []int
+            for value, err := range saved.Range(bounds[0], bounds[1]) {
+                if err != nil {
+                    t.Fatalf("SavedFlatSet.Range() failed: %v", err)
+                }
+                actual = append(actual, value)
+            }
+            if !slices.Equal(actual, expected) {
+                t.Errorf("SavedFlatSet.Range(%d, %d): expected(%d) values, actual(%d)", bounds[0], bounds[1],
+                         len(expected), len(actual))
+            }
+        }
+    }
+
+    legacy, err := os.ReadFile(filepath.Join("testdata", "int-nochecksum.golden"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, err = OpenSaved(bytes.NewReader(legacy), int64(len(legacy)), IntegerCodec[int]{}, lessInt)
+    if !errors.Is(err, ErrInvalidFormat) {
+        t.Errorf("OpenSaved(): expected(%v), actual(%v)", ErrInvalidFormat, err)
+    }
+}
+
+
+// Test the SavedFlatSet finds the first value of every block, which is also the first value in its index entry.
+//
+func TestSavedFirstOfBlock(t *testing.T) {
+    values := make([]int64, 30000)
+    for i := range values {
+        values[i] = int64(i)
+    }
+    fs := InitFlatSet(values, func(a, b int64) bool { return a < b })
+    var buf bytes.Buffer
+    if err := fs.Save(&buf, IntegerCodec[int64]{}); err != nil {
+        t.Fatalf("FlatSet.Save() failed: %v", err)
+    }
+    saved, err := OpenSaved(bytes.NewReader(buf.Bytes()), int64(buf.Len()), IntegerCodec[int64]{}, fs.cmp)
+    if err != nil {
+        t.Fatalf("OpenSaved() failed: %v", err)
+    }
+    if len(saved.first) < 3 {
+        t.Fatalf("OpenSaved(): expected(3 or more) blocks, actual(%d)", len(saved.first))
+    }
+    for _, first := range saved.first {
+        for _, value := range []int64 {first - 1, first, first + 1} {
+            if found, err := saved.Contains(value); err != nil || found != (value >= 0) {
+                t.Errorf("SavedFlatSet.Contains(%d): expected(%t), actual(%t, %v)", value, value >= 0, found, err)
+            }
+        }
+    }
+}
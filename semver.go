@@ -0,0 +1,151 @@
+package flatset
+
+
+import (
+    "errors"
+    "iter"
+    "strconv"
+    "strings"
+)
+
+// A Version is a semantic version of the form MAJOR.MINOR.PATCH with an optional pre-release, such as 1.2.3-rc.1.
+// Build metadata after a plus sign is ignored when a version is parsed, as it does not affect the precedence.
+//
+type Version struct {
+    Major, Minor, Patch uint64
+    Prerelease string
+}
+
+
+// Parse a semantic version, which may begin with a "v". An error is returned if the string is not a valid version.
+//
+func ParseVersion(s string) (Version, error) {
+    var out Version
+    text, _, _ := strings.Cut(strings.TrimPrefix(s, "v"), "+")
+    text, out.Prerelease, _ = strings.Cut(text, "-")
+    parts := strings.Split(text, ".")
+    if len(parts) != 3 {
+        return out, errors.New("flatset: invalid semantic version: " + s)
+    }
+    for i, field := range []*uint64 {&out.Major, &out.Minor, &out.Patch} {
+        value, err := strconv.ParseUint(parts[i], 10, 64)
+        if err != nil {
+            return out, errors.New("flatset: invalid semantic version: " + s)
+        }
+        *field = value
+    }
+    return out, nil
+}
+
+
+// Private function that compares the pre-releases of two versions with the same major, minor and patch numbers, and
+// returns true if the first has a lower precedence. A version without a pre-release has a higher precedence, and the
+// identifiers are compared in order with numeric identifiers compared numerically and lower than other identifiers.
+//
+func lessPrerelease(a, b string) bool {
+    if a == "" || b == "" {
+        return b == "" && a != ""
+    }
+    lhs, rhs := strings.Split(a, "."), strings.Split(b, ".")
+    for i := 0; i < len(lhs) && i < len(rhs); i++ {
+        if lhs[i] == rhs[i] {
+            continue
+        }
+        x, errX := strconv.ParseUint(lhs[i], 10, 64)
+        y, errY := strconv.ParseUint(rhs[i], 10, 64)
+        switch {
+        case errX == nil && errY == nil:
+            return x < y
+        case errX == nil || errY == nil:
+            return errX == nil
+        default:
+            return lhs[i] < rhs[i]
+        }
+    }
+    return len(lhs) < len(rhs)
+}
+
+
+// A comparison function that sorts versions in ascending order of their precedence.
+//
+func LessVersion(a, b Version) bool {
+    switch {
+    case a.Major != b.Major:
+        return a.Major < b.Major
+    case a.Minor != b.Minor:
+        return a.Minor < b.Minor
+    case a.Patch != b.Patch:
+        return a.Patch < b.Patch
+    default:
+        return lessPrerelease(a.Prerelease, b.Prerelease)
+    }
+}
+
+
+// Returns an iterator over the versions in a FlatSet sorted with LessVersion that satisfy a constraint, such as
+// ">=1.2.0 <2.0.0". The constraint is a list of comparisons separated by spaces that must all be satisfied, where each
+// comparison is one of the operators >=, >, <=, < or = followed by a version, and a version without an operator must
+// be equal. As the versions are sorted, the comparisons are combined into a single range that is found with two binary
+// searches each time the iteration starts. An error is returned if the constraint can not be parsed.
+//
+func AllSatisfying(self *FlatSet[Version], constraint string) (iter.Seq[Version], error) {
+    type comparison struct {
+        op string
+        version Version
+    }
+    var comparisons []comparison
+    for _, field := range strings.Fields(constraint) {
+        op := strings.TrimRight(field, "0123456789.-+vabcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+        version, err := ParseVersion(field[len(op):])
+        if err != nil {
+            return nil, err
+        }
+        switch op {
+        case ">=", ">", "<=", "<", "=", "":
+            comparisons = append(comparisons, comparison{op, version})
+        default:
+            return nil, errors.New("flatset: invalid version constraint: " + field)
+        }
+    }
+    return func(yield func(Version) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
+        from, upto := 0, self.Size()
+        for _, c := range comparisons {
+            switch c.op {
+            case ">=":
+                from = max(from, self.LowerBound(c.version))
+            case ">":
+                from = max(from, self.UpperBound(c.version))
+            case "<=":
+                upto = min(upto, self.UpperBound(c.version))
+            case "<":
+                upto = min(upto, self.LowerBound(c.version))
+            default:
+                from, upto = max(from, self.LowerBound(c.version)), min(upto, self.UpperBound(c.version))
+            }
+        }
+        for i := from; i < upto; i++ {
+            if !yield(self.data[i]) {
+                return
+            }
+            if debug {
+                self.guard.iterated(generation)
+            }
+        }
+    }, nil
+}
+
+
+// Returns the version in the form MAJOR.MINOR.PATCH followed by the pre-release if there is one.
+//
+func (self Version) String() string {
+    s := strconv.FormatUint(self.Major, 10) + "." + strconv.FormatUint(self.Minor, 10) + "." +
+         strconv.FormatUint(self.Patch, 10)
+    if self.Prerelease != "" {
+        s += "-" + self.Prerelease
+    }
+    return s
+}
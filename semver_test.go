@@ -0,0 +1,69 @@
+package flatset
+
+
+import (
+    "slices"
+    "testing"
+)
+
+// Test versions are sorted by their precedence and the versions satisfying a constraint are found.
+//
+func TestAllSatisfying(t *testing.T) {
+    ordered := []string {"0.9.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2",
+                         "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.2.0", "1.10.3", "2.0.0"}
+    var versions []Version
+    for _, s := range ordered {
+        version, err := ParseVersion("v" + s + "+build.5")
+        if err != nil || version.String() != s {
+            t.Errorf("ParseVersion(%s): expected(%s), actual(%s, %v)", s, s, version, err)
+        }
+        versions = append(versions, version)
+    }
+    for i := 1; i < len(versions); i++ {
+        if !LessVersion(versions[i - 1], versions[i]) || LessVersion(versions[i], versions[i - 1]) {
+            t.Errorf("LessVersion(%s, %s): expected(true), actual(false)", versions[i - 1], versions[i])
+        }
+    }
+    for _, invalid := range []string {"1.2", "1.x.0", "1.2.3.4"} {
+        if _, err := ParseVersion(invalid); err == nil {
+            t.Errorf("ParseVersion(%s): expected(error), actual(nil)", invalid)
+        }
+    }
+
+    fs := InitFlatSet(slices.Clone(versions), LessVersion)
+    for constraint, expected := range map[string][]string {
+        ">=1.0.0 <2.0.0": {"1.0.0", "1.2.0", "1.10.3"},
+        ">1.0.0-rc.1 <=1.2.0": {"1.0.0", "1.2.0"},
+        "=1.10.3": {"1.10.3"},
+        "1.2.0": {"1.2.0"},
+        ">2.0.0": nil,
+        ">=1.0.0-beta <1.0.0-rc.1": {"1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11"},
+        "": ordered,
+    } {
+        var actual []string
+        all, err := AllSatisfying(fs, constraint)
+        if err == nil {
+            for version := range all {
+                actual = append(actual, version.String())
+            }
+        }
+        if err != nil || !slices.Equal(actual, expected) {
+            t.Errorf("AllSatisfying(%q): expected(%v), actual(%v, %v)", constraint, expected, actual, err)
+        }
+    }
+    for _, invalid := range []string {"~1.2.0", ">=1.2", "!=1.0.0"} {
+        if _, err := AllSatisfying(fs, invalid); err == nil {
+            t.Errorf("AllSatisfying(%q): expected(error), actual(nil)", invalid)
+        }
+    }
+
+    all, _ := AllSatisfying(fs, ">=1.0.0 <2.0.0")
+    fs.Clear()
+    if actual := slices.Collect(all); len(actual) != 0 {
+        t.Errorf("AllSatisfying() after Clear: expected([]), actual(%v)", actual)
+    }
+    fs.Insert(Version{Major: 1, Minor: 5})
+    if actual := slices.Collect(all); len(actual) != 1 || actual[0].String() != "1.5.0" {
+        t.Errorf("AllSatisfying() after Insert: expected([1.5.0]), actual(%v)", actual)
+    }
+}
@@ -0,0 +1,47 @@
+package flatset
+
+
+import (
+    "iter"
+    "time"
+)
+
+// Returns the result of calling the function for each value of the iterator in order, passing the result of the
+// previous call or the initial value for the first call. For example, Reduce(fs.All(), 0, add) returns the sum of the
+// values of a FlatSet. Methods can not have type parameters, so this function takes an iterator such as the All or
+// Backward iterator of a container instead.
+//
+func Reduce[V, A any](values iter.Seq[V], init A, fn func(A, V) A) A {
+    acc := init
+    for value := range values {
+        acc = fn(acc, value)
+    }
+    return acc
+}
+
+
+// Returns an iterator over the start time of each bucket of this interval and the number of values in the bucket, for
+// values such as the times in a FlatMultiSet[time.Time] or structures sorted by a time. The time of each value is
+// returned by the key function and the buckets are found with time.Truncate, so the values are counted in a single
+// pass. The values must be in ascending order of their times, and buckets without any values are not returned.
+//
+func Buckets[V any](values iter.Seq[V], interval time.Duration, key func(V) time.Time) iter.Seq2[time.Time, int] {
+    return func(yield func(time.Time, int) bool) {
+        var bucket time.Time
+        count := 0
+        for value := range values {
+            start := key(value).Truncate(interval)
+            if count > 0 && !start.Equal(bucket) {
+                if !yield(bucket, count) {
+                    return
+                }
+                count = 0
+            }
+            bucket = start
+            count++
+        }
+        if count > 0 {
+            yield(bucket, count)
+        }
+    }
+}
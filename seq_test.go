@@ -0,0 +1,53 @@
+package flatset
+
+import (
+    "slices"
+    "strconv"
+    "testing"
+    "time"
+)
+
+
+// Test the Reduce function folds the values of a container in order.
+//
+func TestReduce(t *testing.T) {
+    fs := InitFlatSet([]int {3, 1, 2}, lessInt)
+    if actual := Reduce(fs.All(), 0, func(sum, value int) int { return sum + value }); actual != 6 {
+        t.Errorf("Reduce(): expected(6), actual(%d)", actual)
+    }
+    join := func(acc string, value int) string { return acc + strconv.Itoa(value) }
+    if actual := Reduce(fs.Backward(), "", join); actual != "321" {
+        t.Errorf("Reduce(): expected(\"321\"), actual(%q)", actual)
+    }
+    if actual := Reduce(slices.Values([]int(nil)), "init", join); actual != "init" {
+        t.Errorf("Reduce(): expected(\"init\"), actual(%q)", actual)
+    }
+}
+
+
+// Test the Buckets function counts the times in each interval of a FlatMultiSet.
+//
+func TestBuckets(t *testing.T) {
+    start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+    var times []time.Time
+    for _, offset := range []int {0, 59, 60, 61, 61, 250, 299, 600} {
+        times = append(times, start.Add(time.Duration(offset) * time.Second))
+    }
+    fm := InitFlatMultiSet(times, func(a, b time.Time) bool { return a.Before(b) })
+
+    var buckets []int
+    var counts []int
+    for bucket, count := range Buckets(fm.All(), time.Minute, func(t time.Time) time.Time { return t }) {
+        buckets = append(buckets, int(bucket.Sub(start) / time.Second))
+        counts = append(counts, count)
+    }
+    if !slices.Equal(buckets, []int {0, 60, 240, 600}) || !slices.Equal(counts, []int {2, 3, 2, 1}) {
+        t.Errorf("Buckets(): expected([0 60 240 600] [2 3 2 1]), actual(%v %v)", buckets, counts)
+    }
+    for bucket, count := range Buckets(fm.All(), time.Hour, func(t time.Time) time.Time { return t }) {
+        if !bucket.Equal(start) || count != 8 {
+            t.Errorf("Buckets(): expected(%v, 8), actual(%v, %d)", start, bucket, count)
+        }
+        break
+    }
+}
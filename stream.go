@@ -0,0 +1,175 @@
+package flatset
+
+
+import (
+    "errors"
+    "io"
+    "iter"
+)
+
+// The error returned when values that must be sorted by a comparison function are out of order.
+//
+var ErrOutOfOrder = errors.New("flatset: values are out of order")
+
+
+// Private function that returns the optional compression of the streaming functions.
+//
+func optionalCompression(compression []Compression) *Compression {
+    if len(compression) > 0 {
+        return &compression[0]
+    }
+    return nil
+}
+
+
+// Returns an iterator over the values read from the reader, that were written by Save or SaveSeq using the same codec,
+// reading a single block at a time so that the whole of the serialized data is never in memory. If the values were
+// written compressed the compression must be passed. If an error occurs it is returned with the zero value as the last
+// pair of the iterator.
+//
+func ScanSaved[V any](r io.Reader, codec Codec[V], compression ...Compression) iter.Seq2[V, error] {
+    return func(yield func(V, error) bool) {
+        var zero V
+        dec, err := newDecoder(r, codec, nil, optionalCompression(compression))
+        if err != nil {
+            yield(zero, err)
+            return
+        }
+        for {
+            value, ok, err := dec.next()
+            if err != nil {
+                yield(zero, err)
+                return
+            } else if !ok || !yield(value, nil) {
+                return
+            }
+        }
+    }
+}
+
+
+// Write the values from the iterator to the writer in the format of Save, encoding a single block at a time so that
+// the values are never all in memory. The values can then be loaded by Load or LoadCompressed. If the iterator returns
+// an error nothing more is written and the error is returned.
+//
+func SaveSeq[V any](w io.Writer, codec Codec[V], values iter.Seq2[V, error], compression ...Compression) error {
+    enc, err := newEncoder(w, codec, optionalCompression(compression), unknownSize)
+    if err != nil {
+        return err
+    }
+    for value, err := range values {
+        if err != nil {
+            return err
+        }
+        if err := enc.add(value); err != nil {
+            return err
+        }
+    }
+    return enc.close()
+}
+
+
+// Returns an iterator over the union of the values from these iterators, which must each be sorted using the comparison
+// function, such as the iterators returned by ScanSaved for the files of a FlatSet that was saved in shards. The
+// iterators are merged one value at a time so the memory used does not depend on the number of values. Values that are
+// repeated are only returned once, and the value from the first iterator is returned for equivalent values. If an
+// iterator is not sorted ErrOutOfOrder is returned as the last pair of the iterator.
+//
+func UnionSaved[V any](cmp Compare[V], inputs ...iter.Seq2[V, error]) iter.Seq2[V, error] {
+    return func(yield func(V, error) bool) {
+        var zero V
+        type head struct {
+            value V
+            next func() (V, error, bool)
+        }
+        // the heads are kept in a binary heap ordered by their value and then by the order of the iterators
+        heap := make([]int, 0, len(inputs))
+        heads := make([]head, len(inputs))
+        less := func(a, b int) bool {
+            if cmp(heads[heap[a]].value, heads[heap[b]].value) {
+                return true
+            }
+            return !cmp(heads[heap[b]].value, heads[heap[a]].value) && heap[a] < heap[b]
+        }
+        down := func(i int) {
+            for {
+                child := 2 * i + 1
+                if child >= len(heap) {
+                    return
+                } else if child + 1 < len(heap) && less(child + 1, child) {
+                    child++
+                }
+                if !less(child, i) {
+                    return
+                }
+                heap[i], heap[child] = heap[child], heap[i]
+                i = child
+            }
+        }
+
+        for i, input := range inputs {
+            next, stop := iter.Pull2(input)
+            defer stop()
+            value, err, ok := next()
+            if err != nil {
+                yield(zero, err)
+                return
+            } else if ok {
+                heads[i] = head{value, next}
+                heap = append(heap, i)
+            }
+        }
+        for i := len(heap) / 2 - 1; i >= 0; i-- {
+            down(i)
+        }
+
+        var last V
+        for count := 0; len(heap) > 0; {
+            top := &heads[heap[0]]
+            if count == 0 || cmp(last, top.value) {
+                if !yield(top.value, nil) {
+                    return
+                }
+                last = top.value
+                count++
+            }
+            value, err, ok := top.next()
+            if err != nil {
+                yield(zero, err)
+                return
+            } else if !ok {
+                heap[0] = heap[len(heap) - 1]
+                heap = heap[:len(heap) - 1]
+            } else if cmp(value, top.value) {
+                yield(zero, ErrOutOfOrder)
+                return
+            } else {
+                top.value = value
+            }
+            down(0)
+        }
+    }
+}
+
+
+// Create a FrozenFlatSet from the values of the iterator, which must be sorted using the comparison function, such as
+// the iterator returned by UnionSaved, without creating a FlatSet first. Values that are repeated are discarded. If the
+// iterator returns an error or the values are not sorted then the error is returned.
+//
+func FreezeSeq[V any](values iter.Seq2[V, error], cmp Compare[V], layout Layout) (*FrozenFlatSet[V], error) {
+    var data []V
+    for value, err := range values {
+        if err != nil {
+            return nil, err
+        }
+        if len(data) > 0 {
+            if cmp(value, data[len(data) - 1]) {
+                return nil, ErrOutOfOrder
+            } else if !cmp(data[len(data) - 1], value) {
+                continue
+            }
+        }
+        data = append(data, value)
+    }
+    return freeze(data, cmp, layout), nil
+}
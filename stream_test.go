@@ -0,0 +1,71 @@
+package flatset
+
+import (
+    "bytes"
+    "errors"
+    "iter"
+    "slices"
+    "testing"
+)
+
+
+// Test the UnionSaved function merges saved shards into a new file and into a FrozenFlatSet.
+//
+func TestUnionSaved(t *testing.T) {
+    var all []int
+    shards := make([]bytes.Buffer, 3)
+    for i := range shards {
+        values := randInt(0, 50000, 20000)
+        all = append(all, values...)
+        var err error
+        if i == 1 {
+            err = InitFlatSet(values, lessInt).SaveCompressed(&shards[i], IntegerCodec[int]{}, Gzip)
+        } else {
+            err = InitFlatSet(values, lessInt).Save(&shards[i], IntegerCodec[int]{})
+        }
+        if err != nil {
+            t.Fatalf("FlatSet.Save() failed: %v", err)
+        }
+    }
+    expected := InitFlatSet(all, lessInt)
+
+    scan := func() []iter.Seq2[int, error] {
+        return []iter.Seq2[int, error] {
+            ScanSaved(bytes.NewReader(shards[0].Bytes()), IntegerCodec[int]{}),
+            ScanSaved(bytes.NewReader(shards[1].Bytes()), IntegerCodec[int]{}, Gzip),
+            ScanSaved(bytes.NewReader(shards[2].Bytes()), IntegerCodec[int]{}),
+        }
+    }
+
+    var merged bytes.Buffer
+    if err := SaveSeq(&merged, IntegerCodec[int]{}, UnionSaved(lessInt, scan()...)); err != nil {
+        t.Fatalf("SaveSeq() failed: %v", err)
+    }
+    loaded := NewFlatSet(lessInt)
+    if err := loaded.Load(&merged, IntegerCodec[int]{}); err != nil || !slices.Equal(loaded.data, expected.data) {
+        t.Errorf("UnionSaved(): expected(%d) values, actual(%d, %v)", expected.Size(), loaded.Size(), err)
+    }
+
+    frozen, err := FreezeSeq(UnionSaved(lessInt, scan()...), lessInt, BlockLayout)
+    if err != nil || !slices.Equal(frozen.sorted.data, expected.data) {
+        t.Errorf("FreezeSeq(): expected(%d) values, actual(%v)", expected.Size(), err)
+    }
+
+    for range UnionSaved(lessInt, scan()...) {
+        break
+    }
+
+    unsorted := NewFlatSet(greaterInt)
+    unsorted.Update(slices.Values([]int {1, 2, 3}))
+    var buf bytes.Buffer
+    if err := unsorted.Save(&buf, IntegerCodec[int]{}); err != nil {
+        t.Fatalf("FlatSet.Save() failed: %v", err)
+    }
+    _, err = FreezeSeq(UnionSaved(lessInt, ScanSaved(&buf, IntegerCodec[int]{})), lessInt, SortedLayout)
+    if !errors.Is(err, ErrOutOfOrder) {
+        t.Errorf("UnionSaved(): expected(%v), actual(%v)", ErrOutOfOrder, err)
+    }
+    if _, err := FreezeSeq(ScanSaved(bytes.NewReader(nil), IntegerCodec[int]{}), lessInt, SortedLayout); err == nil {
+        t.Errorf("ScanSaved(): expected an error for an empty reader")
+    }
+}
@@ -0,0 +1,303 @@
+package flatset
+
+
+import (
+    "iter"
+    "math"
+    "slices"
+    "sort"
+    "strings"
+    "unsafe"
+)
+
+// The location of a string within the buffer of a StringSet.
+//
+type span struct {
+    offset uint32   // offset of the first byte of the string
+    length uint32   // number of bytes in the string
+}
+
+
+// A StringSet is a set of strings sorted by their content that stores the bytes of every string in a single buffer,
+// with a sorted table of the offset and length of each string. Compared to a FlatSet[string] it does not allocate
+// memory for each string and the garbage collector does not need to scan a pointer for each string, which greatly
+// reduces the cost of dictionaries with millions of strings. It has the same binary search methods as a FlatSet. The
+// bytes of removed strings are not reused until Compact is called, and the buffer is limited to 4 GiB.
+//
+type StringSet struct {
+    guard guard     // detects unsynchronized use in debug builds
+    buf []byte      // bytes of the strings in the order they were inserted
+    index []span    // location of each string in the buffer, sorted by the content of the strings
+}
+
+
+// Create a new empty StringSet and return a pointer to it.
+//
+func NewStringSet() *StringSet {
+    return &StringSet{}
+}
+
+
+// Create a new StringSet containing these strings and return a pointer to it. The strings do not need to be sorted and
+// any repeated strings are ignored. The strings are sorted once like Update, so the buffer holds each string once.
+//
+func InitStringSet(values []string) *StringSet {
+    self := &StringSet{}
+    self.Update(slices.Values(values))
+    return self
+}
+
+
+// Private method that returns the string at this index of the table without copying its bytes.
+//
+func (self *StringSet) str(index int) string {
+    s := self.index[index]
+    return unsafe.String(unsafe.SliceData(self.buf[s.offset:]), int(s.length))
+}
+
+
+// Returns the string at the given index. The string refers to the buffer of this set without copying it, which is safe
+// as the bytes of a string are never modified after they are inserted.
+//
+func (self *StringSet) At(index int) string {
+    if debug {
+        defer self.guard.read()()
+    }
+    return self.str(index)
+}
+
+
+// Returns the number of strings stored in this set.
+//
+func (self *StringSet) Size() int {
+    return len(self.index)
+}
+
+
+// Returns the number of bytes used by the buffer of this set, including the bytes of any removed strings.
+//
+func (self *StringSet) BufferSize() int {
+    return len(self.buf)
+}
+
+
+// Returns an index to the first string that is not less than this string.
+//
+func (self *StringSet) LowerBound(value string) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    return sort.Search(len(self.index), func(i int) bool { return self.str(i) >= value })
+}
+
+
+// Returns an index to the first string that is greater than this string.
+//
+func (self *StringSet) UpperBound(value string) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    return sort.Search(len(self.index), func(i int) bool { return self.str(i) > value })
+}
+
+
+// Searches for a string within this set and returns its index and true if it is found, otherwise it returns the index
+// where the string would be inserted and false.
+//
+func (self *StringSet) Search(value string) (int, bool) {
+    if debug {
+        defer self.guard.read()()
+    }
+    lb := self.LowerBound(value)
+    return lb, lb < len(self.index) && self.str(lb) == value
+}
+
+
+// Returns true if this set has this string or false if it does not.
+//
+func (self *StringSet) Contains(value string) bool {
+    if debug {
+        defer self.guard.read()()
+    }
+    _, found := self.Search(value)
+    return found
+}
+
+
+// Searches for a string within this set, and returns the index for the location of the string or -1 if not found.
+//
+func (self *StringSet) Find(value string) int {
+    if debug {
+        defer self.guard.read()()
+    }
+    lb, found := self.Search(value)
+    if found {
+        return lb
+    }
+    return -1
+}
+
+
+// Insert a string into this set. Returns the index of the string and true if it was inserted, or the index of the
+// existing string and false. The bytes of the string are appended to the buffer of this set. This method will
+// invalidate any previous indices and will panic if the buffer would exceed 4 GiB.
+//
+func (self *StringSet) Insert(value string) (int, bool) {
+    if debug {
+        defer self.guard.write()()
+    }
+    lb, found := self.Search(value)
+    if found {
+        return lb, false
+    }
+    if uint64(len(self.buf)) + uint64(len(value)) > math.MaxUint32 {
+        panic("flatset: StringSet buffer exceeds 4 GiB")
+    }
+    s := span{offset: uint32(len(self.buf)), length: uint32(len(value))}
+    self.buf = append(self.buf, value...)
+    self.index = append(self.index, span{})
+    copy(self.index[lb + 1:], self.index[lb:])
+    self.index[lb] = s
+    return lb, true
+}
+
+
+// Insert the strings from an iterator into this set. The strings are collected and sorted once, and then merged with
+// the table of this set in a single pass, so that the table is not shifted for each string. Only the bytes of the
+// strings that are not already in this set are appended to the buffer. This method will invalidate any previous
+// indices and will panic if the buffer would exceed 4 GiB.
+//
+func (self *StringSet) Update(values iter.Seq[string]) {
+    if debug {
+        defer self.guard.write()()
+    }
+    sorted := slices.Compact(slices.Sorted(values))
+    size := len(self.index)
+    index := make([]span, 0, size + len(sorted))
+    i := 0
+    for _, value := range sorted {
+        for ; i < size && self.str(i) < value; i++ {
+            index = append(index, self.index[i])
+        }
+        if i < size && self.str(i) == value {
+            continue
+        } else if uint64(len(self.buf)) + uint64(len(value)) > math.MaxUint32 {
+            panic("flatset: StringSet buffer exceeds 4 GiB")
+        }
+        index = append(index, span{offset: uint32(len(self.buf)), length: uint32(len(value))})
+        self.buf = append(self.buf, value...)
+    }
+    self.index = append(index, self.index[i:]...)
+}
+
+
+// Delete the string at this index. The bytes of the string remain in the buffer until Compact is called. This method
+// will invalidate any previous indices.
+//
+func (self *StringSet) Erase(index int) {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.index = append(self.index[:index], self.index[index + 1:]...)
+}
+
+
+// Remove a string from this set. Returns true if the string was removed or false if it was not found. This method will
+// invalidate any previous indices.
+//
+func (self *StringSet) Remove(value string) bool {
+    if debug {
+        defer self.guard.write()()
+    }
+    lb, found := self.Search(value)
+    if found {
+        self.Erase(lb)
+    }
+    return found
+}
+
+
+// Empty this set. The buffer is released rather than reused, as the strings returned by At may still refer to it.
+//
+func (self *StringSet) Clear() {
+    if debug {
+        defer self.guard.write()()
+    }
+    self.buf = nil
+    self.index = self.index[:0]
+}
+
+
+// Copy the strings of this set into a new buffer in sorted order, releasing the bytes of any removed strings. The
+// strings previously returned by At still refer to the old buffer, which is freed when they are no longer used.
+//
+func (self *StringSet) Compact() {
+    if debug {
+        defer self.guard.write()()
+    }
+    size := 0
+    for _, s := range self.index {
+        size += int(s.length)
+    }
+    buf := make([]byte, 0, size)
+    for i, s := range self.index {
+        self.index[i].offset = uint32(len(buf))
+        buf = append(buf, self.buf[s.offset:s.offset + s.length]...)
+    }
+    self.buf = buf
+}
+
+
+// Returns an iterator that returns each string in sorted order.
+//
+func (self *StringSet) All() iter.Seq[string] {
+    return func(yield func(string) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
+        for i := 0; i < len(self.index); i++ {
+            if !yield(self.str(i)) {
+                break
+            }
+            if debug {
+                self.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Returns an iterator that iterates in reverse order returning each string.
+//
+func (self *StringSet) Backward() iter.Seq[string] {
+    return func(yield func(string) bool) {
+        var generation uint64
+        if debug {
+            generation = self.guard.generation()
+        }
+        for i := len(self.index) - 1; i >= 0; i-- {
+            if !yield(self.str(i)) {
+                break
+            }
+            if debug {
+                self.guard.iterated(generation)
+            }
+        }
+    }
+}
+
+
+// Returns a new FlatSet containing a copy of each string of this set sorted in ascending order.
+//
+func (self *StringSet) ToFlatSet() *FlatSet[string] {
+    if debug {
+        defer self.guard.read()()
+    }
+    out := NewFlatSet[string](orderedCompare[string]())
+    out.data = make([]string, len(self.index))
+    for i := range self.index {
+        out.data[i] = strings.Clone(self.str(i))
+    }
+    return out
+}
@@ -0,0 +1,86 @@
+package flatset
+
+
+import (
+    "slices"
+    "strconv"
+    "testing"
+)
+
+// Test the StringSet returns the same results as a FlatSet of the same strings, before and after it is compacted.
+//
+func TestStringSet(t *testing.T) {
+    var values []string
+    for _, value := range randInt(0, 2000, 1000) {
+        values = append(values, strconv.Itoa(value))
+    }
+    values = append(values, "")
+    ss := InitStringSet(values)
+    fs := InitFlatSet(values, func(a, b string) bool { return a < b })
+
+    check := func(name string) {
+        if !slices.Equal(slices.Collect(ss.All()), fs.data) || !slices.Equal(ss.ToFlatSet().data, fs.data) {
+            t.Errorf("StringSet.All() %s: expected(%d) strings, actual(%d)", name, fs.Size(), ss.Size())
+        }
+        if !slices.Equal(slices.Collect(ss.Backward()), slices.Collect(fs.Backward())) {
+            t.Errorf("StringSet.Backward() %s: expected(descending strings)", name)
+        }
+        for _, value := range []string {"", "0", "1000", "1999", "2000", "5", "50", "500x", "zzz"} {
+            index, found := ss.Search(value)
+            expectedIndex, expectedFound := fs.Search(value)
+            if index != expectedIndex || found != expectedFound || ss.UpperBound(value) != fs.UpperBound(value) {
+                t.Errorf("StringSet.Search(%q) %s: expected(%d, %t), actual(%d, %t)", value, name, expectedIndex,
+                         expectedFound, index, found)
+            }
+        }
+    }
+    check("inserted")
+    if !isLessOrdered(ss.ToFlatSet().cmp) {
+        t.Errorf("StringSet.ToFlatSet(): expected(sorted with <)")
+    }
+
+    for _, value := range values[:300] {
+        if ss.Remove(value) != fs.Remove(value) {
+            t.Errorf("StringSet.Remove(%q): expected(%t)", value, !ss.Contains(value))
+        }
+    }
+    check("removed")
+
+    size := ss.BufferSize()
+    first := ss.At(0)
+    ss.Compact()
+    if ss.BufferSize() >= size || ss.At(0) != first {
+        t.Errorf("StringSet.Compact(): expected(< %d) bytes, actual(%d)", size, ss.BufferSize())
+    }
+    check("compacted")
+
+    if index, inserted := ss.Insert("abc"); !inserted || ss.At(index) != "abc" || ss.Find("abc") != index {
+        t.Errorf("StringSet.Insert(abc): expected(true), actual(%t)", inserted)
+    }
+    ss.Clear()
+    if ss.Size() != 0 || ss.Contains("abc") {
+        t.Errorf("StringSet.Clear(): expected(0), actual(%d)", ss.Size())
+    }
+}
+
+
+// Test the Update method merges unsorted and repeated strings, appending only the bytes of the new strings.
+//
+func TestStringSetUpdate(t *testing.T) {
+    ss := InitStringSet([]string {"pear", "fig", "apple", "fig", "pear"})
+    if expected := []string {"apple", "fig", "pear"}; !slices.Equal(slices.Collect(ss.All()), expected) {
+        t.Errorf("InitStringSet(): expected(%v), actual(%v)", expected, slices.Collect(ss.All()))
+    }
+    if expected := len("applefigpear"); ss.BufferSize() != expected {
+        t.Errorf("InitStringSet(): expected(%d) bytes, actual(%d)", expected, ss.BufferSize())
+    }
+
+    ss.Update(slices.Values([]string {"zoo", "fig", "banana", "", "banana", "apple"}))
+    expected := []string {"", "apple", "banana", "fig", "pear", "zoo"}
+    if actual := slices.Collect(ss.All()); !slices.Equal(actual, expected) {
+        t.Errorf("StringSet.Update(): expected(%v), actual(%v)", expected, actual)
+    }
+    if expected := len("applefigpearbananazoo"); ss.BufferSize() != expected {
+        t.Errorf("StringSet.Update(): expected(%d) bytes, actual(%d)", expected, ss.BufferSize())
+    }
+}
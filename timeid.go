@@ -0,0 +1,79 @@
+package flatset
+
+
+import (
+    "encoding/binary"
+    "errors"
+    "iter"
+    "strings"
+    "time"
+)
+
+// Private function that returns the first 48 bits of an ID, which are the milliseconds since the Unix epoch for ULIDs
+// and UUIDv7.
+//
+func idMillis[V ~[16]byte](id V) uint64 {
+    return binary.BigEndian.Uint64(id[:8]) >> 16
+}
+
+
+// Returns the time that a ULID or UUIDv7 was created, from the milliseconds since the Unix epoch stored in big-endian
+// order in its first 6 bytes.
+//
+func IDTime[V ~[16]byte](id V) time.Time {
+    return time.UnixMilli(int64(idMillis(id)))
+}
+
+
+// Returns the smallest ULID or UUIDv7 that could have been created at this time, which has the milliseconds since the
+// Unix epoch in its first 6 bytes and zero for the remaining bytes. The time is truncated to a millisecond.
+//
+func IDTimeBound[V ~[16]byte](t time.Time) V {
+    var id V
+    binary.BigEndian.PutUint64(id[:8], uint64(t.UnixMilli()) << 16)
+    return id
+}
+
+
+// Returns an iterator over the ULIDs or UUIDv7 in a FlatSet sorted with LessBytes that were created from this time
+// (inclusive) upto this time (exclusive). As the IDs begin with their creation time in big-endian order they are sorted
+// by time, so the range is found with two binary searches. The times are truncated to a millisecond.
+//
+func CreatedBetween[V ~[16]byte](self *FlatSet[V], from, upto time.Time) iter.Seq[V] {
+    return func(yield func(V) bool) {
+        for i, end := self.LowerBound(IDTimeBound[V](from)), self.LowerBound(IDTimeBound[V](upto)); i < end; i++ {
+            if !yield(self.data[i]) {
+                return
+            }
+        }
+    }
+}
+
+
+// The alphabet of Crockford's base 32 that is used to encode ULIDs.
+//
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+
+// Parse a ULID from its canonical encoding of 26 characters of Crockford's base 32. The characters are not case
+// sensitive. An error is returned if the string is not a valid ULID.
+//
+func ParseULID(s string) ([16]byte, error) {
+    var id [16]byte
+    if len(s) != 26 || !strings.ContainsRune("01234567", rune(s[0])) {
+        return id, errors.New("flatset: invalid ULID: " + s)
+    }
+    // the 130 bits of the characters are shifted into the 128 bits of the ID from the least significant end
+    var hi, lo uint64
+    for _, c := range strings.ToUpper(s) {
+        digit := strings.IndexRune(crockford, c)
+        if digit < 0 {
+            return id, errors.New("flatset: invalid ULID: " + s)
+        }
+        hi = hi << 5 | lo >> 59
+        lo = lo << 5 | uint64(digit)
+    }
+    binary.BigEndian.PutUint64(id[:8], hi)
+    binary.BigEndian.PutUint64(id[8:], lo)
+    return id, nil
+}
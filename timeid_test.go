@@ -0,0 +1,49 @@
+package flatset
+
+
+import (
+    "slices"
+    "testing"
+    "time"
+)
+
+// Test the IDs created within a time range are found in a set of ULIDs and UUIDv7.
+//
+func TestCreatedBetween(t *testing.T) {
+    ulid, err := ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+    if err != nil || IDTime(ulid).UnixMilli() != 1469922850259 {
+        t.Errorf("ParseULID(01ARZ3NDEKTSV4RRFFQ69G5FAV): expected(1469922850259), actual(%d, %v)",
+                 IDTime(ulid).UnixMilli(), err)
+    }
+    if lower, _ := ParseULID("01arz3ndektsv4rrffq69g5fav"); lower != ulid {
+        t.Errorf("ParseULID(01arz3ndektsv4rrffq69g5fav): expected(%x), actual(%x)", ulid, lower)
+    }
+    for _, invalid := range []string {"01ARZ3NDEKTSV4RRFFQ69G5FA", "81ARZ3NDEKTSV4RRFFQ69G5FAV",
+                                      "01ARZ3NDEKTSV4RRFFQ69G5FAU"} {
+        if _, err := ParseULID(invalid); err == nil {
+            t.Errorf("ParseULID(%s): expected(error), actual(nil)", invalid)
+        }
+    }
+
+    uuids := []string {"017f22e2-79b0-7cc3-98c4-dc0c0c07398f", "017f22e2-79b1-7000-8000-000000000000",
+                       "017f22e2-79b2-7fff-bfff-ffffffffffff", "017f22e2-79b4-7000-8000-000000000001"}
+    fs, err := InitHexSet[[16]byte](uuids)
+    if err != nil {
+        t.Fatalf("InitHexSet(): expected(nil), actual(%v)", err)
+    }
+    base := IDTime(fs.At(0))
+    if base.UnixMilli() != 0x017f22e279b0 {
+        t.Errorf("IDTime(%s): expected(%d), actual(%d)", uuids[0], 0x017f22e279b0, base.UnixMilli())
+    }
+    for _, test := range []struct { from, upto int64; expected []int } {
+        {0, 3, []int {0, 1, 2}}, {1, 2, []int {1}}, {1, 5, []int {1, 2, 3}}, {3, 4, nil}, {-5, 0, nil}} {
+        var actual []int
+        for id := range CreatedBetween(fs, base.Add(time.Duration(test.from) * time.Millisecond),
+                                       base.Add(time.Duration(test.upto) * time.Millisecond)) {
+            actual = append(actual, fs.Find(id))
+        }
+        if !slices.Equal(actual, test.expected) {
+            t.Errorf("CreatedBetween(%d, %d): expected(%v), actual(%v)", test.from, test.upto, test.expected, actual)
+        }
+    }
+}
@@ -0,0 +1,108 @@
+package flatset
+
+
+import (
+    "context"
+    "runtime/trace"
+    "strconv"
+    "sync/atomic"
+    "time"
+)
+
+// Enables the runtime/trace annotations for the operations that can take a long time to complete.
+//
+var tracing atomic.Bool
+
+// The thresholds and function of the slow operation hook, or nil if there is no hook.
+//
+var slowHook atomic.Pointer[slowOperationHook]
+
+
+// Describes an operation that exceeded a threshold of the slow operation hook.
+//
+type SlowOperation struct {
+    Name string                 // name of the operation, such as "flatset.FlatSet.Merge"
+    Size int                    // number of values in the container when the operation started
+    Moved int                   // number of values moved or copied by the operation
+    Duration time.Duration      // time taken by the operation, or 0 for an Insert
+}
+
+
+// The thresholds and function set by SetSlowOperationHook.
+//
+type slowOperationHook struct {
+    duration time.Duration      // minimum duration of a slow operation, or 0
+    moved int                   // minimum number of values moved by a slow operation, or 0
+    fn func(SlowOperation)      // function called for each slow operation
+}
+
+
+// Enable or disable the runtime/trace annotations for the operations of this package that can take a long time to
+// complete, such as Merge and Update. Each operation is recorded as a task containing a region, so that latency spikes
+// caused by large operations can be found in an execution trace. The annotations are disabled by default.
+//
+func SetTracing(enabled bool) {
+    tracing.Store(enabled)
+}
+
+
+// Private function that starts a trace task and region for an operation on a container of this size if tracing is
+// enabled, and returns a function to end them.
+//
+func traceOperation(name string, size int) func() {
+    slow := slowOperation(name, size)
+    if !tracing.Load() || !trace.IsEnabled() {
+        return slow
+    }
+    ctx, task := trace.NewTask(context.Background(), name)
+    trace.Log(ctx, "size", strconv.Itoa(size))
+    region := trace.StartRegion(ctx, name)
+    return func() {
+        region.End()
+        task.End()
+        slow()
+    }
+}
+
+
+// Set a function that is called when an operation of this package exceeds the duration or the number of values moved,
+// or nil to remove it. A threshold of 0 is ignored. The bulk operations such as Merge, Update and Save are timed and
+// are treated as moving every value of the container, while an Insert is not timed and only reports the number of
+// values it shifted, so that it stays cheap. This allows production code to log the containers that have outgrown the
+// flat representation. The function is called by the goroutine that performed the operation.
+//
+func SetSlowOperationHook(duration time.Duration, moved int, fn func(SlowOperation)) {
+    if fn == nil {
+        slowHook.Store(nil)
+    } else {
+        slowHook.Store(&slowOperationHook{duration: duration, moved: moved, fn: fn})
+    }
+}
+
+
+// Private function that starts timing a bulk operation on a container of this size if there is a slow operation hook,
+// and returns a function to call the hook if the operation exceeded a threshold.
+//
+func slowOperation(name string, size int) func() {
+    hook := slowHook.Load()
+    if hook == nil {
+        return func() {}
+    }
+    start := time.Now()
+    return func() {
+        elapsed := time.Since(start)
+        if (hook.duration > 0 && elapsed >= hook.duration) || (hook.moved > 0 && size >= hook.moved) {
+            hook.fn(SlowOperation{Name: name, Size: size, Moved: size, Duration: elapsed})
+        }
+    }
+}
+
+
+// Private function that calls the slow operation hook if an Insert into a container of this size shifted at least the
+// threshold number of values.
+//
+func slowShift(size, moved int) {
+    if hook := slowHook.Load(); hook != nil && hook.moved > 0 && moved >= hook.moved {
+        hook.fn(SlowOperation{Name: "flatset.Insert", Size: size, Moved: moved})
+    }
+}
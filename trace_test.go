@@ -0,0 +1,61 @@
+package flatset
+
+import (
+    "bytes"
+    "runtime/trace"
+    "slices"
+    "testing"
+    "time"
+)
+
+
+// Test the operations still work when the trace annotations are enabled while a trace is running.
+//
+func TestTracing(t *testing.T) {
+    var buf bytes.Buffer
+    if err := trace.Start(&buf); err != nil {
+        t.Skipf("trace.Start() failed: %v", err)
+    }
+    SetTracing(true)
+    defer SetTracing(false)
+
+    fs := InitFlatSet[int]([]int {5, 3, 1}, lessInt)
+    fs.Update(slices.Values([]int {2, 4}))
+    fs.Merge(InitFlatSet[int]([]int {6}, lessInt))
+    trace.Stop()
+
+    if !slices.Equal(fs.data, []int {1, 2, 3, 4, 5, 6}) {
+        t.Errorf("traced operations: expected([1 2 3 4 5 6]), actual(%v)", fs.data)
+    } else if buf.Len() == 0 {
+        t.Errorf("trace is empty")
+    }
+}
+
+
+// Test the slow operation hook is called when an operation exceeds a threshold.
+//
+func TestSlowOperationHook(t *testing.T) {
+    var reported []SlowOperation
+    SetSlowOperationHook(0, 3, func(op SlowOperation) { reported = append(reported, op) })
+    defer SetSlowOperationHook(0, 0, nil)
+
+    fs := InitFlatSet[int]([]int {5, 3, 1}, lessInt)
+    fs.Insert(6)
+    fs.Insert(4)
+    fs.Insert(0)
+    expected := []SlowOperation {{Name: "flatset.InitFlatSet", Size: 3, Moved: 3}, {Name: "flatset.Insert", Size: 5,
+        Moved: 5}}
+    if len(reported) != 2 || reported[1] != expected[1] || reported[0].Name != expected[0].Name ||
+        reported[0].Moved != expected[0].Moved {
+        t.Errorf("SetSlowOperationHook(): expected(%v), actual(%v)", expected, reported)
+    }
+
+    reported = nil
+    SetSlowOperationHook(time.Hour, 0, func(op SlowOperation) { reported = append(reported, op) })
+    fs.Merge(InitFlatSet[int](randInt(0, 100, 1000), lessInt))
+    SetSlowOperationHook(0, 0, nil)
+    fs.Insert(-1)
+    if len(reported) != 0 {
+        t.Errorf("SetSlowOperationHook(): expected([]), actual(%v)", reported)
+    }
+}